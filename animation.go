@@ -0,0 +1,392 @@
+package huego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Default time between the commands of an animation. Several lights can be animated at once within
+// the roughly 10 light commands per second that the bridge handles, while group commands are limited to 1 per second.
+const (
+	DefaultLightInterval = 500 * time.Millisecond
+	DefaultGroupInterval = time.Second
+)
+
+// Easing maps the progress of a transition between two keyframes, from 0 to 1, to the progress of the values
+type Easing func(t float64) float64
+
+// Easing functions for keyframes
+var (
+	// Linear changes the values at a constant rate
+	Linear Easing = func(t float64) float64 { return t }
+	// EaseIn starts slowly and speeds up
+	EaseIn Easing = func(t float64) float64 { return t * t }
+	// EaseOut starts quickly and slows down
+	EaseOut Easing = func(t float64) float64 { return t * (2 - t) }
+	// EaseInOut starts and ends slowly
+	EaseInOut Easing = func(t float64) float64 { return t * t * (3 - 2*t) }
+)
+
+// Keyframe is the state of a light or group at a point in an animation. Only on, bri, xy, ct, hue and sat are animated.
+type Keyframe struct {
+	// At is the time from the start of the animation
+	At    time.Duration
+	State *StateUpdate
+	// Easing is used for the transition from the previous keyframe. Defaults to Linear
+	Easing Easing
+}
+
+// Animation changes the state of a light or group through keyframes. Values between keyframes are interpolated:
+// bri and ct linearly, colors in the CIE xy color space. A keyframe with hue and sat or ct is converted to xy when
+// the other keyframe holds a color. Attributes that a keyframe leaves out take the value of the other keyframe,
+// and on is only sent if a keyframe sets it.
+type Animation struct {
+	// Keyframes in the order of their time
+	Keyframes []Keyframe
+	// Interval is the time between commands. Each command transitions the light to the state at the next command
+	// so the bridge fades smoothly in between. Defaults to DefaultLightInterval or DefaultGroupInterval
+	Interval time.Duration
+}
+
+// Duration returns the time of the last keyframe
+func (a *Animation) Duration() time.Duration {
+	if len(a.Keyframes) == 0 {
+		return 0
+	}
+	return a.Keyframes[len(a.Keyframes)-1].At
+}
+
+// Validate returns an error if the animation has no keyframes, a keyframe has no state or the keyframes are out of order
+func (a *Animation) Validate() error {
+	if len(a.Keyframes) == 0 {
+		return errors.New("animation has no keyframes")
+	}
+	for i, k := range a.Keyframes {
+		if k.State == nil {
+			return fmt.Errorf("keyframe %d has no state", i)
+		}
+		if k.At < 0 {
+			return fmt.Errorf("keyframe %d is at negative time %v", i, k.At)
+		}
+		if i > 0 && k.At < a.Keyframes[i-1].At {
+			return fmt.Errorf("keyframe %d at %v is before keyframe %d at %v", i, k.At, i-1, a.Keyframes[i-1].At)
+		}
+	}
+	if a.Interval < 0 {
+		return errors.New("animation interval is negative")
+	}
+	return nil
+}
+
+// StateAt returns the state at time t of the animation
+func (a *Animation) StateAt(t time.Duration) *StateUpdate {
+
+	k := a.Keyframes
+	if len(k) == 0 {
+		return NewStateUpdate()
+	}
+	if t <= k[0].At {
+		return animated(k[0].State)
+	}
+
+	for i := 1; i < len(k); i++ {
+		if t < k[i].At {
+			p := float64(t-k[i-1].At) / float64(k[i].At-k[i-1].At)
+			if k[i].Easing != nil {
+				p = k[i].Easing(p)
+			}
+			return interpolate(k[i-1].State, k[i].State, p)
+		}
+	}
+
+	return animated(k[len(k)-1].State)
+}
+
+// Playback is a running animation
+type Playback struct {
+	mu      sync.Mutex
+	offset  time.Duration
+	started time.Time
+	paused  bool
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+}
+
+// Animate runs a on the light until the animation ends or ctx is cancelled
+func (l *Light) Animate(ctx context.Context, a *Animation) (*Playback, error) {
+	return play(ctx, a, DefaultLightInterval, func(ctx context.Context, u *StateUpdate) error {
+		_, err := l.bridge.SetLightStateContext(ctx, l.ID, u)
+		return err
+	})
+}
+
+// Animate runs a on the group until the animation ends or ctx is cancelled
+func (g *Group) Animate(ctx context.Context, a *Animation) (*Playback, error) {
+	return play(ctx, a, DefaultGroupInterval, func(ctx context.Context, u *StateUpdate) error {
+		_, err := g.bridge.SetGroupStateContext(ctx, g.ID, u)
+		return err
+	})
+}
+
+// Pause stops sending commands. The light finishes the transition of the last command.
+func (p *Playback) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		p.offset += time.Since(p.started)
+		p.paused = true
+		p.notify()
+	}
+}
+
+// Resume continues a paused animation from where it was paused
+func (p *Playback) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		p.started = time.Now()
+		p.paused = false
+		p.notify()
+	}
+}
+
+// Cancel stops the animation. Err returns context.Canceled afterwards.
+func (p *Playback) Cancel() {
+	p.cancel()
+}
+
+// Paused returns true if the animation is paused
+func (p *Playback) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Elapsed returns the time of the animation that has been played, not counting the time it was paused
+func (p *Playback) Elapsed() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		return p.offset
+	}
+	return p.offset + time.Since(p.started)
+}
+
+// Done returns a channel that is closed when the animation has ended, was cancelled or failed
+func (p *Playback) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns the error that stopped the animation, or nil if it ended or is still running
+func (p *Playback) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Wait waits for the animation to stop and returns Err
+func (p *Playback) Wait() error {
+	<-p.done
+	return p.Err()
+}
+
+// notify wakes up the animation after a pause or resume. p.mu must be held.
+func (p *Playback) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// play starts running a in the background, sending commands with send
+func play(ctx context.Context, a *Animation, interval time.Duration, send func(context.Context, *StateUpdate) error) (*Playback, error) {
+
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	if a.Interval > 0 {
+		interval = a.Interval
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Playback{
+		started: time.Now(),
+		wake:    make(chan struct{}, 1),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.done)
+		defer cancel()
+		err := p.run(ctx, a, interval, send)
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+	}()
+
+	return p, nil
+}
+
+// run sends the state of the first keyframe at once, then each interval the state at the next interval with a
+// transition time that lasts until then. Commands that wouldn't change the state are left out.
+func (p *Playback) run(ctx context.Context, a *Animation, interval time.Duration, send func(context.Context, *StateUpdate) error) error {
+
+	d := a.Duration()
+	last := a.StateAt(0)
+	if err := send(ctx, withTransition(last, 0)); err != nil {
+		return err
+	}
+
+	for {
+
+		for p.Paused() {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-p.wake:
+			}
+		}
+
+		t := p.Elapsed()
+		if t >= d {
+			return nil
+		}
+
+		next := t + interval
+		if next > d {
+			next = d
+		}
+
+		s := a.StateAt(next)
+		if !reflect.DeepEqual(s, last) {
+			if err := send(ctx, withTransition(s, uint16((next-t)/(100*time.Millisecond)))); err != nil {
+				return err
+			}
+			last = s
+		}
+
+		timer := time.NewTimer(next - t)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-p.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// withTransition returns a copy of s with transition time tt
+func withTransition(s *StateUpdate, tt uint16) *StateUpdate {
+	return s.stateUpdate().SetTransitionTime(tt)
+}
+
+// animated returns the attributes of s that are animated
+func animated(s *StateUpdate) *StateUpdate {
+	if s == nil {
+		return NewStateUpdate()
+	}
+	a := NewStateUpdate()
+	if s.On != nil {
+		a.SetOn(*s.On)
+	}
+	if s.Bri != nil {
+		a.SetBri(*s.Bri)
+	}
+	if s.Hue != nil {
+		a.SetHue(*s.Hue)
+	}
+	if s.Sat != nil {
+		a.SetSat(*s.Sat)
+	}
+	if len(s.Xy) == 2 {
+		a.SetXy(s.Xy[0], s.Xy[1])
+	}
+	if s.Ct != nil {
+		a.SetCt(*s.Ct)
+	}
+	return a
+}
+
+// interpolate returns the state at progress p, from 0 to 1, of the transition from a to b.
+// The light is on during the transition if it is on in either state.
+func interpolate(a, b *StateUpdate, p float64) *StateUpdate {
+
+	a, b = animated(a), animated(b)
+	s := NewStateUpdate()
+
+	if a.On != nil || b.On != nil {
+		s.SetOn((a.On != nil && *a.On) || (b.On != nil && *b.On))
+	}
+
+	switch {
+	case a.Bri == nil:
+		s.Bri = b.Bri
+	case b.Bri == nil:
+		s.Bri = a.Bri
+	default:
+		s.SetBri(uint8(math.Round(lerp(float64(*a.Bri), float64(*b.Bri), p))))
+	}
+
+	ax, bx := stateXy(a), stateXy(b)
+	switch {
+	case ax == nil && bx == nil:
+	case ax == nil:
+		s.Xy, s.Ct = stateColor(b)
+	case bx == nil:
+		s.Xy, s.Ct = stateColor(a)
+	case isCt(a) && isCt(b):
+		s.SetCt(uint16(math.Round(lerp(float64(*a.Ct), float64(*b.Ct), p))))
+	default:
+		s.SetXy(float32(lerp(float64(ax[0]), float64(bx[0]), p)), float32(lerp(float64(ax[1]), float64(bx[1]), p)))
+	}
+
+	return s
+}
+
+// isCt returns true if the color of s is a color temperature
+func isCt(s *StateUpdate) bool {
+	return len(s.Xy) != 2 && s.Hue == nil && s.Sat == nil && s.Ct != nil
+}
+
+// stateColor returns the color of s as xy, or as ct if s holds a color temperature
+func stateColor(s *StateUpdate) ([]float32, *uint16) {
+	if isCt(s) {
+		return nil, s.Ct
+	}
+	return stateXy(s), nil
+}
+
+// stateXy returns the color of s in xy or nil if s has no color. Hue defaults to 0 and sat to 254 if only one is set.
+func stateXy(s *StateUpdate) []float32 {
+	switch {
+	case len(s.Xy) == 2:
+		return []float32{s.Xy[0], s.Xy[1]}
+	case s.Hue != nil || s.Sat != nil:
+		hue, sat := uint16(0), uint8(254)
+		if s.Hue != nil {
+			hue = *s.Hue
+		}
+		if s.Sat != nil {
+			sat = *s.Sat
+		}
+		xy, _ := ConvertRGBToXy(ConvertHSVToRGB(ConvertHueSatToHSV(hue, sat, 254)))
+		return xy
+	case s.Ct != nil:
+		return ConvertKelvinToXy(ConvertMiredToKelvin(*s.Ct))
+	}
+	return nil
+}
+
+func lerp(a, b, p float64) float64 {
+	return a + (b-a)*p
+}
@@ -0,0 +1,152 @@
+package huego
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnimationStateAt(t *testing.T) {
+
+	a := &Animation{Keyframes: []Keyframe{
+		{At: 0, State: NewStateUpdate().SetOn(true).SetBri(1).SetCt(400).SetAlert("select")},
+		{At: 10 * time.Second, State: NewStateUpdate().SetOn(true).SetBri(201).SetCt(200)},
+		{At: 20 * time.Second, State: NewStateUpdate().SetOn(true).SetXy(0.5, 0.4), Easing: EaseIn},
+		{At: 30 * time.Second, State: NewStateUpdate().SetOn(false).SetBri(1)},
+	}}
+	assert.Nil(t, a.Validate())
+	assert.Equal(t, 30*time.Second, a.Duration())
+
+	assert.Equal(t, NewStateUpdate().SetOn(true).SetBri(1).SetCt(400), a.StateAt(-time.Second))
+	assert.Equal(t, NewStateUpdate().SetOn(true).SetBri(101).SetCt(300), a.StateAt(5*time.Second))
+
+	// Color temperature is converted to xy to blend with a color, and bri is held
+	ct := ConvertKelvinToXy(ConvertMiredToKelvin(200))
+	s := a.StateAt(15 * time.Second)
+	assert.Equal(t, uint8(201), *s.Bri)
+	assert.InDelta(t, ct[0]+(0.5-ct[0])*0.25, s.Xy[0], 0.0001)
+	assert.InDelta(t, ct[1]+(0.4-ct[1])*0.25, s.Xy[1], 0.0001)
+
+	// The light stays on until the last keyframe
+	s = a.StateAt(25 * time.Second)
+	assert.True(t, *s.On)
+	assert.Equal(t, []float32{0.5, 0.4}, s.Xy)
+	assert.Equal(t, NewStateUpdate().SetOn(false).SetBri(1), a.StateAt(time.Minute))
+
+	assert.EqualError(t, (&Animation{}).Validate(), "animation has no keyframes")
+	a.Keyframes[1].At = time.Hour
+	assert.EqualError(t, a.Validate(), "keyframe 2 at 20s is before keyframe 1 at 1h0m0s")
+	a.Keyframes[1].State = nil
+	assert.EqualError(t, a.Validate(), "keyframe 1 has no state")
+}
+
+func TestAnimationZeroValues(t *testing.T) {
+
+	// Red at hue 0 fading to blue, and bri 0, leaving on untouched
+	a := &Animation{Keyframes: []Keyframe{
+		{At: 0, State: NewStateUpdate().SetHue(0).SetSat(254).SetBri(0)},
+		{At: 10 * time.Second, State: NewStateUpdate().SetHue(43690).SetSat(254).SetBri(100)},
+	}}
+
+	red, _ := ConvertRGBToXy(ConvertHSVToRGB(ConvertHueSatToHSV(0, 254, 254)))
+	blue, _ := ConvertRGBToXy(ConvertHSVToRGB(ConvertHueSatToHSV(43690, 254, 254)))
+
+	assert.Equal(t, NewStateUpdate().SetHue(0).SetSat(254).SetBri(0), a.StateAt(0))
+
+	s := a.StateAt(5 * time.Second)
+	assert.Nil(t, s.On)
+	assert.Equal(t, uint8(50), *s.Bri)
+	assert.InDelta(t, (red[0]+blue[0])/2, s.Xy[0], 0.0001)
+	assert.InDelta(t, (red[1]+blue[1])/2, s.Xy[1], 0.0001)
+}
+
+func TestEasing(t *testing.T) {
+	for _, e := range []Easing{Linear, EaseIn, EaseOut, EaseInOut} {
+		assert.Equal(t, 0.0, e(0))
+		assert.Equal(t, 1.0, e(1))
+	}
+	assert.True(t, EaseIn(0.5) < 0.5)
+	assert.True(t, EaseOut(0.5) > 0.5)
+	assert.Equal(t, 0.5, EaseInOut(0.5))
+}
+
+func TestAnimate(t *testing.T) {
+
+	s := newRecorder(nil)
+	defer s.Close()
+
+	b := s.bridge()
+	l := &Light{ID: 1, bridge: b}
+
+	a := &Animation{
+		Interval: 100 * time.Millisecond,
+		Keyframes: []Keyframe{
+			{At: 0, State: NewStateUpdate().SetOn(true).SetBri(1)},
+			{At: 300 * time.Millisecond, State: NewStateUpdate().SetOn(true).SetBri(250)},
+			{At: 500 * time.Millisecond, State: NewStateUpdate().SetOn(true).SetBri(250)},
+		},
+	}
+
+	p, err := l.Animate(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, p.Wait())
+
+	bodies := s.bodies()
+	assert.Equal(t, map[string]interface{}{"on": true, "bri": float64(1), "transitiontime": float64(0)}, bodies[0])
+	// Each command fades to the state at the next interval
+	assert.InDelta(t, 84, bodies[1]["bri"], 5)
+	assert.Equal(t, float64(1), bodies[1]["transitiontime"])
+	assert.Equal(t, float64(250), bodies[len(bodies)-1]["bri"])
+	// The state isn't sent again while it is held
+	assert.True(t, len(bodies) <= 4, "%v", bodies)
+
+	_, err = l.Animate(context.Background(), &Animation{})
+	assert.NotNil(t, err)
+}
+
+func TestPlaybackPauseCancel(t *testing.T) {
+
+	s := newRecorder(nil)
+	defer s.Close()
+
+	b := s.bridge()
+	g := &Group{ID: 1, bridge: b}
+
+	p, err := g.Animate(context.Background(), &Animation{
+		Interval: 20 * time.Millisecond,
+		Keyframes: []Keyframe{
+			{At: 0, State: NewStateUpdate().SetOn(true).SetBri(1)},
+			{At: 2 * time.Second, State: NewStateUpdate().SetOn(true).SetBri(254)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	p.Pause()
+	assert.True(t, p.Paused())
+	elapsed := p.Elapsed()
+	time.Sleep(50 * time.Millisecond)
+	n := len(s.bodies())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, elapsed, p.Elapsed())
+	assert.Equal(t, n, len(s.bodies()))
+
+	p.Resume()
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, p.Elapsed() > elapsed)
+	assert.True(t, len(s.bodies()) > n)
+
+	p.Cancel()
+	assert.Equal(t, context.Canceled, p.Wait())
+	select {
+	case <-p.Done():
+	default:
+		t.Fatal("playback isn't done")
+	}
+}
@@ -0,0 +1,784 @@
+package huego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Actions of a Change
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// ownerDescription is the description of the resourcelinks that record the resources managed by a Spec
+const ownerDescription = "huego apply"
+
+// Spec is the desired state of the groups, scenes, schedules, rules, CLIP sensors and resourcelinks of a bridge.
+// Resources are matched with the resources on the bridge by name, sensors by name and type. The resources that
+// are managed by a spec are recorded in a resourcelink named Owner, so that resources removed from the spec are
+// deleted from the bridge while resources created by others are left alone.
+//
+// Resources refer to each other by name by writing the name in braces, for example a light in the lights of a
+// group as {Hue color lamp 1} or a scene in a rule action as /groups/{Living room}/action with the body {"scene": "{Relax}"}.
+// Addresses of schedule commands may leave out /api/<user>.
+//
+// Attributes that the bridge only sets when a resource is created, such as the type of a group or the state of a
+// sensor, are not compared with the bridge. Changing them in the spec leaves the existing resource as it is.
+type Spec struct {
+	Owner         string          `json:"owner"`
+	Sensors       []*Sensor       `json:"sensors,omitempty"`
+	Groups        []*Group        `json:"groups,omitempty"`
+	Scenes        []*SpecScene    `json:"scenes,omitempty"`
+	Schedules     []*SpecSchedule `json:"schedules,omitempty"`
+	Rules         []*Rule         `json:"rules,omitempty"`
+	Resourcelinks []*Resourcelink `json:"resourcelinks,omitempty"`
+}
+
+// SpecScene is a scene in a Spec. Unlike Scene, the light states may be keyed by light name.
+type SpecScene struct {
+	Name           string           `json:"name"`
+	Group          string           `json:"group,omitempty"`
+	Lights         []string         `json:"lights,omitempty"`
+	LightStates    map[string]State `json:"lightstates,omitempty"`
+	Recycle        bool             `json:"recycle"`
+	AppData        interface{}      `json:"appdata,omitempty"`
+	Picture        string           `json:"picture,omitempty"`
+	TransitionTime uint16           `json:"transitiontime,omitempty"`
+}
+
+// SpecSchedule is a schedule in a Spec. Attributes that are left out keep the value they have on the bridge.
+type SpecSchedule struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Command     *Command `json:"command,omitempty"`
+	Time        string   `json:"time,omitempty"`
+	LocalTime   string   `json:"localtime,omitempty"`
+	Status      string   `json:"status,omitempty"`
+	AutoDelete  *bool    `json:"autodelete,omitempty"`
+}
+
+// Change is a change to one resource on the bridge in a Plan
+type Change struct {
+	// Action is one of ChangeCreate, ChangeUpdate or ChangeDelete
+	Action string
+	// Resource is the collection of the resource, such as CollectionGroups
+	Resource string
+	Name     string
+	// ID is the id of the resource on the bridge, empty for resources that will be created
+	ID string
+	// Fields are the attributes that differ from the spec
+	Fields []string
+
+	desired map[string]interface{}
+	owner   bool
+}
+
+// String returns a one line description of the change
+func (c *Change) String() string {
+	switch c.Action {
+	case ChangeCreate:
+		return fmt.Sprintf("+ %s %q", c.Resource, c.Name)
+	case ChangeUpdate:
+		return fmt.Sprintf("~ %s/%s %q: %s", c.Resource, c.ID, c.Name, strings.Join(c.Fields, ", "))
+	default:
+		return fmt.Sprintf("- %s/%s %q", c.Resource, c.ID, c.Name)
+	}
+}
+
+// Plan is the list of changes that make a bridge match a Spec, returned by Bridge.Plan
+type Plan struct {
+	Changes []*Change
+
+	r *reconciler
+}
+
+// String returns the changes one per line
+func (p *Plan) String() string {
+	if len(p.Changes) == 0 {
+		return "No changes\n"
+	}
+	var sb strings.Builder
+	for _, c := range p.Changes {
+		sb.WriteString(c.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// ReadSpec reads a JSON or YAML spec from r
+func ReadSpec(r io.Reader) (*Spec, error) {
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var v interface{}
+		err = yaml.Unmarshal(data, &v)
+		if err != nil {
+			return nil, err
+		}
+		data, err = json.Marshal(jsonValue(v))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var s Spec
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Validate checks that the spec has an owner and that resources have unique names
+func (s *Spec) Validate() error {
+
+	if s.Owner == "" {
+		return errors.New("spec has no owner")
+	}
+
+	for _, kind := range applyOrder {
+		names := map[string]bool{}
+		for _, o := range s.objects(kind) {
+			name, _ := o["name"].(string)
+			if name == "" {
+				return fmt.Errorf("%s without a name", kind)
+			}
+			if names[name] {
+				return fmt.Errorf("%s %q is defined more than once", kind, name)
+			}
+			if kind == CollectionResourcelinks && name == s.Owner {
+				return fmt.Errorf("resourcelinks %q has the name of the owner", name)
+			}
+			names[name] = true
+		}
+	}
+
+	return nil
+}
+
+// objects returns the resources of kind in the spec as objects
+func (s *Spec) objects(kind string) []map[string]interface{} {
+
+	var v interface{}
+	switch kind {
+	case CollectionSensors:
+		v = s.Sensors
+	case CollectionGroups:
+		v = s.Groups
+	case CollectionScenes:
+		v = s.Scenes
+	case CollectionSchedules:
+		v = s.Schedules
+	case CollectionRules:
+		v = s.Rules
+	case CollectionResourcelinks:
+		v = s.Resourcelinks
+	}
+
+	var objects []map[string]interface{}
+	_ = convert(v, &objects)
+
+	for _, o := range objects {
+		for _, k := range ignoredAttributes[kind] {
+			delete(o, k)
+		}
+	}
+
+	return objects
+}
+
+// The order in which resources are created, they are deleted in the reverse order
+var applyOrder = []string{CollectionSensors, CollectionGroups, CollectionScenes, CollectionSchedules, CollectionRules, CollectionResourcelinks}
+
+// Attributes of a spec that are not compared with the bridge
+var ignoredAttributes = map[string][]string{
+	CollectionSensors:       {"ID"},
+	CollectionGroups:        {"action", "state", "stream"},
+	CollectionRules:         {"ID", "owner", "lasttriggered", "creationtime", "timestriggered"},
+	CollectionSchedules:     {"starttime"},
+	CollectionResourcelinks: {"ID", "owner", "type"},
+}
+
+// Attributes that can only be set when a resource is created, they are left out when comparing and updating.
+// Only the config of sensors can be changed.
+var createOnlyAttributes = map[string][]string{
+	CollectionGroups: {"type", "recycle"},
+	CollectionScenes: {"type", "group", "recycle"},
+}
+
+// Attributes whose values are references to resources of another kind
+var referenceAttributes = map[string]string{
+	"lights":      CollectionLights,
+	"lightstates": CollectionLights,
+	"locations":   CollectionLights,
+	"group":       CollectionGroups,
+	"scene":       CollectionScenes,
+}
+
+// Plan compares spec with the bridge and returns the changes that Apply makes to match it. Resources on the bridge that were
+// applied from a spec with the same owner before and are no longer in spec are deleted.
+func (b *Bridge) Plan(ctx context.Context, spec *Spec) (*Plan, error) {
+
+	err := spec.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := b.GetFullStateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reconciler{
+		bridge:  b,
+		spec:    spec,
+		actual:  map[string]map[string]map[string]interface{}{},
+		names:   map[string]map[string]string{},
+		owned:   map[string]bool{},
+		managed: map[string]bool{},
+		deleted: map[string]bool{},
+	}
+
+	for id, l := range s.Resourcelinks {
+		if l.Name == spec.Owner && l.Description == ownerDescription {
+			r.ownerID = strconv.Itoa(id)
+			for _, a := range l.Links {
+				r.owned[a] = true
+			}
+		}
+	}
+
+	// The full state doesn't include the light states of scenes
+	for id, sc := range s.Scenes {
+		for _, d := range spec.Scenes {
+			if d.Name == sc.Name {
+				s.Scenes[id], err = b.GetSceneContext(ctx, id)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	r.add(CollectionLights, s.Lights)
+	r.add(CollectionSensors, s.Sensors)
+	r.add(CollectionGroups, s.Groups)
+	r.add(CollectionScenes, s.Scenes)
+	r.add(CollectionSchedules, s.Schedules)
+	r.add(CollectionRules, s.Rules)
+	r.add(CollectionResourcelinks, s.Resourcelinks)
+	if r.ownerID != "" {
+		delete(r.actual[CollectionResourcelinks], r.ownerID)
+	}
+	r.index()
+
+	p := &Plan{r: r}
+
+	for _, kind := range applyOrder {
+		for _, desired := range spec.objects(kind) {
+			name := desired["name"].(string)
+			id, ok := r.match(kind, desired)
+			if !ok {
+				p.Changes = append(p.Changes, &Change{Action: ChangeCreate, Resource: kind, Name: name, desired: desired})
+				continue
+			}
+			r.managed["/"+kind+"/"+id] = true
+			resolved, _ := r.resolve(kind, desired, false)
+			fields := changedFields(kind, resolved, r.actual[kind][id])
+			if len(fields) > 0 {
+				p.Changes = append(p.Changes, &Change{Action: ChangeUpdate, Resource: kind, Name: name, ID: id, Fields: fields, desired: desired})
+			}
+		}
+	}
+
+	for i := len(applyOrder) - 1; i >= 0; i-- {
+		kind := applyOrder[i]
+		for _, id := range sortedKeys(r.actual[kind]) {
+			address := "/" + kind + "/" + id
+			if r.owned[address] && !r.managed[address] {
+				name, _ := r.actual[kind][id]["name"].(string)
+				p.Changes = append(p.Changes, &Change{Action: ChangeDelete, Resource: kind, Name: name, ID: id})
+			}
+		}
+	}
+
+	// The owner resourcelink is updated when resources are created or deleted, or when existing resources are adopted
+	changed := r.ownerID == ""
+	for _, c := range p.Changes {
+		changed = changed || c.Action != ChangeUpdate
+	}
+	for a := range r.managed {
+		changed = changed || !r.owned[a]
+	}
+	if changed {
+		c := &Change{Action: ChangeUpdate, Resource: CollectionResourcelinks, Name: spec.Owner, ID: r.ownerID, Fields: []string{"links"}, owner: true}
+		if r.ownerID == "" {
+			c.Action, c.Fields = ChangeCreate, nil
+		}
+		p.Changes = append(p.Changes, c)
+	}
+
+	return p, nil
+}
+
+// Apply makes the changes in plan p, in order. Apply stops at the first change that fails, the owner resourcelink
+// is then still updated so that the resources created before the failure are managed by the spec.
+func (b *Bridge) Apply(ctx context.Context, p *Plan) error {
+	for i, c := range p.Changes {
+		err := p.r.apply(ctx, c)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("%s: %w", c, err)
+		if owner := p.Changes[len(p.Changes)-1]; owner.owner && i < len(p.Changes)-1 && p.r.linksChanged() {
+			if oerr := p.r.apply(ctx, owner); oerr != nil {
+				return fmt.Errorf("%v, %s: %w", err, owner, oerr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// reconciler holds the state of the bridge while planning and applying a spec
+type reconciler struct {
+	bridge *Bridge
+	spec   *Spec
+	// actual holds the resources on the bridge as objects by kind and id
+	actual map[string]map[string]map[string]interface{}
+	// names maps the names of resources to their ids by kind
+	names   map[string]map[string]string
+	ownerID string
+	// owned holds the addresses in the owner resourcelink and managed the addresses of the resources in the spec
+	owned   map[string]bool
+	managed map[string]bool
+	// deleted holds the addresses of the owned resources that Apply has deleted
+	deleted map[string]bool
+}
+
+// add adds the resources in m, a map of resources by id, to the actual resources of kind
+func (r *reconciler) add(kind string, m interface{}) {
+	var objects map[string]map[string]interface{}
+	_ = convert(m, &objects)
+	if objects == nil {
+		objects = map[string]map[string]interface{}{}
+	}
+	r.actual[kind] = objects
+}
+
+// index maps the names of the actual resources to their ids. Resources owned by the spec take precedence
+// over other resources with the same name, and resources with lower ids over higher.
+func (r *reconciler) index() {
+	for kind, objects := range r.actual {
+		r.names[kind] = map[string]string{}
+		ids := sortedKeys(objects)
+		for i := len(ids) - 1; i >= 0; i-- {
+			if name, ok := objects[ids[i]]["name"].(string); ok && !r.owned["/"+kind+"/"+ids[i]] {
+				r.names[kind][name] = ids[i]
+			}
+		}
+		for _, id := range ids {
+			if name, ok := objects[id]["name"].(string); ok && r.owned["/"+kind+"/"+id] {
+				r.names[kind][name] = id
+			}
+		}
+	}
+}
+
+// match returns the id of the resource on the bridge that matches desired
+func (r *reconciler) match(kind string, desired map[string]interface{}) (string, bool) {
+	id, ok := r.names[kind][desired["name"].(string)]
+	if !ok {
+		return "", false
+	}
+	if kind == CollectionSensors && desired["type"] != r.actual[kind][id]["type"] {
+		return "", false
+	}
+	return id, true
+}
+
+func (r *reconciler) apply(ctx context.Context, c *Change) error {
+
+	b := r.bridge
+
+	if c.owner {
+		links := r.links()
+		l := &Resourcelink{Name: r.spec.Owner, Description: ownerDescription, ClassID: 1, Links: links}
+		if c.Action == ChangeCreate {
+			res, err := b.CreateResourcelinkContext(ctx, l)
+			if err != nil {
+				return err
+			}
+			r.ownerID, _ = res.Success["id"].(string)
+			return nil
+		}
+		id, _ := strconv.Atoi(c.ID)
+		_, err := b.UpdateResourcelinkContext(ctx, id, &Resourcelink{Links: links})
+		return err
+	}
+
+	id, _ := strconv.Atoi(c.ID)
+
+	if c.Action == ChangeDelete {
+		var err error
+		switch c.Resource {
+		case CollectionSensors:
+			err = b.DeleteSensorContext(ctx, id)
+		case CollectionGroups:
+			err = b.DeleteGroupContext(ctx, id)
+		case CollectionScenes:
+			err = b.DeleteSceneContext(ctx, c.ID)
+		case CollectionSchedules:
+			err = b.DeleteScheduleContext(ctx, id)
+		case CollectionRules:
+			err = b.DeleteRuleContext(ctx, id)
+		case CollectionResourcelinks:
+			err = b.DeleteResourcelinkContext(ctx, id)
+		}
+		if err == nil {
+			r.deleted["/"+c.Resource+"/"+c.ID] = true
+		}
+		return err
+	}
+
+	desired, err := r.resolve(c.Resource, c.desired, true)
+	if err != nil {
+		return err
+	}
+
+	if c.Action == ChangeUpdate {
+		// Only the attributes that changed are sent, except for schedules that need the command
+		if c.Resource != CollectionSchedules {
+			changed := map[string]interface{}{}
+			for _, f := range c.Fields {
+				changed[f] = desired[f]
+			}
+			desired = changed
+		}
+		return r.update(ctx, c.Resource, c.ID, desired)
+	}
+
+	res, err := r.create(ctx, c.Resource, desired)
+	if err != nil {
+		return err
+	}
+
+	created, ok := res.Success["id"].(string)
+	if !ok {
+		return errors.New("no id was returned by the bridge")
+	}
+	c.ID = created
+	r.names[c.Resource][c.Name] = created
+	r.managed["/"+c.Resource+"/"+created] = true
+
+	return nil
+}
+
+// links returns the addresses to record in the owner resourcelink, the managed resources and the owned resources
+// on the bridge that are yet to be deleted
+func (r *reconciler) links() []string {
+	links := make([]string, 0, len(r.managed))
+	for a := range r.managed {
+		links = append(links, a)
+	}
+	for a := range r.owned {
+		p := strings.Split(strings.TrimPrefix(a, "/"), "/")
+		if len(p) != 2 || r.managed[a] || r.deleted[a] {
+			continue
+		}
+		if _, ok := r.actual[p[0]][p[1]]; ok {
+			links = append(links, a)
+		}
+	}
+	sort.Strings(links)
+	return links
+}
+
+// linksChanged reports whether links differs from the addresses in the owner resourcelink
+func (r *reconciler) linksChanged() bool {
+	links := r.links()
+	if len(links) != len(r.owned) {
+		return true
+	}
+	for _, a := range links {
+		if !r.owned[a] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *reconciler) create(ctx context.Context, kind string, desired map[string]interface{}) (*Response, error) {
+
+	b := r.bridge
+
+	switch kind {
+	case CollectionSensors:
+		var s Sensor
+		if err := convert(desired, &s); err != nil {
+			return nil, err
+		}
+		return b.CreateSensorContext(ctx, &s)
+	case CollectionGroups:
+		var g Group
+		if err := convert(desired, &g); err != nil {
+			return nil, err
+		}
+		return b.CreateGroupContext(ctx, g)
+	case CollectionScenes:
+		var s Scene
+		if err := convert(desired, &s); err != nil {
+			return nil, err
+		}
+		// The bridge creates a LightScene unless told otherwise, which can't have a group
+		if s.Group != "" {
+			s.Type = "GroupScene"
+		}
+		return b.CreateSceneContext(ctx, &s)
+	case CollectionSchedules:
+		// Sent as is since Schedule would add the attributes that the spec leaves out
+		return r.send(ctx, http.MethodPost, desired, "/schedules/")
+	case CollectionRules:
+		var rule Rule
+		if err := convert(desired, &rule); err != nil {
+			return nil, err
+		}
+		return b.CreateRuleContext(ctx, &rule)
+	default:
+		var l Resourcelink
+		if err := convert(desired, &l); err != nil {
+			return nil, err
+		}
+		return b.CreateResourcelinkContext(ctx, &l)
+	}
+}
+
+func (r *reconciler) update(ctx context.Context, kind, id string, desired map[string]interface{}) error {
+
+	b := r.bridge
+	i, _ := strconv.Atoi(id)
+
+	var err error
+	switch kind {
+	case CollectionSensors:
+		if c, ok := desired["config"]; ok {
+			_, err = b.UpdateSensorConfigContext(ctx, i, c)
+		}
+	case CollectionGroups:
+		var g Group
+		if err = convert(desired, &g); err == nil {
+			_, err = b.UpdateGroupContext(ctx, i, g)
+		}
+	case CollectionScenes:
+		var s Scene
+		if err = convert(desired, &s); err == nil {
+			_, err = b.UpdateSceneContext(ctx, id, &s)
+		}
+	case CollectionSchedules:
+		_, err = r.send(ctx, http.MethodPut, desired, "/schedules/", id)
+	case CollectionRules:
+		var rule Rule
+		if err = convert(desired, &rule); err == nil {
+			_, err = b.UpdateRuleContext(ctx, i, &rule)
+		}
+	case CollectionResourcelinks:
+		var l Resourcelink
+		if err = convert(desired, &l); err == nil {
+			_, err = b.UpdateResourcelinkContext(ctx, i, &l)
+		}
+	}
+
+	return err
+}
+
+// send sends desired to the bridge at path with method
+func (r *reconciler) send(ctx context.Context, method string, desired map[string]interface{}, path ...string) (*Response, error) {
+
+	data, err := json.Marshal(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := r.bridge.getAPIPath(path...)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []byte
+	if method == http.MethodPost {
+		res, err = post(ctx, target, data, r.bridge.client)
+	} else {
+		res, err = put(ctx, target, data, r.bridge.client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var a []*APIResponse
+	err = unmarshal(res, &a)
+	if err != nil {
+		return nil, err
+	}
+
+	return handleResponse(a)
+}
+
+// resolve returns a copy of desired with references by name replaced by ids. References to resources that
+// don't exist are an error if strict is set, and are left as they are otherwise.
+func (r *reconciler) resolve(kind string, desired map[string]interface{}, strict bool) (map[string]interface{}, error) {
+
+	v, err := r.resolveValue(desired, "", strict)
+	if err != nil {
+		return nil, err
+	}
+	resolved := v.(map[string]interface{})
+
+	// Schedule commands address the bridge as /api/<user>/<resource>
+	if cmd, ok := resolved["command"].(map[string]interface{}); ok && kind == CollectionSchedules {
+		if a, ok := cmd["address"].(string); ok && !strings.HasPrefix(a, "/api/") {
+			cmd["address"] = "/api/" + r.bridge.User + a
+		}
+	}
+
+	return resolved, nil
+}
+
+// resolveValue resolves the references in v. Strings in braces refer to resources of kind, and
+// strings that start with a slash are addresses with references to resources of the collection before them.
+func (r *reconciler) resolveValue(v interface{}, kind string, strict bool) (interface{}, error) {
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			key := k
+			if kind != "" {
+				id, err := r.lookup(kind, k, strict)
+				if err != nil {
+					return nil, err
+				}
+				key = id
+			}
+			e, err := r.resolveValue(e, referenceAttributes[k], strict)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = e
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, e := range t {
+			e, err := r.resolveValue(e, kind, strict)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = e
+		}
+		return l, nil
+	case string:
+		if strings.HasPrefix(t, "/") {
+			p := strings.Split(t, "/")
+			for i := 2; i < len(p); i++ {
+				id, err := r.lookup(p[i-1], p[i], strict)
+				if err != nil {
+					return nil, err
+				}
+				p[i] = id
+			}
+			return strings.Join(p, "/"), nil
+		}
+		if kind != "" {
+			return r.lookup(kind, t, strict)
+		}
+	}
+
+	return v, nil
+}
+
+// lookup returns the id of the resource of kind named by ref if ref is a reference in braces
+func (r *reconciler) lookup(kind, ref string, strict bool) (string, error) {
+	if !strings.HasPrefix(ref, "{") || !strings.HasSuffix(ref, "}") {
+		return ref, nil
+	}
+	name := ref[1 : len(ref)-1]
+	if id, ok := r.names[kind][name]; ok {
+		return id, nil
+	}
+	if strict {
+		return "", fmt.Errorf("%s %q not found", kind, name)
+	}
+	return ref, nil
+}
+
+// changedFields returns the attributes of desired that differ from actual
+func changedFields(kind string, desired, actual map[string]interface{}) []string {
+	var fields []string
+	for k, v := range desired {
+		if (kind == CollectionSensors && k != "config") || contains(createOnlyAttributes[kind], k) {
+			continue
+		}
+		if !matches(v, actual[k]) {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// matches reports whether actual has the values in desired. Objects in actual may have more attributes than in desired.
+func matches(desired, actual interface{}) bool {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return len(d) == 0 && actual == nil
+		}
+		for k, v := range d {
+			if !matches(v, a[k]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(d) {
+			return len(d) == 0 && actual == nil
+		}
+		for i := range d {
+			if !matches(d[i], a[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(desired, actual)
+}
+
+// convert converts v to dst through their json representation
+func convert(v, dst interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
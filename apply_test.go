@@ -0,0 +1,269 @@
+package huego_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/amimof/huego"
+	"github.com/amimof/huego/huegotest"
+	"github.com/stretchr/testify/assert"
+)
+
+const officeSpec = `
+owner: office
+sensors:
+  - name: Presence
+    type: CLIPPresence
+    modelid: PHA_STATE
+    manufacturername: huego
+    uniqueid: huego-presence
+    swversion: "1.0"
+groups:
+  - name: Living room
+    type: Room
+    class: Living room
+    lights: ["{Hue color lamp 1}", "{Hue white lamp 1}"]
+scenes:
+  - name: Relax
+    group: "{Living room}"
+    lightstates:
+      "{Hue color lamp 1}": {on: true, bri: 100}
+      "{Hue white lamp 1}": {on: false}
+schedules:
+  - name: Wake up
+    localtime: W124/T07:00:00
+    command: {address: "/groups/{Living room}/action", method: PUT, body: {scene: "{Relax}"}}
+rules:
+  - name: Welcome home
+    conditions:
+      - {address: "/sensors/{Presence}/state/presence", operator: eq, value: "true"}
+    actions:
+      - {address: "/groups/{Living room}/action", method: PUT, body: {scene: "{Relax}"}}
+`
+
+func TestApply(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	defer srv.Close()
+	b := srv.Bridge()
+	ctx := context.Background()
+
+	// Resources that aren't in the owner resourcelink are left alone
+	_, err := b.CreateGroup(huego.Group{Name: "Kitchen", Lights: []string{"3"}})
+	assert.Nil(t, err)
+
+	spec, err := huego.ReadSpec(strings.NewReader(officeSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := b.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `+ sensors "Presence"
++ groups "Living room"
++ scenes "Relax"
++ schedules "Wake up"
++ rules "Welcome home"
++ resourcelinks "office"
+`, plan.String())
+
+	err = b.Apply(ctx, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := b.GetGroup(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "Living room", g.Name)
+	assert.Equal(t, []string{"1", "2"}, g.Lights)
+
+	scenes, err := b.GetScenes()
+	assert.Nil(t, err)
+	assert.Len(t, scenes, 1)
+	sc, err := b.GetScene(scenes[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", sc.Group)
+	assert.Equal(t, uint8(100), sc.LightStates[1].Bri)
+
+	rules, err := b.GetRules()
+	assert.Nil(t, err)
+	assert.Equal(t, "/sensors/2/state/presence", rules[0].Conditions[0].Address)
+	assert.Equal(t, "/groups/2/action", rules[0].Actions[0].Address)
+	assert.Equal(t, map[string]interface{}{"scene": sc.ID}, rules[0].Actions[0].Body)
+
+	schedules, err := b.GetSchedules()
+	assert.Nil(t, err)
+	assert.Equal(t, "/api/"+huegotest.User+"/groups/2/action", schedules[0].Command.Address)
+
+	// Applying the same spec again changes nothing
+	plan, err = b.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "No changes\n", plan.String())
+
+	// Resources removed from the spec are deleted
+	spec.Rules = nil
+	spec.Groups[0].Lights = []string{"{Hue color lamp 1}"}
+	spec.Scenes[0].LightStates = map[string]huego.State{"{Hue color lamp 1}": {On: true, Bri: 100}}
+
+	plan, err = b.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `~ groups/2 "Living room": lights
+~ scenes/`+sc.ID+` "Relax": lightstates
+- rules/1 "Welcome home"
+~ resourcelinks/1 "office": links
+`, plan.String())
+
+	err = b.Apply(ctx, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err = b.GetRules()
+	assert.Nil(t, err)
+	assert.Empty(t, rules)
+
+	g, err = b.GetGroup(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1"}, g.Lights)
+
+	g, err = b.GetGroup(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Kitchen", g.Name)
+
+	plan, err = b.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, plan.Changes)
+}
+
+func TestApplyErrors(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	defer srv.Close()
+	b := srv.Bridge()
+	ctx := context.Background()
+
+	_, err := b.Plan(ctx, &huego.Spec{})
+	assert.EqualError(t, err, "spec has no owner")
+
+	_, err = b.Plan(ctx, &huego.Spec{Owner: "office", Groups: []*huego.Group{{Name: "A"}, {Name: "A"}}})
+	assert.EqualError(t, err, `groups "A" is defined more than once`)
+
+	plan, err := b.Plan(ctx, &huego.Spec{Owner: "office", Groups: []*huego.Group{{Name: "A", Lights: []string{"{Missing lamp}"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, b.Apply(ctx, plan), `+ groups "A": lights "Missing lamp" not found`)
+}
+
+func TestApplySchedule(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	defer srv.Close()
+	b := srv.Bridge()
+	ctx := context.Background()
+
+	// Adopted by the spec below, which leaves out the description
+	_, err := b.CreateSchedule(&huego.Schedule{
+		Name:        "Lights off",
+		Description: "Turns the lights off at night",
+		LocalTime:   "W127/T23:00:00",
+		Command:     &huego.Command{Address: "/api/" + huegotest.User + "/groups/0/action", Method: "PUT", Body: map[string]interface{}{"on": false}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := huego.ReadSpec(strings.NewReader(`
+owner: office
+schedules:
+  - name: Wake up
+    time: W124/T07:00:00
+    command: {address: "/groups/0/action", method: PUT, body: {on: true}}
+  - name: Lights off
+    localtime: W127/T22:00:00
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := b.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `+ schedules "Wake up"
+~ schedules/1 "Lights off": localtime
++ resourcelinks "office"
+`, plan.String())
+
+	err = b.Apply(ctx, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := b.GetSchedule(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Turns the lights off at night", s.Description)
+	assert.Equal(t, "W127/T22:00:00", s.LocalTime)
+
+	plan, err = b.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, plan.Changes)
+}
+
+func TestApplyPartial(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	defer srv.Close()
+	b := srv.Bridge()
+	ctx := context.Background()
+
+	spec, err := huego.ReadSpec(strings.NewReader(`
+owner: office
+groups:
+  - name: Living room
+    type: Room
+    lights: ["1"]
+rules:
+  - name: Welcome home
+    conditions:
+      - {address: "/sensors/{Missing}/state/presence", operator: eq, value: "true"}
+    actions:
+      - {address: "/groups/{Living room}/action", method: PUT, body: {on: true}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The group created before the rule failed is recorded in the owner resourcelink
+	plan, err := b.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.EqualError(t, b.Apply(ctx, plan), `+ rules "Welcome home": sensors "Missing" not found`)
+
+	spec.Rules = nil
+	plan, err = b.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "No changes\n", plan.String())
+
+	// Attributes that can only be set on creation aren't compared
+	spec.Groups[0].Type = "Zone"
+	plan, err = b.Plan(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, plan.Changes)
+}
@@ -0,0 +1,730 @@
+package huego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ArchiveVersion is the version of the archive format written by Export
+const ArchiveVersion = 1
+
+// Archive is a portable backup of the user-defined resources of a bridge created by Export. Lights and sensors
+// that are paired with the bridge can't be recreated, they are kept to find the same devices on another bridge
+// by their unique id. Resources are keyed by their id on the exported bridge.
+type Archive struct {
+	Version       int                       `json:"version"`
+	Created       time.Time                 `json:"created"`
+	BridgeID      string                    `json:"bridgeid,omitempty"`
+	Lights        map[string]*ArchiveDevice `json:"lights"`
+	Sensors       map[string]*Sensor        `json:"sensors"`
+	Groups        map[string]*Group         `json:"groups"`
+	Scenes        map[string]*Scene         `json:"scenes"`
+	Schedules     map[string]*Schedule      `json:"schedules"`
+	Rules         map[string]*Rule          `json:"rules"`
+	Resourcelinks map[string]*Resourcelink  `json:"resourcelinks"`
+}
+
+// ArchiveDevice identifies a light in an Archive
+type ArchiveDevice struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	ModelID  string `json:"modelid,omitempty"`
+	UniqueID string `json:"uniqueid,omitempty"`
+}
+
+// ImportOptions configures Import
+type ImportOptions struct {
+	// DryRun reports what would be imported without changing the bridge
+	DryRun bool
+}
+
+// ImportReport describes the outcome of Import
+type ImportReport struct {
+	// Mapping maps the address of every resource in the archive that was found or created on the bridge to its address
+	// on the bridge, for example /lights/1 to /lights/7. In a dry run, resources that would be created map to an empty string.
+	Mapping map[string]string
+	// Created lists the addresses, in the archive, of the resources that were created
+	Created []string
+	// Skipped lists the resources that were not imported. Lights and sensors that aren't paired with the bridge
+	// aren't listed, the resources that refer to them are.
+	Skipped []ImportIssue
+	// Warnings lists the resources that were imported without some of the lights or sensors they refer to
+	Warnings []ImportIssue
+}
+
+// ImportIssue describes why a resource in an archive was skipped or changed on import
+type ImportIssue struct {
+	Address string
+	Reason  string
+}
+
+// Export returns an archive of the groups, scenes, schedules, rules, sensors and resourcelinks of the bridge. Sensors
+// that are paired with the bridge are kept, like lights, to find the same devices on another bridge. Write the archive with WriteJSON or WriteYAML and read it back with ReadArchive.
+func (b *Bridge) Export(ctx context.Context) (*Archive, error) {
+
+	s, err := b.GetFullStateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{
+		Version:       ArchiveVersion,
+		Created:       time.Now().UTC().Truncate(time.Second),
+		Lights:        map[string]*ArchiveDevice{},
+		Sensors:       map[string]*Sensor{},
+		Groups:        map[string]*Group{},
+		Scenes:        map[string]*Scene{},
+		Schedules:     map[string]*Schedule{},
+		Rules:         map[string]*Rule{},
+		Resourcelinks: map[string]*Resourcelink{},
+	}
+
+	if s.Config != nil {
+		a.BridgeID = s.Config.BridgeID
+	}
+
+	for id, l := range s.Lights {
+		a.Lights[strconv.Itoa(id)] = &ArchiveDevice{Name: l.Name, Type: l.Type, ModelID: l.ModelID, UniqueID: l.UniqueID}
+	}
+	for id, sn := range s.Sensors {
+		a.Sensors[strconv.Itoa(id)] = sn
+	}
+	// Luminaire and LightSource groups are created by the bridge
+	for id, g := range s.Groups {
+		if creatableGroupTypes[g.Type] {
+			a.Groups[strconv.Itoa(id)] = g
+		}
+	}
+	for id, sc := range s.Schedules {
+		a.Schedules[strconv.Itoa(id)] = sc
+	}
+	for id, r := range s.Rules {
+		a.Rules[strconv.Itoa(id)] = r
+	}
+	for id, r := range s.Resourcelinks {
+		a.Resourcelinks[strconv.Itoa(id)] = r
+	}
+
+	// The full state doesn't include the light states of scenes
+	for id := range s.Scenes {
+		sc, err := b.GetSceneContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		a.Scenes[id] = sc
+	}
+
+	return a, nil
+}
+
+// WriteJSON writes the archive to w as indented JSON
+func (a *Archive) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteYAML writes the archive to w as YAML
+func (a *Archive) WriteYAML(w io.Writer) error {
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	// JSON is valid YAML, decoding it into a MapSlice keeps the order of the keys
+	var m yaml.MapSlice
+	err = yaml.Unmarshal(data, &m)
+	if err != nil {
+		return err
+	}
+
+	data, err = yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadArchive reads an archive written by WriteJSON or WriteYAML from r
+func ReadArchive(r io.Reader) (*Archive, error) {
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var v interface{}
+		err = yaml.Unmarshal(data, &v)
+		if err != nil {
+			return nil, err
+		}
+		data, err = json.Marshal(jsonValue(v))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var a Archive
+	err = json.Unmarshal(data, &a)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Version < 1 || a.Version > ArchiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", a.Version)
+	}
+
+	return &a, nil
+}
+
+// Import recreates the resources in archive a on the bridge. Lights and sensors are matched with the devices on the bridge
+// by their unique id and the daylight sensor by its type. References to lights, groups, sensors, scenes, schedules and rules in
+// groups, scenes, rule conditions and actions, schedule commands and resourcelinks are rewritten to their ids on the bridge.
+// Resources that refer to a light or sensor that isn't paired with the bridge are skipped, or imported without it where possible.
+// opts may be nil. Errors from the bridge while creating a resource are reported in the skipped resources of the report.
+func (b *Bridge) Import(ctx context.Context, a *Archive, opts *ImportOptions) (*ImportReport, error) {
+
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	if a.Version < 1 || a.Version > ArchiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", a.Version)
+	}
+
+	s, err := b.GetFullStateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	im := &importer{
+		bridge: b,
+		dryRun: opts.DryRun,
+		report: &ImportReport{Mapping: map[string]string{"/groups/0": "/groups/0"}},
+	}
+
+	im.mapLights(a, s)
+	im.mapSensors(a, s)
+
+	for _, id := range sortedKeys(a.Sensors) {
+		im.importSensor(ctx, id, a.Sensors[id])
+	}
+	for _, id := range sortedKeys(a.Groups) {
+		im.importGroup(ctx, id, a.Groups[id])
+	}
+	for _, id := range sortedKeys(a.Scenes) {
+		im.importScene(ctx, id, a.Scenes[id])
+	}
+	im.importAutomations(ctx, a)
+	for _, id := range sortedKeys(a.Resourcelinks) {
+		im.importResourcelink(ctx, id, a.Resourcelinks[id])
+	}
+
+	return im.report, nil
+}
+
+// Group types that can be created through the API
+var creatableGroupTypes = map[string]bool{
+	"LightGroup":    true,
+	"Room":          true,
+	"Zone":          true,
+	"Entertainment": true,
+}
+
+// Collections that hold resources with ids that differ between bridges
+var importedCollections = map[string]bool{
+	"lights":        true,
+	"groups":        true,
+	"sensors":       true,
+	"scenes":        true,
+	"schedules":     true,
+	"rules":         true,
+	"resourcelinks": true,
+}
+
+type importer struct {
+	bridge *Bridge
+	dryRun bool
+	report *ImportReport
+}
+
+func (im *importer) mapLights(a *Archive, s *FullState) {
+	unique := map[string]int{}
+	for id, l := range s.Lights {
+		if l.UniqueID != "" {
+			unique[l.UniqueID] = id
+		}
+	}
+	for _, id := range sortedKeys(a.Lights) {
+		if i, ok := unique[a.Lights[id].UniqueID]; ok && a.Lights[id].UniqueID != "" {
+			im.report.Mapping["/lights/"+id] = "/lights/" + strconv.Itoa(i)
+		}
+	}
+}
+
+// mapSensors maps paired sensors by unique id and the daylight sensor by type. CLIP sensors are mapped when
+// the bridge has a sensor with the same unique id and type, and created otherwise.
+func (im *importer) mapSensors(a *Archive, s *FullState) {
+	unique := map[string]int{}
+	daylight := 0
+	for id, sn := range s.Sensors {
+		if sn.UniqueID != "" {
+			unique[sn.Type+"/"+sn.UniqueID] = id
+		}
+		if sn.Type == "Daylight" {
+			daylight = id
+		}
+	}
+	for _, id := range sortedKeys(a.Sensors) {
+		sn := a.Sensors[id]
+		if i, ok := unique[sn.Type+"/"+sn.UniqueID]; ok && sn.UniqueID != "" {
+			im.report.Mapping["/sensors/"+id] = "/sensors/" + strconv.Itoa(i)
+		} else if sn.Type == "Daylight" && daylight != 0 {
+			im.report.Mapping["/sensors/"+id] = "/sensors/" + strconv.Itoa(daylight)
+		}
+	}
+}
+
+func (im *importer) importSensor(ctx context.Context, id string, sn *Sensor) {
+
+	address := "/sensors/" + id
+	if _, ok := im.report.Mapping[address]; ok {
+		return
+	}
+	if !strings.HasPrefix(sn.Type, "CLIP") {
+		return
+	}
+
+	c := *sn
+	c.ID = 0
+	c.State = map[string]interface{}{}
+	for k, v := range sn.State {
+		if k != "lastupdated" {
+			c.State[k] = v
+		}
+	}
+
+	im.create(address, func() (*Response, error) {
+		return im.bridge.CreateSensorContext(ctx, &c)
+	})
+}
+
+func (im *importer) importGroup(ctx context.Context, id string, g *Group) {
+
+	address := "/groups/" + id
+
+	lights, missing := im.resolveLights(g.Lights)
+	if len(lights) == 0 && len(g.Lights) > 0 {
+		im.skip(address, "none of the lights of the group are paired with the bridge")
+		return
+	}
+	if len(missing) > 0 {
+		im.warn(address, "lights "+strings.Join(missing, ", ")+" are not paired with the bridge")
+	}
+
+	c := Group{
+		Name:    g.Name,
+		Lights:  lights,
+		Type:    g.Type,
+		Class:   g.Class,
+		Recycle: g.Recycle,
+	}
+	if len(g.Locations) > 0 {
+		c.Locations = map[string][]float64{}
+		for l, loc := range g.Locations {
+			if n, ok := im.resolveID("lights", l); ok {
+				c.Locations[n] = loc
+			}
+		}
+	}
+
+	im.create(address, func() (*Response, error) {
+		return im.bridge.CreateGroupContext(ctx, c)
+	})
+}
+
+func (im *importer) importScene(ctx context.Context, id string, sc *Scene) {
+
+	address := "/scenes/" + id
+
+	c := Scene{
+		Name:           sc.Name,
+		Type:           sc.Type,
+		Recycle:        sc.Recycle,
+		AppData:        sc.AppData,
+		Picture:        sc.Picture,
+		TransitionTime: sc.TransitionTime,
+	}
+
+	if sc.Type == "GroupScene" {
+		group, ok := im.resolveID("groups", sc.Group)
+		if !ok {
+			im.skip(address, "group "+sc.Group+" was not imported")
+			return
+		}
+		c.Group = group
+	} else {
+		lights, missing := im.resolveLights(sc.Lights)
+		if len(lights) == 0 {
+			im.skip(address, "none of the lights of the scene are paired with the bridge")
+			return
+		}
+		if len(missing) > 0 {
+			im.warn(address, "lights "+strings.Join(missing, ", ")+" are not paired with the bridge")
+		}
+		c.Lights = lights
+	}
+
+	if len(sc.LightStates) > 0 {
+		c.LightStates = map[int]State{}
+		for l, st := range sc.LightStates {
+			n, ok := im.resolveID("lights", strconv.Itoa(l))
+			if !ok {
+				continue
+			}
+			i, _ := strconv.Atoi(n)
+			c.LightStates[i] = st
+		}
+	}
+
+	im.create(address, func() (*Response, error) {
+		return im.bridge.CreateSceneContext(ctx, &c)
+	})
+}
+
+// importAutomations imports the rules and schedules of a. They can refer to each other and rules to later rules,
+// so the ones that refer to a rule or schedule that is yet to be imported wait for a later pass. The ones left
+// when no more can be imported refer to each other in a cycle and are imported to report them as skipped.
+func (im *importer) importAutomations(ctx context.Context, a *Archive) {
+
+	var pending []string
+	for _, id := range sortedKeys(a.Rules) {
+		pending = append(pending, "/rules/"+id)
+	}
+	for _, id := range sortedKeys(a.Schedules) {
+		pending = append(pending, "/schedules/"+id)
+	}
+
+	remaining := map[string]bool{}
+	for _, address := range pending {
+		remaining[address] = true
+	}
+
+	for len(pending) > 0 {
+		var waiting []string
+		for _, address := range pending {
+			if im.waits(a, address, remaining) {
+				waiting = append(waiting, address)
+				continue
+			}
+			im.importAutomation(ctx, a, address)
+			delete(remaining, address)
+		}
+		if len(waiting) == len(pending) {
+			for _, address := range waiting {
+				im.importAutomation(ctx, a, address)
+			}
+			return
+		}
+		pending = waiting
+	}
+}
+
+func (im *importer) importAutomation(ctx context.Context, a *Archive, address string) {
+	id := address[strings.LastIndex(address, "/")+1:]
+	if strings.HasPrefix(address, "/rules/") {
+		im.importRule(ctx, id, a.Rules[id])
+	} else {
+		im.importSchedule(ctx, id, a.Schedules[id])
+	}
+}
+
+// waits reports whether the rule or schedule at address refers to another one in remaining
+func (im *importer) waits(a *Archive, address string, remaining map[string]bool) bool {
+
+	var refs []string
+	id := address[strings.LastIndex(address, "/")+1:]
+	if strings.HasPrefix(address, "/rules/") {
+		for _, cond := range a.Rules[id].Conditions {
+			refs = append(refs, cond.Address)
+		}
+		for _, action := range a.Rules[id].Actions {
+			refs = append(refs, action.Address)
+		}
+	} else if c := a.Schedules[id].Command; c != nil {
+		// Commands address the bridge as /api/<user>/<resource>
+		if p := strings.SplitN(strings.TrimPrefix(c.Address, "/api/"), "/", 2); len(p) == 2 {
+			refs = append(refs, "/"+p[1])
+		}
+	}
+
+	for _, ref := range refs {
+		p := strings.SplitN(strings.TrimPrefix(ref, "/"), "/", 3)
+		if len(p) < 2 {
+			continue
+		}
+		if r := "/" + p[0] + "/" + p[1]; r != address && remaining[r] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (im *importer) importSchedule(ctx context.Context, id string, s *Schedule) {
+
+	address := "/schedules/" + id
+
+	c := *s
+	c.ID = 0
+	c.StartTime = ""
+	if c.LocalTime != "" {
+		c.Time = ""
+	}
+
+	if s.Command != nil {
+		// Commands address the bridge as /api/<user>/<resource>
+		p := strings.SplitN(strings.TrimPrefix(s.Command.Address, "/api/"), "/", 2)
+		if len(p) != 2 {
+			im.skip(address, "invalid command address "+s.Command.Address)
+			return
+		}
+		target, ok := im.resolve("/" + p[1])
+		if !ok {
+			im.skip(address, "command address "+s.Command.Address+" refers to a resource that was not imported")
+			return
+		}
+		body, ok := im.resolveBody(s.Command.Body)
+		if !ok {
+			im.skip(address, "command body refers to a scene that was not imported")
+			return
+		}
+		c.Command = &Command{
+			Address: "/api/" + im.bridge.User + target,
+			Method:  s.Command.Method,
+			Body:    body,
+		}
+	}
+
+	im.create(address, func() (*Response, error) {
+		return im.bridge.CreateScheduleContext(ctx, &c)
+	})
+}
+
+func (im *importer) importRule(ctx context.Context, id string, r *Rule) {
+
+	address := "/rules/" + id
+
+	c := Rule{
+		Name:   r.Name,
+		Status: r.Status,
+	}
+
+	for _, cond := range r.Conditions {
+		target, ok := im.resolve(cond.Address)
+		if !ok {
+			im.skip(address, "condition address "+cond.Address+" refers to a resource that was not imported")
+			return
+		}
+		c.Conditions = append(c.Conditions, &Condition{Address: target, Operator: cond.Operator, Value: cond.Value})
+	}
+
+	for _, action := range r.Actions {
+		target, ok := im.resolve(action.Address)
+		if !ok {
+			im.skip(address, "action address "+action.Address+" refers to a resource that was not imported")
+			return
+		}
+		body, ok := im.resolveBody(action.Body)
+		if !ok {
+			im.skip(address, "action body refers to a scene that was not imported")
+			return
+		}
+		c.Actions = append(c.Actions, &RuleAction{Address: target, Method: action.Method, Body: body})
+	}
+
+	im.create(address, func() (*Response, error) {
+		return im.bridge.CreateRuleContext(ctx, &c)
+	})
+}
+
+func (im *importer) importResourcelink(ctx context.Context, id string, r *Resourcelink) {
+
+	address := "/resourcelinks/" + id
+
+	c := Resourcelink{
+		Name:        r.Name,
+		Description: r.Description,
+		ClassID:     r.ClassID,
+		Links:       []string{},
+	}
+
+	for _, l := range r.Links {
+		target, ok := im.resolve(l)
+		if !ok {
+			im.warn(address, "link "+l+" refers to a resource that was not imported")
+			continue
+		}
+		c.Links = append(c.Links, target)
+	}
+
+	im.create(address, func() (*Response, error) {
+		return im.bridge.CreateResourcelinkContext(ctx, &c)
+	})
+}
+
+// create creates the resource at address in the archive with fn and maps address to the id returned by the bridge
+func (im *importer) create(address string, fn func() (*Response, error)) {
+
+	if im.dryRun {
+		im.report.Mapping[address] = ""
+		im.report.Created = append(im.report.Created, address)
+		return
+	}
+
+	res, err := fn()
+	if err != nil {
+		im.skip(address, err.Error())
+		return
+	}
+
+	id, ok := res.Success["id"].(string)
+	if !ok {
+		im.skip(address, "no id was returned by the bridge")
+		return
+	}
+
+	im.report.Mapping[address] = address[:strings.LastIndex(address, "/")+1] + id
+	im.report.Created = append(im.report.Created, address)
+}
+
+func (im *importer) skip(address, reason string) {
+	im.report.Skipped = append(im.report.Skipped, ImportIssue{Address: address, Reason: reason})
+}
+
+func (im *importer) warn(address, reason string) {
+	im.report.Warnings = append(im.report.Warnings, ImportIssue{Address: address, Reason: reason})
+}
+
+// resolve rewrites an address such as /sensors/2/state/buttonevent to the ids on the bridge. Addresses of
+// resources that aren't imported, such as /config/localtime, are returned as is.
+func (im *importer) resolve(address string) (string, bool) {
+
+	p := strings.SplitN(strings.TrimPrefix(address, "/"), "/", 3)
+	if len(p) < 2 || !importedCollections[p[0]] {
+		return address, true
+	}
+
+	target, ok := im.report.Mapping["/"+p[0]+"/"+p[1]]
+	if !ok {
+		return "", false
+	}
+	if target == "" {
+		// Dry run
+		target = "/" + p[0] + "/" + p[1]
+	}
+
+	if len(p) == 3 {
+		target += "/" + p[2]
+	}
+
+	return target, true
+}
+
+// resolveID returns the id on the bridge of the resource with id in collection c. The id is empty in a dry run
+// for resources that would be created.
+func (im *importer) resolveID(c, id string) (string, bool) {
+	target, ok := im.report.Mapping["/"+c+"/"+id]
+	if !ok {
+		return "", false
+	}
+	if target == "" {
+		return id, true
+	}
+	return target[strings.LastIndex(target, "/")+1:], true
+}
+
+// resolveLights returns the ids on the bridge of the lights in ids and the ids that are not paired with the bridge
+func (im *importer) resolveLights(ids []string) ([]string, []string) {
+	var lights, missing []string
+	for _, id := range ids {
+		if n, ok := im.resolveID("lights", id); ok {
+			lights = append(lights, n)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return lights, missing
+}
+
+// resolveBody rewrites the scene in the body of a group action
+func (im *importer) resolveBody(body interface{}) (interface{}, bool) {
+
+	m, ok := body.(map[string]interface{})
+	if !ok {
+		return body, true
+	}
+
+	scene, ok := m["scene"].(string)
+	if !ok {
+		return body, true
+	}
+
+	id, ok := im.resolveID("scenes", scene)
+	if !ok {
+		return nil, false
+	}
+
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	c["scene"] = id
+
+	return c, true
+}
+
+// sortedKeys returns the keys of the map m in the order of their ids
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sortIDs(keys)
+	return keys
+}
+
+// jsonValue converts the maps decoded by yaml to maps that can be encoded as JSON
+func jsonValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range t {
+			t[i] = jsonValue(e)
+		}
+	}
+	return v
+}
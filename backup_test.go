@@ -0,0 +1,240 @@
+package huego_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/amimof/huego"
+	"github.com/amimof/huego/huegotest"
+	"github.com/stretchr/testify/assert"
+)
+
+const extraLight = "00:17:88:01:00:aa:bb:cc-0b"
+
+// newBackupSource returns a bridge with an extra light, a CLIP sensor and a group, scene, rule, schedule and resourcelink that refer to them
+func newBackupSource(t *testing.T) *huegotest.Server {
+
+	s := huegotest.NewServer()
+	b := s.Bridge()
+
+	s.AddLight(huego.Light{Name: "Hue white lamp 2", Type: "Dimmable light", ModelID: "LWB010", UniqueID: extraLight, State: &huego.State{Bri: 1}})
+
+	must := func(res *huego.Response, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Success["id"].(string)
+	}
+
+	sensor := must(b.CreateSensor(&huego.Sensor{Name: "Presence", Type: "CLIPPresence", ModelID: "PHA_STATE", ManufacturerName: "huego", UniqueID: "huego-presence", SwVersion: "1.0"}))
+	group := must(b.CreateGroup(huego.Group{Name: "Living room", Type: "Room", Class: "Living room", Lights: []string{"1", "4"}}))
+	scene := must(b.CreateScene(&huego.Scene{Name: "Relax", Type: "GroupScene", Group: group}))
+	must(b.CreateRule(&huego.Rule{
+		Name: "Welcome home",
+		Conditions: []*huego.Condition{
+			{Address: "/sensors/" + sensor + "/state/presence", Operator: "eq", Value: "true"},
+			{Address: "/sensors/1/state/daylight", Operator: "eq", Value: "false"},
+		},
+		Actions: []*huego.RuleAction{
+			{Address: "/groups/" + group + "/action", Method: "PUT", Body: map[string]interface{}{"scene": scene}},
+		},
+	}))
+	must(b.CreateSchedule(&huego.Schedule{
+		Name:      "Wake up",
+		LocalTime: "W124/T07:00:00",
+		Command:   &huego.Command{Address: "/api/" + huegotest.User + "/lights/4/state", Method: "PUT", Body: map[string]interface{}{"on": true}},
+	}))
+	must(b.CreateResourcelink(&huego.Resourcelink{Name: "Living room", ClassID: 1, Links: []string{"/groups/" + group, "/scenes/" + scene, "/rules/1"}}))
+
+	return s
+}
+
+func TestExportImport(t *testing.T) {
+
+	src := newBackupSource(t)
+	defer src.Close()
+
+	a, err := src.Bridge().Export(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, huego.ArchiveVersion, a.Version)
+	assert.Equal(t, "001788FFFE73FF19", a.BridgeID)
+	assert.Len(t, a.Lights, 4)
+	assert.Len(t, a.Groups, 1)
+	assert.Len(t, a.Scenes, 1)
+	for _, sc := range a.Scenes {
+		assert.Len(t, sc.LightStates, 2)
+	}
+
+	// The archive survives a round trip through YAML and JSON
+	var buf bytes.Buffer
+	assert.Nil(t, a.WriteYAML(&buf))
+	a, err = huego.ReadArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	assert.Nil(t, a.WriteJSON(&buf))
+	a, err = huego.ReadArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, extraLight, a.Lights["4"].UniqueID)
+
+	// The extra light, the group and the sensor get other ids on the new bridge
+	dst := huegotest.NewServer()
+	defer dst.Close()
+	b := dst.Bridge()
+
+	dst.AddLight(huego.Light{Name: "Other lamp", Type: "Dimmable light", UniqueID: "00:17:88:01:00:dd:ee:ff-0b", State: &huego.State{Bri: 1}})
+	dst.AddLight(huego.Light{Name: "Hue white lamp 2", Type: "Dimmable light", UniqueID: extraLight, State: &huego.State{Bri: 1}})
+	_, err = b.CreateGroup(huego.Group{Name: "Existing", Lights: []string{"1"}})
+	assert.Nil(t, err)
+	_, err = b.CreateSensor(&huego.Sensor{Name: "Switch", Type: "CLIPSwitch", ModelID: "PHA_STATE", ManufacturerName: "huego", UniqueID: "huego-switch", SwVersion: "1.0"})
+	assert.Nil(t, err)
+
+	report, err := b.Import(context.Background(), a, &huego.ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, report.Created, 6)
+	assert.Empty(t, report.Skipped)
+	rules, err := b.GetRules()
+	assert.Nil(t, err)
+	assert.Empty(t, rules)
+
+	report, err = b.Import(context.Background(), a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, report.Created, 6)
+	assert.Empty(t, report.Skipped)
+	assert.Empty(t, report.Warnings)
+	assert.Equal(t, "/lights/5", report.Mapping["/lights/4"])
+	assert.Equal(t, "/sensors/3", report.Mapping["/sensors/2"])
+	assert.Equal(t, "/groups/2", report.Mapping["/groups/1"])
+	assert.Equal(t, "/sensors/1", report.Mapping["/sensors/1"])
+
+	var oldScene string
+	for id := range a.Scenes {
+		oldScene = id
+	}
+	scene := report.Mapping["/scenes/"+oldScene][len("/scenes/"):]
+
+	g, err := b.GetGroup(2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "5"}, g.Lights)
+
+	sc, err := b.GetScene(scene)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", sc.Group)
+	assert.Contains(t, sc.LightStates, 5)
+
+	rules, err = b.GetRules()
+	assert.Nil(t, err)
+	assert.Len(t, rules, 1)
+	assert.Equal(t, "/sensors/3/state/presence", rules[0].Conditions[0].Address)
+	assert.Equal(t, "/sensors/1/state/daylight", rules[0].Conditions[1].Address)
+	assert.Equal(t, "/groups/2/action", rules[0].Actions[0].Address)
+	assert.Equal(t, map[string]interface{}{"scene": scene}, rules[0].Actions[0].Body)
+
+	schedules, err := b.GetSchedules()
+	assert.Nil(t, err)
+	assert.Len(t, schedules, 1)
+	assert.Equal(t, "/api/"+huegotest.User+"/lights/5/state", schedules[0].Command.Address)
+
+	links, err := b.GetResourcelinks()
+	assert.Nil(t, err)
+	assert.Len(t, links, 1)
+	assert.Equal(t, []string{"/groups/2", "/scenes/" + scene, report.Mapping["/rules/1"]}, links[0].Links)
+}
+
+func TestImportMissingLights(t *testing.T) {
+
+	src := newBackupSource(t)
+	defer src.Close()
+
+	a, err := src.Bridge().Export(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The extra light isn't paired with the new bridge
+	dst := huegotest.NewServer()
+	defer dst.Close()
+
+	report, err := dst.Bridge().Import(context.Background(), a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, report.Created, 5)
+	assert.Equal(t, []huego.ImportIssue{{Address: "/schedules/1", Reason: "command address /api/huegotest/lights/4/state refers to a resource that was not imported"}}, report.Skipped)
+	assert.Equal(t, []huego.ImportIssue{{Address: "/groups/1", Reason: "lights 4 are not paired with the bridge"}}, report.Warnings)
+
+	g, err := dst.Bridge().GetGroup(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1"}, g.Lights)
+
+	_, err = huego.ReadArchive(bytes.NewBufferString(`{"version":2}`))
+	assert.EqualError(t, err, "unsupported archive version 2")
+}
+
+func TestImportForwardReferences(t *testing.T) {
+
+	src := huegotest.NewServer()
+	defer src.Close()
+	b := src.Bridge()
+
+	// The first rule enables the second, which enables a schedule, and the first schedule disables the first rule
+	rules := []*huego.Rule{
+		{Name: "Enable night", Actions: []*huego.RuleAction{{Address: "/rules/2", Method: "PUT", Body: map[string]interface{}{"status": "enabled"}}}},
+		{Name: "Night", Actions: []*huego.RuleAction{{Address: "/schedules/2", Method: "PUT", Body: map[string]interface{}{"status": "enabled"}}}},
+	}
+	for _, r := range rules {
+		r.Conditions = []*huego.Condition{{Address: "/sensors/1/state/daylight", Operator: "eq", Value: "false"}}
+		_, err := b.CreateRule(r)
+		assert.Nil(t, err)
+	}
+	schedules := []*huego.Schedule{
+		{Name: "Disable", LocalTime: "W124/T07:00:00", Command: &huego.Command{Address: "/api/" + huegotest.User + "/rules/1", Method: "PUT", Body: map[string]interface{}{"status": "disabled"}}},
+		{Name: "Off", LocalTime: "W124/T23:00:00", Command: &huego.Command{Address: "/api/" + huegotest.User + "/groups/0/action", Method: "PUT", Body: map[string]interface{}{"on": false}}},
+	}
+	for _, s := range schedules {
+		_, err := b.CreateSchedule(s)
+		assert.Nil(t, err)
+	}
+
+	a, err := b.Export(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing resources make the ids differ on the new bridge
+	dst := huegotest.NewServer()
+	defer dst.Close()
+	_, err = dst.Bridge().CreateRule(&huego.Rule{Name: "Existing", Conditions: rules[0].Conditions, Actions: rules[1].Actions})
+	assert.Nil(t, err)
+	_, err = dst.Bridge().CreateSchedule(schedules[1])
+	assert.Nil(t, err)
+
+	report, err := dst.Bridge().Import(context.Background(), a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, report.Skipped)
+	assert.Equal(t, []string{"/schedules/2", "/rules/2", "/rules/1", "/schedules/1"}, report.Created)
+
+	r, err := dst.Bridge().GetRule(3)
+	assert.Nil(t, err)
+	assert.Equal(t, "Enable night", r.Name)
+	assert.Equal(t, "/rules/2", r.Actions[0].Address)
+	r, err = dst.Bridge().GetRule(2)
+	assert.Nil(t, err)
+	assert.Equal(t, "/schedules/2", r.Actions[0].Address)
+	s, err := dst.Bridge().GetSchedule(3)
+	assert.Nil(t, err)
+	assert.Equal(t, "/api/"+huegotest.User+"/rules/3", s.Command.Address)
+}
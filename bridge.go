@@ -10,6 +10,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Bridge exposes a hardware bridge through a struct.
@@ -39,6 +40,26 @@ func (b *Bridge) getAPIPath(str ...string) (string, error) {
 	return u.String(), nil
 }
 
+// getV2Path returns a url to a CLIP v2 endpoint on the bridge. The v2 API is only served over https,
+// so https is assumed when Host has no scheme. Unlike getAPIPath, the user is not part of the path.
+func (b *Bridge) getV2Path(str ...string) (string, error) {
+
+	host := b.Host
+	if strings.Index(strings.ToLower(host), "http://") <= -1 && strings.Index(strings.ToLower(host), "https://") <= -1 {
+		host = fmt.Sprintf("%s%s", "https://", host)
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range str {
+		u.Path = path.Join(u.Path, p)
+	}
+	return u.String(), nil
+}
+
 // Login calls New() and passes Host on this Bridge instance.
 func (b *Bridge) Login(u string) *Bridge {
 	b.User = u
@@ -151,9 +172,14 @@ func (b *Bridge) createUserWithContext(ctx context.Context, deviceType string, g
 		return nil, err
 	}
 
+	username, ok := resp.Success["username"].(string)
+	if !ok {
+		return nil, errors.New("no username was returned by the bridge")
+	}
+
 	wl := Whitelist{
 		Name:     deviceType,
-		Username: resp.Success["username"].(string),
+		Username: username,
 	}
 
 	if ck, ok := resp.Success["clientkey"]; ok {
@@ -165,6 +191,58 @@ func (b *Bridge) createUserWithContext(ctx context.Context, deviceType string, g
 	return &wl, nil
 }
 
+// PairOptions configures how Pair waits for the link button
+type PairOptions struct {
+	// Interval is the time between attempts to create the user. Defaults to 1 second
+	Interval time.Duration
+	// GenerateClientKey requests a client key for the entertainment API
+	GenerateClientKey bool
+	// Progress is called after each attempt that failed because the link button hasn't been pressed
+	Progress func(attempt int)
+}
+
+// Pair creates a user by adding deviceType to the list of whitelisted users on the bridge. Unlike CreateUser, Pair keeps
+// trying until the link button on the bridge is pressed or ctx is done. opts may be nil. Pair returns a copy of the bridge
+// logged in as the new user, and the whitelist entry of the user that holds the client key if one was requested.
+func (b *Bridge) Pair(ctx context.Context, deviceType string, opts *PairOptions) (*Bridge, *Whitelist, error) {
+
+	if opts == nil {
+		opts = &PairOptions{}
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for attempt := 1; ; attempt++ {
+
+		wl, err := b.createUserWithContext(ctx, deviceType, opts.GenerateClientKey)
+		if err == nil {
+			paired := *b
+			paired.User = wl.Username
+			return &paired, wl, nil
+		}
+
+		if !errors.Is(err, ErrLinkButtonNotPressed) {
+			return nil, nil, err
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(attempt)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("link button not pressed after %d attempts: %w", attempt, ctx.Err())
+		}
+	}
+}
+
 // GetUsers returns a list of whitelists from the bridge
 func (b *Bridge) GetUsers() ([]Whitelist, error) {
 	c, err := b.GetConfig()
@@ -243,15 +321,15 @@ func (b *Bridge) DeleteUserContext(ctx context.Context, n string) error {
 
 }
 
-// GetFullState returns the entire bridge configuration.
-func (b *Bridge) GetFullState() (map[string]interface{}, error) {
+// GetFullState returns the entire bridge configuration in one request.
+func (b *Bridge) GetFullState() (*FullState, error) {
 	return b.GetFullStateContext(context.Background())
 }
 
-// GetFullStateContext returns the entire bridge configuration.
-func (b *Bridge) GetFullStateContext(ctx context.Context) (map[string]interface{}, error) {
+// GetFullStateContext returns the entire bridge configuration in one request.
+func (b *Bridge) GetFullStateContext(ctx context.Context) (*FullState, error) {
 
-	var n map[string]interface{}
+	var n FullState
 
 	target, err := b.getAPIPath("/")
 	if err != nil {
@@ -268,7 +346,9 @@ func (b *Bridge) GetFullStateContext(ctx context.Context) (map[string]interface{
 		return nil, err
 	}
 
-	return n, nil
+	n.init(b)
+
+	return &n, nil
 }
 
 /*
@@ -350,12 +430,13 @@ func (b *Bridge) GetGroupContext(ctx context.Context, i int) (*Group, error) {
 }
 
 // SetGroupState allows for setting the state of one group, controlling the state of all lights in that group.
-func (b *Bridge) SetGroupState(i int, l State) (*Response, error) {
+// l is either a State or a *StateUpdate.
+func (b *Bridge) SetGroupState(i int, l StateChange) (*Response, error) {
 	return b.SetGroupStateContext(context.Background(), i, l)
 }
 
 // SetGroupStateContext allows for setting the state of one group, controlling the state of all lights in that group.
-func (b *Bridge) SetGroupStateContext(ctx context.Context, i int, l State) (*Response, error) {
+func (b *Bridge) SetGroupStateContext(ctx context.Context, i int, l StateChange) (*Response, error) {
 
 	var a []*APIResponse
 
@@ -365,7 +446,7 @@ func (b *Bridge) SetGroupStateContext(ctx context.Context, i int, l State) (*Res
 		return nil, err
 	}
 
-	data, err := json.Marshal(&l)
+	data, err := stateBody(l)
 	if err != nil {
 		return nil, err
 	}
@@ -607,20 +688,17 @@ func (b *Bridge) IdentifyLightContext(ctx context.Context, i int) (*Response, er
 
 }
 
-// SetLightState allows for controlling one light's state
-func (b *Bridge) SetLightState(i int, l State) (*Response, error) {
+// SetLightState allows for controlling one light's state. l is either a State or a *StateUpdate.
+func (b *Bridge) SetLightState(i int, l StateChange) (*Response, error) {
 	return b.SetLightStateContext(context.Background(), i, l)
 }
 
 // SetLightStateContext allows for controlling one light's state
-func (b *Bridge) SetLightStateContext(ctx context.Context, i int, l State) (*Response, error) {
+func (b *Bridge) SetLightStateContext(ctx context.Context, i int, l StateChange) (*Response, error) {
 
 	var a []*APIResponse
 
-	l.Reachable = false
-	l.ColorMode = ""
-
-	data, err := json.Marshal(&l)
+	data, err := stateBody(l)
 	if err != nil {
 		return nil, err
 	}
@@ -1282,14 +1360,14 @@ func (b *Bridge) UpdateSceneContext(ctx context.Context, id string, s *Scene) (*
 }
 
 // SetSceneLightState allows for setting the state of a light in a scene.
-// SetSceneLightState accepts the id of the scene, the id of a light associated with the scene and the state object.
-func (b *Bridge) SetSceneLightState(id string, iid int, l *State) (*Response, error) {
+// SetSceneLightState accepts the id of the scene, the id of a light associated with the scene and the state, either a State or a *StateUpdate.
+func (b *Bridge) SetSceneLightState(id string, iid int, l StateChange) (*Response, error) {
 	return b.SetSceneLightStateContext(context.Background(), id, iid, l)
 }
 
 // SetSceneLightStateContext allows for setting the state of a light in a scene.
-// SetSceneLightStateContext accepts the id of the scene, the id of a light associated with the scene and the state object.
-func (b *Bridge) SetSceneLightStateContext(ctx context.Context, id string, iid int, l *State) (*Response, error) {
+// SetSceneLightStateContext accepts the id of the scene, the id of a light associated with the scene and the state, either a State or a *StateUpdate.
+func (b *Bridge) SetSceneLightStateContext(ctx context.Context, id string, iid int, l StateChange) (*Response, error) {
 
 	var a []*APIResponse
 
@@ -1299,7 +1377,7 @@ func (b *Bridge) SetSceneLightStateContext(ctx context.Context, id string, iid i
 		return nil, err
 	}
 
-	data, err := json.Marshal(&l)
+	data, err := stateBody(l)
 	if err != nil {
 		return nil, err
 	}
@@ -1654,6 +1732,7 @@ func (b *Bridge) GetSensorsContext(ctx context.Context) ([]Sensor, error) {
 		if err != nil {
 			return nil, err
 		}
+		k.bridge = b
 		sensors = append(sensors, k)
 	}
 	return sensors, err
@@ -1687,6 +1766,8 @@ func (b *Bridge) GetSensorContext(ctx context.Context, i int) (*Sensor, error) {
 		return r, err
 	}
 
+	r.bridge = b
+
 	return r, err
 
 }
@@ -1798,6 +1879,7 @@ func (b *Bridge) GetNewSensorsContext(ctx context.Context) (*NewSensor, error) {
 				return nil, err
 			}
 			lCopy := l
+			lCopy.bridge = b
 			sensors = append(sensors, &lCopy)
 		}
 	}
@@ -1919,6 +2001,43 @@ func (b *Bridge) UpdateSensorConfigContext(ctx context.Context, i int, c interfa
 	return resp, nil
 }
 
+// UpdateSensorState updates the state of one sensor. Only the state of CLIP sensors can be updated
+func (b *Bridge) UpdateSensorState(i int, s interface{}) (*Response, error) {
+	return b.UpdateSensorStateContext(context.Background(), i, s)
+}
+
+// UpdateSensorStateContext updates the state of one sensor. Only the state of CLIP sensors can be updated
+func (b *Bridge) UpdateSensorStateContext(ctx context.Context, i int, s interface{}) (*Response, error) {
+	var a []*APIResponse
+
+	data, err := json.Marshal(&s)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := b.getAPIPath("/sensors/", strconv.Itoa(i), "/state")
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := put(ctx, target, data, b.client)
+	if err != nil {
+		return nil, err
+	}
+
+	err = unmarshal(res, &a)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := handleResponse(a)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
 /*
 
 	CAPABILITIES API
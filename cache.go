@@ -0,0 +1,755 @@
+package huego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collections of resources in the v1 API, used by Cache, Plan and Fleet addresses. A Cache holds lights, groups,
+// sensors, scenes and config. The resource types of the v2 API are the Resource constants.
+const (
+	CollectionLights        = "lights"
+	CollectionGroups        = "groups"
+	CollectionSensors       = "sensors"
+	CollectionScenes        = "scenes"
+	CollectionSchedules     = "schedules"
+	CollectionRules         = "rules"
+	CollectionResourcelinks = "resourcelinks"
+	CollectionConfig        = "config"
+)
+
+// CacheEvent describes a change to a resource in a Cache
+type CacheEvent struct {
+	// Type is one of EventAdd, EventUpdate or EventDelete
+	Type string
+	// Resource is one of the Collection constants
+	Resource string
+	// ID is the id of the resource, empty for config
+	ID string
+}
+
+// Cache holds the lights, groups, sensors, scenes and config of a bridge in memory so that reads don't require a
+// round trip. The cache is loaded with Refresh and kept up to date with Poll or Watch. Writes made through the
+// bridge the cache was created with invalidate the resources they change, which are read again on the next read.
+// Schedules, rules and resourcelinks aren't cached, read them through the bridge.
+type Cache struct {
+	// OnError is called with the errors of the refreshes of Poll and the reloads of Watch, which keep running
+	// after an error. Resources that Watch failed to reload are read again on the next read. Set it before Poll or Watch.
+	OnError func(error)
+
+	bridge *Bridge
+
+	mu        sync.RWMutex
+	loaded    bool
+	resources map[string]map[string]interface{}
+	stale     map[string]bool
+	listeners map[chan *CacheEvent]bool
+}
+
+// NewCache returns an empty cache of bridge b. The http client of b is replaced with a copy that invalidates
+// the cache on writes made through b. A bridge has one cache, a cache made earlier for b is no longer
+// invalidated by writes once NewCache is called again.
+func NewCache(b *Bridge) *Cache {
+
+	c := &Cache{
+		bridge:    b,
+		resources: map[string]map[string]interface{}{},
+		stale:     map[string]bool{},
+		listeners: map[chan *CacheEvent]bool{},
+	}
+
+	b.replaceLayer(func(l layer) bool {
+		_, ok := l.(*cacheTransport)
+		return ok
+	}, &cacheTransport{cache: c})
+
+	return c
+}
+
+// Refresh loads all resources from the bridge in one request and notifies subscribers of the changes
+func (c *Cache) Refresh() error {
+	return c.RefreshContext(context.Background())
+}
+
+// RefreshContext loads all resources from the bridge in one request and notifies subscribers of the changes
+func (c *Cache) RefreshContext(ctx context.Context) error {
+
+	s, err := c.bridge.GetFullStateContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	resources := map[string]map[string]interface{}{
+		CollectionLights:  {},
+		CollectionGroups:  {},
+		CollectionSensors: {},
+		CollectionScenes:  {},
+		CollectionConfig:  {},
+	}
+
+	for id, l := range s.Lights {
+		resources[CollectionLights][strconv.Itoa(id)] = *l
+	}
+	for id, g := range s.Groups {
+		resources[CollectionGroups][strconv.Itoa(id)] = *g
+	}
+	for id, sn := range s.Sensors {
+		resources[CollectionSensors][strconv.Itoa(id)] = *sn
+	}
+	for id, sc := range s.Scenes {
+		resources[CollectionScenes][id] = *sc
+	}
+	if s.Config != nil {
+		resources[CollectionConfig][""] = *s.Config
+	}
+
+	c.mu.Lock()
+	var events []*CacheEvent
+	for _, r := range []string{CollectionLights, CollectionGroups, CollectionSensors, CollectionScenes, CollectionConfig} {
+		events = append(events, diff(r, c.resources[r], resources[r])...)
+	}
+	c.resources = resources
+	c.stale = map[string]bool{}
+	c.loaded = true
+	c.mu.Unlock()
+
+	c.publish(events)
+
+	return nil
+}
+
+// Poll refreshes the cache every interval until ctx is done. Failed refreshes are reported to OnError and retried on the next interval.
+func (c *Cache) Poll(ctx context.Context, interval time.Duration) error {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.RefreshContext(ctx); err != nil && ctx.Err() == nil {
+			c.reportError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Watch keeps the cache up to date with the bridge event stream until ctx is done or the bridge refuses the stream,
+// in which case the error is returned. Resources are read again through the v1 API when an event refers to them.
+// Watch requires a bridge that serves the CLIP v2 API, see Bridge.Events.
+func (c *Cache) Watch(ctx context.Context) error {
+
+	events, err := c.bridge.Events(ctx)
+	if err != nil {
+		return err
+	}
+
+	for e := range events {
+		if e.Err != nil {
+			return e.Err
+		}
+		p := strings.Split(strings.Trim(e.IDV1, "/"), "/")
+		if len(p) != 2 {
+			continue
+		}
+		if err := c.reload(ctx, p[0], p[1]); err != nil && ctx.Err() == nil {
+			c.invalidate(p[0], p[1])
+			c.reportError(err)
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (c *Cache) reportError(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// Subscribe returns a channel on which changes to the cache are delivered. Events are dropped if the
+// channel isn't read fast enough. Call Unsubscribe to close the channel.
+func (c *Cache) Subscribe() <-chan *CacheEvent {
+	ch := make(chan *CacheEvent, 64)
+	c.mu.Lock()
+	c.listeners[ch] = true
+	c.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe stops delivering events on ch and closes it
+func (c *Cache) Unsubscribe(ch <-chan *CacheEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for l := range c.listeners {
+		if l == ch {
+			delete(c.listeners, l)
+			close(l)
+		}
+	}
+}
+
+// GetLights returns all lights from the cache
+func (c *Cache) GetLights() ([]Light, error) {
+	return c.GetLightsContext(context.Background())
+}
+
+// GetLightsContext returns all lights from the cache
+func (c *Cache) GetLightsContext(ctx context.Context) ([]Light, error) {
+	all, err := c.all(ctx, CollectionLights)
+	if err != nil {
+		return nil, err
+	}
+	lights := make([]Light, len(all))
+	for i, v := range all {
+		err = c.copy(v, &lights[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return lights, nil
+}
+
+// GetLight returns one light by its id of i from the cache
+func (c *Cache) GetLight(i int) (*Light, error) {
+	return c.GetLightContext(context.Background(), i)
+}
+
+// GetLightContext returns one light by its id of i from the cache
+func (c *Cache) GetLightContext(ctx context.Context, i int) (*Light, error) {
+	v, err := c.one(ctx, CollectionLights, strconv.Itoa(i))
+	if err != nil {
+		return nil, err
+	}
+	l := &Light{}
+	err = c.copy(v, l)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// GetGroups returns all groups from the cache
+func (c *Cache) GetGroups() ([]Group, error) {
+	return c.GetGroupsContext(context.Background())
+}
+
+// GetGroupsContext returns all groups from the cache
+func (c *Cache) GetGroupsContext(ctx context.Context) ([]Group, error) {
+	all, err := c.all(ctx, CollectionGroups)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]Group, len(all))
+	for i, v := range all {
+		err = c.copy(v, &groups[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// GetGroup returns one group by its id of i from the cache
+func (c *Cache) GetGroup(i int) (*Group, error) {
+	return c.GetGroupContext(context.Background(), i)
+}
+
+// GetGroupContext returns one group by its id of i from the cache
+func (c *Cache) GetGroupContext(ctx context.Context, i int) (*Group, error) {
+	v, err := c.one(ctx, CollectionGroups, strconv.Itoa(i))
+	if err != nil {
+		return nil, err
+	}
+	g := &Group{}
+	err = c.copy(v, g)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// GetSensors returns all sensors from the cache
+func (c *Cache) GetSensors() ([]Sensor, error) {
+	return c.GetSensorsContext(context.Background())
+}
+
+// GetSensorsContext returns all sensors from the cache
+func (c *Cache) GetSensorsContext(ctx context.Context) ([]Sensor, error) {
+	all, err := c.all(ctx, CollectionSensors)
+	if err != nil {
+		return nil, err
+	}
+	sensors := make([]Sensor, len(all))
+	for i, v := range all {
+		err = c.copy(v, &sensors[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sensors, nil
+}
+
+// GetSensor returns one sensor by its id of i from the cache
+func (c *Cache) GetSensor(i int) (*Sensor, error) {
+	return c.GetSensorContext(context.Background(), i)
+}
+
+// GetSensorContext returns one sensor by its id of i from the cache
+func (c *Cache) GetSensorContext(ctx context.Context, i int) (*Sensor, error) {
+	v, err := c.one(ctx, CollectionSensors, strconv.Itoa(i))
+	if err != nil {
+		return nil, err
+	}
+	s := &Sensor{}
+	err = c.copy(v, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetScenes returns all scenes from the cache
+func (c *Cache) GetScenes() ([]Scene, error) {
+	return c.GetScenesContext(context.Background())
+}
+
+// GetScenesContext returns all scenes from the cache
+func (c *Cache) GetScenesContext(ctx context.Context) ([]Scene, error) {
+	all, err := c.all(ctx, CollectionScenes)
+	if err != nil {
+		return nil, err
+	}
+	scenes := make([]Scene, len(all))
+	for i, v := range all {
+		err = c.copy(v, &scenes[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return scenes, nil
+}
+
+// GetScene returns one scene by its id of i from the cache
+func (c *Cache) GetScene(i string) (*Scene, error) {
+	return c.GetSceneContext(context.Background(), i)
+}
+
+// GetSceneContext returns one scene by its id of i from the cache
+func (c *Cache) GetSceneContext(ctx context.Context, i string) (*Scene, error) {
+	v, err := c.one(ctx, CollectionScenes, i)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scene{}
+	err = c.copy(v, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetConfig returns the bridge configuration from the cache
+func (c *Cache) GetConfig() (*Config, error) {
+	return c.GetConfigContext(context.Background())
+}
+
+// GetConfigContext returns the bridge configuration from the cache
+func (c *Cache) GetConfigContext(ctx context.Context) (*Config, error) {
+	v, err := c.one(ctx, CollectionConfig, "")
+	if err != nil {
+		return nil, err
+	}
+	conf := &Config{}
+	err = c.copy(v, conf)
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// all returns all resources of a kind ordered by id, after reading them again if some are stale
+func (c *Cache) all(ctx context.Context, resource string) ([]interface{}, error) {
+
+	c.mu.RLock()
+	loaded := c.loaded
+	stale := false
+	for k := range c.stale {
+		if k == resource || strings.HasPrefix(k, resource+"/") {
+			stale = true
+		}
+	}
+	c.mu.RUnlock()
+
+	var err error
+	switch {
+	case !loaded:
+		err = c.RefreshContext(ctx)
+	case stale:
+		err = c.reload(ctx, resource, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]string, 0, len(c.resources[resource]))
+	for id := range c.resources[resource] {
+		ids = append(ids, id)
+	}
+	sortIDs(ids)
+
+	all := make([]interface{}, len(ids))
+	for i, id := range ids {
+		all[i] = c.resources[resource][id]
+	}
+	return all, nil
+}
+
+// one returns one resource, after reading it again if it is stale
+func (c *Cache) one(ctx context.Context, resource, id string) (interface{}, error) {
+
+	c.mu.RLock()
+	loaded := c.loaded
+	staleAll := c.stale[resource]
+	stale := c.stale[resource+"/"+id]
+	c.mu.RUnlock()
+
+	var err error
+	switch {
+	case !loaded:
+		err = c.RefreshContext(ctx)
+	case staleAll:
+		err = c.reload(ctx, resource, "")
+	case stale:
+		err = c.reload(ctx, resource, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	v, ok := c.resources[resource][id]
+	if !ok {
+		return nil, &APIError{Type: ErrorTypeResourceNotAvailable, Address: "/" + resource + "/" + id, Description: "resource, /" + resource + "/" + id + ", not available"}
+	}
+	return v, nil
+}
+
+// invalidate marks a resource as stale, all resources of its kind if id is empty. Changing the state of a light
+// changes the state of its groups and a group action changes the state of its lights, so those are invalidated as well.
+func (c *Cache) invalidate(resource, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id == "" {
+		c.stale[resource] = true
+	} else {
+		c.stale[resource+"/"+id] = true
+	}
+	switch resource {
+	case CollectionLights:
+		c.stale[CollectionGroups] = true
+	case CollectionGroups:
+		c.stale[CollectionLights] = true
+	}
+}
+
+// reload reads one resource from the bridge, or all resources of its kind if id is empty, and notifies subscribers of the changes
+func (c *Cache) reload(ctx context.Context, resource, id string) error {
+
+	next := map[string]interface{}{}
+
+	if id == "" || resource == CollectionConfig {
+		all, err := c.fetchAll(ctx, resource)
+		if err != nil {
+			return err
+		}
+		for _, v := range all {
+			next[resourceID(v)] = v
+		}
+	} else {
+		v, err := c.fetch(ctx, resource, id)
+		if err != nil && !errors.Is(err, ErrResourceNotAvailable) {
+			return err
+		}
+		if err == nil {
+			next[id] = v
+		}
+	}
+
+	c.mu.Lock()
+	current := c.resources[resource]
+	if current == nil {
+		current = map[string]interface{}{}
+		c.resources[resource] = current
+	}
+	var events []*CacheEvent
+	if id == "" || resource == CollectionConfig {
+		events = diff(resource, current, next)
+		c.resources[resource] = next
+		for k := range c.stale {
+			if k == resource || strings.HasPrefix(k, resource+"/") {
+				delete(c.stale, k)
+			}
+		}
+	} else {
+		prev := map[string]interface{}{}
+		if v, ok := current[id]; ok {
+			prev[id] = v
+		}
+		events = diff(resource, prev, next)
+		if v, ok := next[id]; ok {
+			current[id] = v
+		} else {
+			delete(current, id)
+		}
+		delete(c.stale, resource+"/"+id)
+	}
+	c.mu.Unlock()
+
+	c.publish(events)
+
+	return nil
+}
+
+// fetchAll reads all resources of a kind from the bridge
+func (c *Cache) fetchAll(ctx context.Context, resource string) ([]interface{}, error) {
+
+	b := c.bridge
+	var all []interface{}
+
+	switch resource {
+	case CollectionLights:
+		lights, err := b.GetLightsContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range lights {
+			all = append(all, l)
+		}
+	case CollectionGroups:
+		groups, err := b.GetGroupsContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range groups {
+			all = append(all, g)
+		}
+	case CollectionSensors:
+		sensors, err := b.GetSensorsContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sensors {
+			all = append(all, s)
+		}
+	case CollectionScenes:
+		scenes, err := b.GetScenesContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range scenes {
+			all = append(all, s)
+		}
+	case CollectionConfig:
+		conf, err := b.GetConfigContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, *conf)
+	}
+
+	return all, nil
+}
+
+// fetch reads one resource from the bridge
+func (c *Cache) fetch(ctx context.Context, resource, id string) (interface{}, error) {
+
+	b := c.bridge
+
+	if resource == CollectionScenes {
+		s, err := b.GetSceneContext(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return *s, nil
+	}
+
+	i, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resource {
+	case CollectionLights:
+		l, err := b.GetLightContext(ctx, i)
+		if err != nil {
+			return nil, err
+		}
+		return *l, nil
+	case CollectionGroups:
+		g, err := b.GetGroupContext(ctx, i)
+		if err != nil {
+			return nil, err
+		}
+		return *g, nil
+	case CollectionSensors:
+		s, err := b.GetSensorContext(ctx, i)
+		if err != nil {
+			return nil, err
+		}
+		return *s, nil
+	}
+
+	return nil, ErrResourceNotAvailable
+}
+
+// copy copies the cached resource v into dst so that callers can't modify the cache through pointers, slices or maps of v
+func (c *Cache) copy(v, dst interface{}) error {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(data, dst)
+	if err != nil {
+		return err
+	}
+
+	switch r := dst.(type) {
+	case *Light:
+		r.ID, r.bridge = v.(Light).ID, c.bridge
+	case *Group:
+		r.ID, r.bridge = v.(Group).ID, c.bridge
+	case *Sensor:
+		r.ID, r.bridge = v.(Sensor).ID, c.bridge
+	case *Scene:
+		r.ID, r.bridge = v.(Scene).ID, c.bridge
+	}
+
+	return nil
+}
+
+func (c *Cache) publish(events []*CacheEvent) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, e := range events {
+		for l := range c.listeners {
+			select {
+			case l <- e:
+			default:
+			}
+		}
+	}
+}
+
+// diff returns the events that turn current into next
+func diff(resource string, current, next map[string]interface{}) []*CacheEvent {
+	var events []*CacheEvent
+	for id, v := range next {
+		old, ok := current[id]
+		switch {
+		case !ok:
+			events = append(events, &CacheEvent{Type: EventAdd, Resource: resource, ID: id})
+		case !reflect.DeepEqual(old, v):
+			events = append(events, &CacheEvent{Type: EventUpdate, Resource: resource, ID: id})
+		}
+	}
+	for id := range current {
+		if _, ok := next[id]; !ok {
+			events = append(events, &CacheEvent{Type: EventDelete, Resource: resource, ID: id})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return lessID(events[i].ID, events[j].ID)
+	})
+	return events
+}
+
+func resourceID(v interface{}) string {
+	switch r := v.(type) {
+	case Light:
+		return strconv.Itoa(r.ID)
+	case Group:
+		return strconv.Itoa(r.ID)
+	case Sensor:
+		return strconv.Itoa(r.ID)
+	case Scene:
+		return r.ID
+	}
+	return ""
+}
+
+// sortIDs sorts numeric ids by value and other ids alphabetically
+func sortIDs(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		return lessID(ids[i], ids[j])
+	})
+}
+
+func lessID(a, b string) bool {
+	ai, aerr := strconv.Atoi(a)
+	bi, berr := strconv.Atoi(b)
+	if aerr == nil && berr == nil {
+		return ai < bi
+	}
+	return a < b
+}
+
+// cacheTransport invalidates the resources changed by successful writes
+type cacheTransport struct {
+	cache *Cache
+	next  http.RoundTripper
+}
+
+func (t *cacheTransport) unwrap() http.RoundTripper {
+	return t.next
+}
+
+func (t *cacheTransport) wrap(next http.RoundTripper) layer {
+	return &cacheTransport{cache: t.cache, next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	res, err := next.RoundTrip(req)
+	if err != nil || req.Method == http.MethodGet || res.StatusCode >= 300 {
+		return res, err
+	}
+
+	// /api/<user>/<resource>/<id>/...
+	api := path.Join("/api", t.cache.bridge.User)
+	if !strings.HasPrefix(req.URL.Path, api+"/") {
+		return res, err
+	}
+	p := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, api), "/"), "/")
+	resource, id := p[0], ""
+	if len(p) > 1 && req.Method != http.MethodPost && resource != CollectionConfig {
+		id = p[1]
+	}
+	t.cache.invalidate(resource, id)
+
+	return res, err
+}
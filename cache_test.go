@@ -0,0 +1,269 @@
+package huego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cacheServer serves a bridge with two lights and one group
+type cacheServer struct {
+	*recorder
+	mu sync.Mutex
+	on map[string]bool
+}
+
+func newCacheServer() *cacheServer {
+	s := &cacheServer{on: map[string]bool{"1": false, "2": true}}
+	s.recorder = newRecorder(s.serve)
+	return s
+}
+
+func (s *cacheServer) light(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":  "Light " + id,
+		"type":  "Extended color light",
+		"state": map[string]interface{}{"on": s.on[id], "bri": 254, "reachable": true},
+	}
+}
+
+func (s *cacheServer) lights() map[string]interface{} {
+	lights := map[string]interface{}{}
+	for id := range s.on {
+		lights[id] = s.light(id)
+	}
+	return lights
+}
+
+func (s *cacheServer) groups() map[string]interface{} {
+	anyOn := false
+	for _, on := range s.on {
+		anyOn = anyOn || on
+	}
+	return map[string]interface{}{
+		"1": map[string]interface{}{
+			"name":   "Living room",
+			"lights": []string{"1", "2"},
+			"type":   "Room",
+			"state":  map[string]interface{}{"any_on": anyOn},
+		},
+	}
+}
+
+func (s *cacheServer) serve(w http.ResponseWriter, r *http.Request, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := apiPath(r)
+
+	var v interface{}
+	switch {
+	case r.Method == http.MethodGet && p == "/":
+		v = map[string]interface{}{
+			"lights":  s.lights(),
+			"groups":  s.groups(),
+			"sensors": map[string]interface{}{},
+			"scenes":  map[string]interface{}{},
+			"config":  map[string]interface{}{"name": "Philips hue"},
+		}
+	case r.Method == http.MethodGet && p == "/lights":
+		v = s.lights()
+	case r.Method == http.MethodGet && p == "/groups":
+		v = s.groups()
+	case r.Method == http.MethodGet && strings.HasPrefix(p, "/lights/"):
+		id := strings.TrimPrefix(p, "/lights/")
+		if _, ok := s.on[id]; !ok {
+			v = []map[string]interface{}{{"error": map[string]interface{}{"type": 3, "address": p, "description": "resource, " + p + ", not available"}}}
+			break
+		}
+		v = s.light(id)
+	case r.Method == http.MethodPut && strings.HasPrefix(p, "/lights/"):
+		id := strings.TrimSuffix(strings.TrimPrefix(p, "/lights/"), "/state")
+		var st map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&st)
+		s.on[id], _ = st["on"].(bool)
+		v = []map[string]interface{}{{"success": map[string]interface{}{p + "/on": s.on[id]}}}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *cacheServer) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.on, id)
+}
+
+func nextCacheEvent(t *testing.T, events <-chan *CacheEvent) *CacheEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+	return nil
+}
+
+func TestCache(t *testing.T) {
+
+	s := newCacheServer()
+	defer s.Close()
+
+	b := s.bridge()
+	c := NewCache(b)
+
+	// The first read loads everything
+	lights, err := c.GetLights()
+	assert.Nil(t, err)
+	assert.Len(t, lights, 2)
+	assert.Equal(t, 1, lights[0].ID)
+	assert.Equal(t, "Light 2", lights[1].Name)
+
+	groups, err := c.GetGroups()
+	assert.Nil(t, err)
+	assert.Len(t, groups, 1)
+	assert.True(t, groups[0].GroupState.AnyOn)
+
+	conf, err := c.GetConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, "Philips hue", conf.Name)
+
+	l, err := c.GetLight(1)
+	assert.Nil(t, err)
+	assert.False(t, l.State.On)
+
+	_, err = c.GetLight(3)
+	assert.True(t, errors.Is(err, ErrResourceNotAvailable))
+
+	assert.Equal(t, 1, s.count("GET", "/"))
+	assert.Equal(t, 0, s.count("GET", "/lights/1"))
+
+	events := c.Subscribe()
+	defer c.Unsubscribe(events)
+
+	// Writes through the bridge invalidate the light and the groups
+	assert.Nil(t, l.On())
+	l, err = c.GetLight(1)
+	assert.Nil(t, err)
+	assert.True(t, l.State.On)
+	assert.Equal(t, 1, s.count("GET", "/lights/1"))
+	assert.Equal(t, &CacheEvent{Type: EventUpdate, Resource: CollectionLights, ID: "1"}, nextCacheEvent(t, events))
+
+	_, err = c.GetGroups()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, s.count("GET", "/groups"))
+
+	// Reads are served locally until the next write
+	_, err = c.GetLight(1)
+	assert.Nil(t, err)
+	_, err = c.GetGroups()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, s.count("GET", "/lights/1"))
+	assert.Equal(t, 1, s.count("GET", "/groups"))
+	assert.Equal(t, 1, s.count("GET", "/"))
+
+	// Removed resources are reported when the cache is refreshed
+	s.remove("2")
+	assert.Nil(t, c.Refresh())
+	assert.Equal(t, &CacheEvent{Type: EventDelete, Resource: CollectionLights, ID: "2"}, nextCacheEvent(t, events))
+
+	lights, err = c.GetLights()
+	assert.Nil(t, err)
+	assert.Len(t, lights, 1)
+}
+
+func TestCachePoll(t *testing.T) {
+
+	s := newCacheServer()
+	defer s.Close()
+
+	c := NewCache(s.bridge())
+	events := c.Subscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- c.Poll(ctx, 10*time.Millisecond)
+	}()
+
+	// The first poll adds every resource
+	added := map[string]bool{}
+	for len(added) < 4 {
+		e := <-events
+		assert.Equal(t, EventAdd, e.Type)
+		added[e.Resource+"/"+e.ID] = true
+	}
+	assert.Equal(t, map[string]bool{"lights/1": true, "lights/2": true, "groups/1": true, "config/": true}, added)
+
+	// Changes made elsewhere are picked up by the next poll
+	s.mu.Lock()
+	s.on["1"] = true
+	s.mu.Unlock()
+
+	select {
+	case e := <-events:
+		assert.Equal(t, &CacheEvent{Type: EventUpdate, Resource: CollectionLights, ID: "1"}, e)
+	case <-time.After(time.Second):
+		t.Fatal("no event after a change")
+	}
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	c.Unsubscribe(events)
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestCachePollError(t *testing.T) {
+
+	s := newCacheServer()
+	defer s.Close()
+
+	// Every request fails with 403
+	b := NewWithClient(s.URL, "unknown", s.Client())
+	c := NewCache(b)
+	errs := make(chan error, 10)
+	c.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Poll(ctx, 10*time.Millisecond)
+
+	select {
+	case err := <-errs:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("no error reported")
+	}
+}
+
+func TestNewCacheTwice(t *testing.T) {
+
+	client := &http.Client{}
+	b := NewWithClient(hostname, username, client)
+
+	NewCache(b)
+	c := NewCache(b)
+	assert.Nil(t, client.Transport)
+
+	ct, ok := b.client.Transport.(*cacheTransport)
+	assert.True(t, ok)
+	assert.Same(t, c, ct.cache)
+	assert.Nil(t, ct.next)
+}
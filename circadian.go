@@ -0,0 +1,247 @@
+package huego
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CurvePoint is the color temperature and brightness of lights at a sun elevation in degrees
+type CurvePoint struct {
+	Elevation float64
+	Ct        uint16
+	Bri       uint8
+}
+
+// Curve maps sun elevation to color temperature and brightness. Values are interpolated linearly between points,
+// and the first and last points are used when the sun is below or above them. Points are in order of elevation.
+type Curve []CurvePoint
+
+// DefaultCurve is warm and dim after dusk and cool and bright when the sun is high
+var DefaultCurve = Curve{
+	{Elevation: -6, Ct: 454, Bri: 77},
+	{Elevation: 0, Ct: 370, Bri: 150},
+	{Elevation: 15, Ct: 250, Bri: 230},
+	{Elevation: 40, Ct: 182, Bri: 254},
+}
+
+// At returns the color temperature and brightness at sun elevation e
+func (c Curve) At(e float64) (uint16, uint8) {
+
+	if len(c) == 0 {
+		return 0, 0
+	}
+	if e <= c[0].Elevation {
+		return c[0].Ct, c[0].Bri
+	}
+
+	for i := 1; i < len(c); i++ {
+		if e < c[i].Elevation {
+			a, b := c[i-1], c[i]
+			p := (e - a.Elevation) / (b.Elevation - a.Elevation)
+			return uint16(math.Round(lerp(float64(a.Ct), float64(b.Ct), p))), uint8(math.Round(lerp(float64(a.Bri), float64(b.Bri), p)))
+		}
+	}
+
+	last := c[len(c)-1]
+	return last.Ct, last.Bri
+}
+
+// SunElevation returns the elevation of the sun in degrees above the horizon at time t for a location in degrees,
+// north and east being positive. It uses the NOAA solar position equations without atmospheric refraction.
+func SunElevation(t time.Time, latitude, longitude float64) float64 {
+
+	t = t.UTC()
+	rad := math.Pi / 180
+
+	// Julian century since J2000
+	jc := (float64(t.UnixNano())/float64(24*time.Hour) + 2440587.5 - 2451545) / 36525
+
+	l0 := math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	m := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	e := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	center := math.Sin(m*rad)*(1.914602-jc*(0.004817+0.000014*jc)) + math.Sin(2*m*rad)*(0.019993-0.000101*jc) + math.Sin(3*m*rad)*0.000289
+	omega := 125.04 - 1934.136*jc
+	lambda := l0 + center - 0.00569 - 0.00478*math.Sin(omega*rad)
+	obliquity := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60 + 0.00256*math.Cos(omega*rad)
+	declination := math.Asin(math.Sin(obliquity*rad) * math.Sin(lambda*rad))
+
+	// Equation of time in minutes
+	y := math.Pow(math.Tan(obliquity/2*rad), 2)
+	eqTime := 4 / rad * (y*math.Sin(2*l0*rad) - 2*e*math.Sin(m*rad) + 4*e*y*math.Sin(m*rad)*math.Cos(2*l0*rad) -
+		0.5*y*y*math.Sin(4*l0*rad) - 1.25*e*e*math.Sin(2*m*rad))
+
+	minutes := float64(t.Hour()*60+t.Minute()) + float64(t.Second())/60
+	solarTime := math.Mod(minutes+eqTime+4*longitude, 1440)
+	if solarTime < 0 {
+		solarTime += 1440
+	}
+	hourAngle := solarTime/4 - 180
+
+	cosZenith := math.Sin(latitude*rad)*math.Sin(declination) + math.Cos(latitude*rad)*math.Cos(declination)*math.Cos(hourAngle*rad)
+
+	return 90 - math.Acos(clamp(cosZenith, -1, 1))/rad
+}
+
+// CircadianTarget is the state that a group is set to by a Circadian controller
+type CircadianTarget struct {
+	Group     int
+	Elevation float64
+	Ct        uint16
+	Bri       uint8
+}
+
+// Circadian sets the color temperature and brightness of groups to follow daylight at a location. Groups where
+// all lights are off are left alone, and lights that are off are never turned on. Fields must not be changed once Run is called.
+type Circadian struct {
+	// Latitude and Longitude of the location in degrees, north and east being positive
+	Latitude  float64
+	Longitude float64
+	// Curve is used for groups without a curve of their own. Defaults to DefaultCurve
+	Curve Curve
+	// Interval is the time between updates of Run. Defaults to 1 minute
+	Interval time.Duration
+	// TransitionTime of each update in multiples of 100ms. The bridge default of 400ms is used if 0
+	TransitionTime uint16
+	// OnError is called with the error of each group that Run fails to update. Set it before Run.
+	OnError func(error)
+
+	bridge *Bridge
+	mu     sync.Mutex
+	groups map[int]Curve
+	now    func() time.Time
+}
+
+// NewCircadian returns a controller for groups on b at the location latitude, longitude
+func NewCircadian(b *Bridge, latitude, longitude float64) *Circadian {
+	return &Circadian{
+		Latitude:  latitude,
+		Longitude: longitude,
+		bridge:    b,
+		groups:    map[int]Curve{},
+		now:       time.Now,
+	}
+}
+
+// AddGroup adds group id to the groups that are updated. curve overrides the curve of the controller if not nil.
+func (c *Circadian) AddGroup(id int, curve Curve) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.groups[id] = curve
+}
+
+// RemoveGroup stops updating group id
+func (c *Circadian) RemoveGroup(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.groups, id)
+}
+
+// Targets returns the current targets of the groups in order of group id
+func (c *Circadian) Targets() []CircadianTarget {
+	return c.TargetsAt(c.now())
+}
+
+// TargetsAt returns the targets of the groups at time t in order of group id
+func (c *Circadian) TargetsAt(t time.Time) []CircadianTarget {
+
+	e := SunElevation(t, c.Latitude, c.Longitude)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	targets := make([]CircadianTarget, 0, len(c.groups))
+	for id, curve := range c.groups {
+		if curve == nil {
+			curve = c.Curve
+		}
+		if curve == nil {
+			curve = DefaultCurve
+		}
+		ct, bri := curve.At(e)
+		targets = append(targets, CircadianTarget{Group: id, Elevation: e, Ct: ct, Bri: bri})
+	}
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Group < targets[j].Group })
+
+	return targets
+}
+
+// Update sets the groups to their current targets
+func (c *Circadian) Update() error {
+	return c.UpdateContext(context.Background())
+}
+
+// UpdateContext sets the groups to their current targets. Groups where no light is on are skipped.
+// All groups are updated even if some fail, the first error is returned.
+func (c *Circadian) UpdateContext(ctx context.Context) error {
+
+	var first error
+
+	c.updateAll(ctx, func(err error) {
+		if first == nil {
+			first = err
+		}
+	})
+
+	return first
+}
+
+// updateAll sets the groups to their current targets and calls report with the error of each group that fails
+func (c *Circadian) updateAll(ctx context.Context, report func(error)) {
+	for _, t := range c.Targets() {
+		err := c.update(ctx, t)
+		if err != nil {
+			report(fmt.Errorf("group %d: %w", t.Group, err))
+		}
+	}
+}
+
+// Run updates the groups at once and then every Interval until ctx is done. Failed updates are reported to OnError
+// and retried at the next interval.
+func (c *Circadian) Run(ctx context.Context) error {
+
+	interval := c.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.updateAll(ctx, func(err error) {
+			if ctx.Err() == nil && c.OnError != nil {
+				c.OnError(err)
+			}
+		})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// update sets group t.Group to t if any of its lights is on. On is left out so that lights that are off stay off.
+func (c *Circadian) update(ctx context.Context, t CircadianTarget) error {
+
+	g, err := c.bridge.GetGroupContext(ctx, t.Group)
+	if err != nil {
+		return err
+	}
+	if g.GroupState == nil || !g.GroupState.AnyOn {
+		return nil
+	}
+
+	u := NewStateUpdate().SetCt(t.Ct).SetBri(t.Bri)
+	if c.TransitionTime > 0 {
+		u.SetTransitionTime(c.TransitionTime)
+	}
+
+	_, err = c.bridge.SetGroupStateContext(ctx, t.Group, u)
+	return err
+}
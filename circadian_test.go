@@ -0,0 +1,142 @@
+package huego
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSunElevation(t *testing.T) {
+
+	// The sun is highest in Stockholm around 10:49 UTC at the summer solstice, at 90-59.33+23.44 degrees
+	max, at := -90.0, time.Time{}
+	for m := 0; m < 24*60; m++ {
+		tm := time.Date(2020, 6, 20, 0, m, 0, 0, time.UTC)
+		if e := SunElevation(tm, 59.33, 18.07); e > max {
+			max, at = e, tm
+		}
+	}
+	assert.InDelta(t, 54.1, max, 0.1)
+	assert.Equal(t, time.Date(2020, 6, 20, 10, 49, 0, 0, time.UTC), at)
+
+	// The time zone of t doesn't matter
+	loc := time.FixedZone("CEST", 2*60*60)
+	assert.InDelta(t, max, SunElevation(at.In(loc), 59.33, 18.07), 0.0001)
+
+	assert.InDelta(t, 88.2, SunElevation(time.Date(2021, 3, 20, 12, 0, 0, 0, time.UTC), 0, 0), 0.1)
+	assert.True(t, SunElevation(time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC), 59.33, 18.07) < -50)
+}
+
+func TestCurve(t *testing.T) {
+
+	ct, bri := DefaultCurve.At(-30)
+	assert.Equal(t, uint16(454), ct)
+	assert.Equal(t, uint8(77), bri)
+
+	ct, bri = DefaultCurve.At(-3)
+	assert.Equal(t, uint16(412), ct)
+	assert.Equal(t, uint8(114), bri)
+
+	ct, bri = DefaultCurve.At(60)
+	assert.Equal(t, uint16(182), ct)
+	assert.Equal(t, uint8(254), bri)
+
+	ct, bri = Curve(nil).At(10)
+	assert.Equal(t, uint16(0), ct)
+	assert.Equal(t, uint8(0), bri)
+}
+
+// circadianGroups serves groups 1 with a light on and 2 with all lights off
+func circadianGroups(w http.ResponseWriter, r *http.Request, n int) {
+	if r.Method == http.MethodPut {
+		w.Write([]byte(`[{"success":{}}]`))
+		return
+	}
+	switch p := apiPath(r); p {
+	case "/groups/1":
+		w.Write([]byte(`{"name":"Living room","lights":["1","2"],"state":{"any_on":true,"all_on":false},"action":{"on":true}}`))
+	case "/groups/2":
+		w.Write([]byte(`{"name":"Bedroom","lights":["3"],"state":{"any_on":false,"all_on":false},"action":{"on":false}}`))
+	default:
+		w.Write([]byte(`[{"error":{"type":3,"address":"` + p + `","description":"resource not available"}}]`))
+	}
+}
+
+// circadianActions returns the last body put to each path
+func circadianActions(s *recorder) map[string]string {
+	actions := map[string]string{}
+	for _, r := range s.requests() {
+		if r.Method == http.MethodPut {
+			actions[r.Path] = string(r.Body)
+		}
+	}
+	return actions
+}
+
+func TestCircadian(t *testing.T) {
+
+	s := newRecorder(circadianGroups)
+	defer s.Close()
+
+	b := s.bridge()
+
+	// Stockholm at noon in midsummer and at midnight in midwinter
+	c := NewCircadian(b, 59.33, 18.07)
+	c.TransitionTime = 50
+	c.now = func() time.Time { return time.Date(2020, 6, 20, 10, 49, 0, 0, time.UTC) }
+	c.AddGroup(2, nil)
+	c.AddGroup(1, Curve{{Elevation: 0, Ct: 400, Bri: 100}, {Elevation: 60, Ct: 200, Bri: 200}})
+
+	targets := c.Targets()
+	assert.Len(t, targets, 2)
+	assert.Equal(t, 1, targets[0].Group)
+	assert.InDelta(t, 54.1, targets[0].Elevation, 0.1)
+	assert.Equal(t, uint16(220), targets[0].Ct)
+	assert.Equal(t, uint8(190), targets[0].Bri)
+	assert.Equal(t, CircadianTarget{Group: 2, Elevation: targets[0].Elevation, Ct: 182, Bri: 254}, targets[1])
+
+	targets = c.TargetsAt(time.Date(2020, 12, 21, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, uint16(400), targets[0].Ct)
+	assert.Equal(t, uint16(454), targets[1].Ct)
+
+	// The group with all lights off is left alone and on is never sent
+	assert.Nil(t, c.Update())
+	assert.Equal(t, map[string]string{"/groups/1/action": `{"bri":190,"ct":220,"transitiontime":50}`}, circadianActions(s))
+
+	c.AddGroup(3, nil)
+	err := c.Update()
+	assert.True(t, errors.Is(err, ErrResourceNotAvailable))
+	assert.Contains(t, err.Error(), "group 3: ")
+
+	c.RemoveGroup(3)
+	assert.Len(t, c.Targets(), 2)
+}
+
+func TestCircadianRunError(t *testing.T) {
+
+	s := newRecorder(circadianGroups)
+	defer s.Close()
+
+	c := NewCircadian(s.bridge(), 59.33, 18.07)
+	c.AddGroup(1, nil)
+	c.AddGroup(3, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 10)
+	c.OnError = func(err error) {
+		errs <- err
+		cancel()
+	}
+
+	err := c.Run(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, errs, 1)
+	err = <-errs
+	assert.True(t, errors.Is(err, ErrResourceNotAvailable))
+	assert.Contains(t, err.Error(), "group 3: ")
+	assert.Len(t, circadianActions(s), 1)
+}
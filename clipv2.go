@@ -0,0 +1,831 @@
+package huego
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	applicationKey = "hue-application-key"
+	resourcePath   = "/clip/v2/resource"
+)
+
+// Resource types of the CLIP v2 API
+const (
+	ResourceLight        = "light"
+	ResourceRoom         = "room"
+	ResourceZone         = "zone"
+	ResourceGroupedLight = "grouped_light"
+	ResourceScene        = "scene"
+	ResourceDevice       = "device"
+	ResourceMotion       = "motion"
+	ResourceButton       = "button"
+	ResourceBridgeHome   = "bridge_home"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// V2Response holds the response data returned from the bridge after a request to the CLIP v2 API
+type V2Response struct {
+	Errors []V2Error        `json:"errors"`
+	Data   *json.RawMessage `json:"data"`
+}
+
+// V2Error defines an error returned from the CLIP v2 API
+type V2Error struct {
+	Description string `json:"description"`
+}
+
+// Error returns an error string
+func (e *V2Error) Error() string {
+	return fmt.Sprintf("ERROR: \"%s\"", e.Description)
+}
+
+// ResourceIdentifier references a resource on the bridge by its id and type
+type ResourceIdentifier struct {
+	RID   string `json:"rid"`
+	RType string `json:"rtype"`
+}
+
+// ResourceMetadata holds the configurable name and archetype of a resource
+type ResourceMetadata struct {
+	Name      string `json:"name,omitempty"`
+	Archetype string `json:"archetype,omitempty"`
+	ControlID int    `json:"control_id,omitempty"`
+}
+
+// ResourceOn defines the on/off state of a light or group
+type ResourceOn struct {
+	On bool `json:"on"`
+}
+
+// ResourceDimming defines the brightness of a light or group in percent (0-100)
+type ResourceDimming struct {
+	Brightness  float64 `json:"brightness"`
+	MinDimLevel float64 `json:"min_dim_level,omitempty"`
+}
+
+// ResourceXy is a color in CIE xy color space
+type ResourceXy struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ResourceGamut defines the color gamut of a light by its three corners in CIE xy color space
+type ResourceGamut struct {
+	Red   ResourceXy `json:"red"`
+	Green ResourceXy `json:"green"`
+	Blue  ResourceXy `json:"blue"`
+}
+
+// ResourceColor defines the color of a light
+type ResourceColor struct {
+	Xy        ResourceXy     `json:"xy"`
+	Gamut     *ResourceGamut `json:"gamut,omitempty"`
+	GamutType string         `json:"gamut_type,omitempty"`
+}
+
+// MirekSchema defines the range of color temperatures supported by a light
+type MirekSchema struct {
+	MirekMinimum uint16 `json:"mirek_minimum"`
+	MirekMaximum uint16 `json:"mirek_maximum"`
+}
+
+// ResourceColorTemperature defines the color temperature of a light in mirek
+type ResourceColorTemperature struct {
+	Mirek       *uint16      `json:"mirek,omitempty"`
+	MirekValid  bool         `json:"mirek_valid,omitempty"`
+	MirekSchema *MirekSchema `json:"mirek_schema,omitempty"`
+}
+
+// ResourceDynamics defines the speed of dynamic scenes and effects
+type ResourceDynamics struct {
+	Status     string  `json:"status,omitempty"`
+	Speed      float64 `json:"speed,omitempty"`
+	SpeedValid bool    `json:"speed_valid,omitempty"`
+	Duration   int     `json:"duration,omitempty"`
+}
+
+// ResourceAlert defines the alert actions supported by a light
+type ResourceAlert struct {
+	Action       string   `json:"action,omitempty"`
+	ActionValues []string `json:"action_values,omitempty"`
+}
+
+// GradientPoint defines the color of one point of a gradient light
+type GradientPoint struct {
+	Color ResourceColor `json:"color"`
+}
+
+// ResourceGradient defines the colors of a gradient capable light such as the gradient lightstrip
+type ResourceGradient struct {
+	Points        []GradientPoint `json:"points"`
+	PointsCapable int             `json:"points_capable,omitempty"`
+}
+
+// ResourceEffects defines the effect of a light, for example candle or fire
+type ResourceEffects struct {
+	Effect       string   `json:"effect,omitempty"`
+	EffectValues []string `json:"effect_values,omitempty"`
+	Status       string   `json:"status,omitempty"`
+	StatusValues []string `json:"status_values,omitempty"`
+}
+
+// LightResource represents a light in the CLIP v2 API
+type LightResource struct {
+	ID               string                    `json:"id,omitempty"`
+	IDV1             string                    `json:"id_v1,omitempty"`
+	Owner            *ResourceIdentifier       `json:"owner,omitempty"`
+	Metadata         *ResourceMetadata         `json:"metadata,omitempty"`
+	On               *ResourceOn               `json:"on,omitempty"`
+	Dimming          *ResourceDimming          `json:"dimming,omitempty"`
+	ColorTemperature *ResourceColorTemperature `json:"color_temperature,omitempty"`
+	Color            *ResourceColor            `json:"color,omitempty"`
+	Dynamics         *ResourceDynamics         `json:"dynamics,omitempty"`
+	Alert            *ResourceAlert            `json:"alert,omitempty"`
+	Gradient         *ResourceGradient         `json:"gradient,omitempty"`
+	Effects          *ResourceEffects          `json:"effects,omitempty"`
+	Mode             string                    `json:"mode,omitempty"`
+	Type             string                    `json:"type,omitempty"`
+}
+
+// RoomResource represents a room in the CLIP v2 API. Children of a room are devices.
+type RoomResource struct {
+	ID       string               `json:"id,omitempty"`
+	IDV1     string               `json:"id_v1,omitempty"`
+	Children []ResourceIdentifier `json:"children,omitempty"`
+	Services []ResourceIdentifier `json:"services,omitempty"`
+	Metadata *ResourceMetadata    `json:"metadata,omitempty"`
+	Type     string               `json:"type,omitempty"`
+}
+
+// ZoneResource represents a zone in the CLIP v2 API. Children of a zone are lights.
+type ZoneResource struct {
+	ID       string               `json:"id,omitempty"`
+	IDV1     string               `json:"id_v1,omitempty"`
+	Children []ResourceIdentifier `json:"children,omitempty"`
+	Services []ResourceIdentifier `json:"services,omitempty"`
+	Metadata *ResourceMetadata    `json:"metadata,omitempty"`
+	Type     string               `json:"type,omitempty"`
+}
+
+// GroupedLightResource represents the combined lights of a room, zone or the bridge home
+type GroupedLightResource struct {
+	ID      string              `json:"id,omitempty"`
+	IDV1    string              `json:"id_v1,omitempty"`
+	Owner   *ResourceIdentifier `json:"owner,omitempty"`
+	On      *ResourceOn         `json:"on,omitempty"`
+	Dimming *ResourceDimming    `json:"dimming,omitempty"`
+	Alert   *ResourceAlert      `json:"alert,omitempty"`
+	Type    string              `json:"type,omitempty"`
+}
+
+// SceneAction defines the state of one light when a scene is recalled
+type SceneAction struct {
+	Target ResourceIdentifier `json:"target"`
+	Action LightResource      `json:"action"`
+}
+
+// ScenePalette defines the colors a dynamic scene cycles through
+type ScenePalette struct {
+	Color            []SceneColorPalette `json:"color,omitempty"`
+	Dimming          []ResourceDimming   `json:"dimming,omitempty"`
+	ColorTemperature []SceneCtPalette    `json:"color_temperature,omitempty"`
+}
+
+// SceneColorPalette is one color of a scene palette
+type SceneColorPalette struct {
+	Color   ResourceColor   `json:"color"`
+	Dimming ResourceDimming `json:"dimming"`
+}
+
+// SceneCtPalette is one color temperature of a scene palette
+type SceneCtPalette struct {
+	ColorTemperature ResourceColorTemperature `json:"color_temperature"`
+	Dimming          ResourceDimming          `json:"dimming"`
+}
+
+// SceneRecall is used to recall a scene. Action is one of active, dynamic_palette or static.
+type SceneRecall struct {
+	Action   string           `json:"action,omitempty"`
+	Duration int              `json:"duration,omitempty"`
+	Dimming  *ResourceDimming `json:"dimming,omitempty"`
+}
+
+// SceneStatus holds the active state of a scene
+type SceneStatus struct {
+	Active string `json:"active,omitempty"`
+}
+
+// SceneResource represents a scene in the CLIP v2 API
+type SceneResource struct {
+	ID          string              `json:"id,omitempty"`
+	IDV1        string              `json:"id_v1,omitempty"`
+	Actions     []SceneAction       `json:"actions,omitempty"`
+	Palette     *ScenePalette       `json:"palette,omitempty"`
+	Recall      *SceneRecall        `json:"recall,omitempty"`
+	Metadata    *ResourceMetadata   `json:"metadata,omitempty"`
+	Group       *ResourceIdentifier `json:"group,omitempty"`
+	Speed       float64             `json:"speed,omitempty"`
+	AutoDynamic bool                `json:"auto_dynamic,omitempty"`
+	Status      *SceneStatus        `json:"status,omitempty"`
+	Type        string              `json:"type,omitempty"`
+}
+
+// ProductData holds information about the product of a device
+type ProductData struct {
+	ModelID              string `json:"model_id,omitempty"`
+	ManufacturerName     string `json:"manufacturer_name,omitempty"`
+	ProductName          string `json:"product_name,omitempty"`
+	ProductArchetype     string `json:"product_archetype,omitempty"`
+	Certified            bool   `json:"certified,omitempty"`
+	SoftwareVersion      string `json:"software_version,omitempty"`
+	HardwarePlatformType string `json:"hardware_platform_type,omitempty"`
+}
+
+// DeviceResource represents a physical device in the CLIP v2 API. Services are the lights, sensors and buttons the device provides.
+type DeviceResource struct {
+	ID          string               `json:"id,omitempty"`
+	IDV1        string               `json:"id_v1,omitempty"`
+	ProductData *ProductData         `json:"product_data,omitempty"`
+	Metadata    *ResourceMetadata    `json:"metadata,omitempty"`
+	Services    []ResourceIdentifier `json:"services,omitempty"`
+	Type        string               `json:"type,omitempty"`
+}
+
+// MotionReport holds the last motion report of a motion sensor
+type MotionReport struct {
+	Changed string `json:"changed,omitempty"`
+	Motion  bool   `json:"motion"`
+}
+
+// MotionState holds the state of a motion sensor
+type MotionState struct {
+	Motion       bool          `json:"motion"`
+	MotionValid  bool          `json:"motion_valid"`
+	MotionReport *MotionReport `json:"motion_report,omitempty"`
+}
+
+// MotionSensitivity holds the sensitivity of a motion sensor
+type MotionSensitivity struct {
+	Sensitivity    int `json:"sensitivity"`
+	SensitivityMax int `json:"sensitivity_max,omitempty"`
+}
+
+// MotionResource represents a motion sensor in the CLIP v2 API
+type MotionResource struct {
+	ID          string              `json:"id,omitempty"`
+	IDV1        string              `json:"id_v1,omitempty"`
+	Owner       *ResourceIdentifier `json:"owner,omitempty"`
+	Enabled     *bool               `json:"enabled,omitempty"`
+	Motion      *MotionState        `json:"motion,omitempty"`
+	Sensitivity *MotionSensitivity  `json:"sensitivity,omitempty"`
+	Type        string              `json:"type,omitempty"`
+}
+
+// ButtonReport holds the last event of a button and when it occurred
+type ButtonReport struct {
+	Updated string `json:"updated,omitempty"`
+	Event   string `json:"event,omitempty"`
+}
+
+// ButtonState holds the state of a button
+type ButtonState struct {
+	LastEvent      string        `json:"last_event,omitempty"`
+	ButtonReport   *ButtonReport `json:"button_report,omitempty"`
+	RepeatInterval int           `json:"repeat_interval,omitempty"`
+	EventValues    []string      `json:"event_values,omitempty"`
+}
+
+// ButtonResource represents one button of a switch in the CLIP v2 API
+type ButtonResource struct {
+	ID       string              `json:"id,omitempty"`
+	IDV1     string              `json:"id_v1,omitempty"`
+	Owner    *ResourceIdentifier `json:"owner,omitempty"`
+	Metadata *ResourceMetadata   `json:"metadata,omitempty"`
+	Button   *ButtonState        `json:"button,omitempty"`
+	Type     string              `json:"type,omitempty"`
+}
+
+// BridgeHomeResource represents all rooms and devices connected to the bridge
+type BridgeHomeResource struct {
+	ID       string               `json:"id,omitempty"`
+	IDV1     string               `json:"id_v1,omitempty"`
+	Children []ResourceIdentifier `json:"children,omitempty"`
+	Services []ResourceIdentifier `json:"services,omitempty"`
+	Type     string               `json:"type,omitempty"`
+}
+
+// IsResourceID returns true if id is a valid CLIP v2 resource identifier (UUID)
+func IsResourceID(id string) bool {
+	return uuidPattern.MatchString(id)
+}
+
+func doV2(ctx context.Context, method, url, key string, data []byte, client *http.Client) ([]byte, error) {
+
+	body := strings.NewReader(string(data))
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+
+	req.Header.Set(applicationKey, key)
+	if data != nil {
+		req.Header.Set(contentType, applicationJSON)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	result, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// unmarshalV2 unmarshals the data array of a CLIP v2 response into v.
+// The first error in the response is returned if there is one.
+func unmarshalV2(data []byte, v interface{}) error {
+
+	var r V2Response
+
+	err := json.Unmarshal(data, &r)
+	if err != nil {
+		return err
+	}
+
+	if len(r.Errors) > 0 {
+		return &r.Errors[0]
+	}
+
+	if r.Data == nil {
+		return errors.New("no data in response from bridge")
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(*r.Data, v)
+}
+
+func (b *Bridge) getResourcesContext(ctx context.Context, rtype string, v interface{}) error {
+
+	target, err := b.getV2Path(resourcePath, rtype)
+	if err != nil {
+		return err
+	}
+
+	res, err := doV2(ctx, http.MethodGet, target, b.User, nil, b.client)
+	if err != nil {
+		return err
+	}
+
+	return unmarshalV2(res, v)
+}
+
+// getResourceContext gets one resource of type rtype. Single resources are returned by the bridge as a list of one.
+func (b *Bridge) getResourceContext(ctx context.Context, rtype, id string, v interface{}) error {
+
+	if !IsResourceID(id) {
+		return fmt.Errorf("invalid resource id %q", id)
+	}
+
+	target, err := b.getV2Path(resourcePath, rtype, id)
+	if err != nil {
+		return err
+	}
+
+	res, err := doV2(ctx, http.MethodGet, target, b.User, nil, b.client)
+	if err != nil {
+		return err
+	}
+
+	var raw []json.RawMessage
+
+	err = unmarshalV2(res, &raw)
+	if err != nil {
+		return err
+	}
+
+	if len(raw) == 0 {
+		return fmt.Errorf("resource %s/%s not found", rtype, id)
+	}
+
+	return json.Unmarshal(raw[0], v)
+}
+
+// sendResourceContext sends body to the bridge with method and returns the identifiers of the affected resources.
+// id is left out of the path when it is empty.
+func (b *Bridge) sendResourceContext(ctx context.Context, method, rtype, id string, body interface{}) ([]ResourceIdentifier, error) {
+
+	var ids []ResourceIdentifier
+
+	elem := []string{resourcePath, rtype}
+	if id != "" {
+		if !IsResourceID(id) {
+			return nil, fmt.Errorf("invalid resource id %q", id)
+		}
+		elem = append(elem, id)
+	}
+
+	target, err := b.getV2Path(elem...)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if body != nil {
+		data, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := doV2(ctx, method, target, b.User, data, b.client)
+	if err != nil {
+		return nil, err
+	}
+
+	err = unmarshalV2(res, &ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+/*
+
+	CLIP V2 LIGHT API
+
+*/
+
+// GetLightResources returns all lights known to the bridge using the CLIP v2 API
+func (b *Bridge) GetLightResources() ([]LightResource, error) {
+	return b.GetLightResourcesContext(context.Background())
+}
+
+// GetLightResourcesContext returns all lights known to the bridge using the CLIP v2 API
+func (b *Bridge) GetLightResourcesContext(ctx context.Context) ([]LightResource, error) {
+	var r []LightResource
+	err := b.getResourcesContext(ctx, ResourceLight, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetLightResource returns one light by its id using the CLIP v2 API
+func (b *Bridge) GetLightResource(id string) (*LightResource, error) {
+	return b.GetLightResourceContext(context.Background(), id)
+}
+
+// GetLightResourceContext returns one light by its id using the CLIP v2 API
+func (b *Bridge) GetLightResourceContext(ctx context.Context, id string) (*LightResource, error) {
+	r := &LightResource{}
+	err := b.getResourceContext(ctx, ResourceLight, id, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// UpdateLightResource updates one light with the attributes set in l using the CLIP v2 API.
+// Only set attributes are sent to the bridge, for example On, Dimming, Color, ColorTemperature or Gradient.
+func (b *Bridge) UpdateLightResource(id string, l *LightResource) ([]ResourceIdentifier, error) {
+	return b.UpdateLightResourceContext(context.Background(), id, l)
+}
+
+// UpdateLightResourceContext updates one light with the attributes set in l using the CLIP v2 API.
+// Only set attributes are sent to the bridge, for example On, Dimming, Color, ColorTemperature or Gradient.
+func (b *Bridge) UpdateLightResourceContext(ctx context.Context, id string, l *LightResource) ([]ResourceIdentifier, error) {
+	return b.sendResourceContext(ctx, http.MethodPut, ResourceLight, id, l)
+}
+
+/*
+
+	CLIP V2 ROOM AND ZONE API
+
+*/
+
+// GetRoomResources returns all rooms known to the bridge using the CLIP v2 API
+func (b *Bridge) GetRoomResources() ([]RoomResource, error) {
+	return b.GetRoomResourcesContext(context.Background())
+}
+
+// GetRoomResourcesContext returns all rooms known to the bridge using the CLIP v2 API
+func (b *Bridge) GetRoomResourcesContext(ctx context.Context) ([]RoomResource, error) {
+	var r []RoomResource
+	err := b.getResourcesContext(ctx, ResourceRoom, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetRoomResource returns one room by its id using the CLIP v2 API
+func (b *Bridge) GetRoomResource(id string) (*RoomResource, error) {
+	return b.GetRoomResourceContext(context.Background(), id)
+}
+
+// GetRoomResourceContext returns one room by its id using the CLIP v2 API
+func (b *Bridge) GetRoomResourceContext(ctx context.Context, id string) (*RoomResource, error) {
+	r := &RoomResource{}
+	err := b.getResourceContext(ctx, ResourceRoom, id, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetZoneResources returns all zones known to the bridge using the CLIP v2 API
+func (b *Bridge) GetZoneResources() ([]ZoneResource, error) {
+	return b.GetZoneResourcesContext(context.Background())
+}
+
+// GetZoneResourcesContext returns all zones known to the bridge using the CLIP v2 API
+func (b *Bridge) GetZoneResourcesContext(ctx context.Context) ([]ZoneResource, error) {
+	var r []ZoneResource
+	err := b.getResourcesContext(ctx, ResourceZone, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetZoneResource returns one zone by its id using the CLIP v2 API
+func (b *Bridge) GetZoneResource(id string) (*ZoneResource, error) {
+	return b.GetZoneResourceContext(context.Background(), id)
+}
+
+// GetZoneResourceContext returns one zone by its id using the CLIP v2 API
+func (b *Bridge) GetZoneResourceContext(ctx context.Context, id string) (*ZoneResource, error) {
+	r := &ZoneResource{}
+	err := b.getResourceContext(ctx, ResourceZone, id, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+/*
+
+	CLIP V2 GROUPED LIGHT API
+
+*/
+
+// GetGroupedLightResources returns all grouped lights known to the bridge using the CLIP v2 API
+func (b *Bridge) GetGroupedLightResources() ([]GroupedLightResource, error) {
+	return b.GetGroupedLightResourcesContext(context.Background())
+}
+
+// GetGroupedLightResourcesContext returns all grouped lights known to the bridge using the CLIP v2 API
+func (b *Bridge) GetGroupedLightResourcesContext(ctx context.Context) ([]GroupedLightResource, error) {
+	var r []GroupedLightResource
+	err := b.getResourcesContext(ctx, ResourceGroupedLight, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetGroupedLightResource returns one grouped light by its id using the CLIP v2 API
+func (b *Bridge) GetGroupedLightResource(id string) (*GroupedLightResource, error) {
+	return b.GetGroupedLightResourceContext(context.Background(), id)
+}
+
+// GetGroupedLightResourceContext returns one grouped light by its id using the CLIP v2 API
+func (b *Bridge) GetGroupedLightResourceContext(ctx context.Context, id string) (*GroupedLightResource, error) {
+	r := &GroupedLightResource{}
+	err := b.getResourceContext(ctx, ResourceGroupedLight, id, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// UpdateGroupedLightResource updates all lights of a grouped light with the attributes set in g using the CLIP v2 API
+func (b *Bridge) UpdateGroupedLightResource(id string, g *GroupedLightResource) ([]ResourceIdentifier, error) {
+	return b.UpdateGroupedLightResourceContext(context.Background(), id, g)
+}
+
+// UpdateGroupedLightResourceContext updates all lights of a grouped light with the attributes set in g using the CLIP v2 API
+func (b *Bridge) UpdateGroupedLightResourceContext(ctx context.Context, id string, g *GroupedLightResource) ([]ResourceIdentifier, error) {
+	return b.sendResourceContext(ctx, http.MethodPut, ResourceGroupedLight, id, g)
+}
+
+/*
+
+	CLIP V2 SCENE API
+
+*/
+
+// GetSceneResources returns all scenes known to the bridge using the CLIP v2 API
+func (b *Bridge) GetSceneResources() ([]SceneResource, error) {
+	return b.GetSceneResourcesContext(context.Background())
+}
+
+// GetSceneResourcesContext returns all scenes known to the bridge using the CLIP v2 API
+func (b *Bridge) GetSceneResourcesContext(ctx context.Context) ([]SceneResource, error) {
+	var r []SceneResource
+	err := b.getResourcesContext(ctx, ResourceScene, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetSceneResource returns one scene by its id using the CLIP v2 API
+func (b *Bridge) GetSceneResource(id string) (*SceneResource, error) {
+	return b.GetSceneResourceContext(context.Background(), id)
+}
+
+// GetSceneResourceContext returns one scene by its id using the CLIP v2 API
+func (b *Bridge) GetSceneResourceContext(ctx context.Context, id string) (*SceneResource, error) {
+	r := &SceneResource{}
+	err := b.getResourceContext(ctx, ResourceScene, id, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CreateSceneResource creates one new scene with its attributes defined in s using the CLIP v2 API
+func (b *Bridge) CreateSceneResource(s *SceneResource) ([]ResourceIdentifier, error) {
+	return b.CreateSceneResourceContext(context.Background(), s)
+}
+
+// CreateSceneResourceContext creates one new scene with its attributes defined in s using the CLIP v2 API
+func (b *Bridge) CreateSceneResourceContext(ctx context.Context, s *SceneResource) ([]ResourceIdentifier, error) {
+	return b.sendResourceContext(ctx, http.MethodPost, ResourceScene, "", s)
+}
+
+// UpdateSceneResource updates one scene with the attributes set in s using the CLIP v2 API
+func (b *Bridge) UpdateSceneResource(id string, s *SceneResource) ([]ResourceIdentifier, error) {
+	return b.UpdateSceneResourceContext(context.Background(), id, s)
+}
+
+// UpdateSceneResourceContext updates one scene with the attributes set in s using the CLIP v2 API
+func (b *Bridge) UpdateSceneResourceContext(ctx context.Context, id string, s *SceneResource) ([]ResourceIdentifier, error) {
+	return b.sendResourceContext(ctx, http.MethodPut, ResourceScene, id, s)
+}
+
+// RecallSceneResource recalls one scene using the CLIP v2 API. action is one of active, dynamic_palette or static.
+func (b *Bridge) RecallSceneResource(id, action string) ([]ResourceIdentifier, error) {
+	return b.RecallSceneResourceContext(context.Background(), id, action)
+}
+
+// RecallSceneResourceContext recalls one scene using the CLIP v2 API. action is one of active, dynamic_palette or static.
+func (b *Bridge) RecallSceneResourceContext(ctx context.Context, id, action string) ([]ResourceIdentifier, error) {
+	return b.sendResourceContext(ctx, http.MethodPut, ResourceScene, id, &SceneResource{Recall: &SceneRecall{Action: action}})
+}
+
+// DeleteSceneResource deletes one scene using the CLIP v2 API
+func (b *Bridge) DeleteSceneResource(id string) error {
+	return b.DeleteSceneResourceContext(context.Background(), id)
+}
+
+// DeleteSceneResourceContext deletes one scene using the CLIP v2 API
+func (b *Bridge) DeleteSceneResourceContext(ctx context.Context, id string) error {
+	_, err := b.sendResourceContext(ctx, http.MethodDelete, ResourceScene, id, nil)
+	return err
+}
+
+/*
+
+	CLIP V2 DEVICE API
+
+*/
+
+// GetDeviceResources returns all devices known to the bridge using the CLIP v2 API
+func (b *Bridge) GetDeviceResources() ([]DeviceResource, error) {
+	return b.GetDeviceResourcesContext(context.Background())
+}
+
+// GetDeviceResourcesContext returns all devices known to the bridge using the CLIP v2 API
+func (b *Bridge) GetDeviceResourcesContext(ctx context.Context) ([]DeviceResource, error) {
+	var r []DeviceResource
+	err := b.getResourcesContext(ctx, ResourceDevice, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetDeviceResource returns one device by its id using the CLIP v2 API
+func (b *Bridge) GetDeviceResource(id string) (*DeviceResource, error) {
+	return b.GetDeviceResourceContext(context.Background(), id)
+}
+
+// GetDeviceResourceContext returns one device by its id using the CLIP v2 API
+func (b *Bridge) GetDeviceResourceContext(ctx context.Context, id string) (*DeviceResource, error) {
+	r := &DeviceResource{}
+	err := b.getResourceContext(ctx, ResourceDevice, id, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+/*
+
+	CLIP V2 SENSOR API
+
+*/
+
+// GetMotionResources returns all motion sensors known to the bridge using the CLIP v2 API
+func (b *Bridge) GetMotionResources() ([]MotionResource, error) {
+	return b.GetMotionResourcesContext(context.Background())
+}
+
+// GetMotionResourcesContext returns all motion sensors known to the bridge using the CLIP v2 API
+func (b *Bridge) GetMotionResourcesContext(ctx context.Context) ([]MotionResource, error) {
+	var r []MotionResource
+	err := b.getResourcesContext(ctx, ResourceMotion, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetMotionResource returns one motion sensor by its id using the CLIP v2 API
+func (b *Bridge) GetMotionResource(id string) (*MotionResource, error) {
+	return b.GetMotionResourceContext(context.Background(), id)
+}
+
+// GetMotionResourceContext returns one motion sensor by its id using the CLIP v2 API
+func (b *Bridge) GetMotionResourceContext(ctx context.Context, id string) (*MotionResource, error) {
+	r := &MotionResource{}
+	err := b.getResourceContext(ctx, ResourceMotion, id, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetButtonResources returns all buttons known to the bridge using the CLIP v2 API
+func (b *Bridge) GetButtonResources() ([]ButtonResource, error) {
+	return b.GetButtonResourcesContext(context.Background())
+}
+
+// GetButtonResourcesContext returns all buttons known to the bridge using the CLIP v2 API
+func (b *Bridge) GetButtonResourcesContext(ctx context.Context) ([]ButtonResource, error) {
+	var r []ButtonResource
+	err := b.getResourcesContext(ctx, ResourceButton, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetButtonResource returns one button by its id using the CLIP v2 API
+func (b *Bridge) GetButtonResource(id string) (*ButtonResource, error) {
+	return b.GetButtonResourceContext(context.Background(), id)
+}
+
+// GetButtonResourceContext returns one button by its id using the CLIP v2 API
+func (b *Bridge) GetButtonResourceContext(ctx context.Context, id string) (*ButtonResource, error) {
+	r := &ButtonResource{}
+	err := b.getResourceContext(ctx, ResourceButton, id, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+/*
+
+	CLIP V2 BRIDGE HOME API
+
+*/
+
+// GetBridgeHomeResources returns the bridge home resources using the CLIP v2 API
+func (b *Bridge) GetBridgeHomeResources() ([]BridgeHomeResource, error) {
+	return b.GetBridgeHomeResourcesContext(context.Background())
+}
+
+// GetBridgeHomeResourcesContext returns the bridge home resources using the CLIP v2 API
+func (b *Bridge) GetBridgeHomeResourcesContext(ctx context.Context) ([]BridgeHomeResource, error) {
+	var r []BridgeHomeResource
+	err := b.getResourcesContext(ctx, ResourceBridgeHome, &r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,272 @@
+package huego
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testLightID   = "9e1c5f2a-7b3a-4d4f-8a1e-3c6b1b0c2d11"
+	testSceneID   = "4a2f7c1e-9b3d-4e6a-8c5f-0d1e2f3a4b5c"
+	testUnknownID = "00000000-0000-0000-0000-000000000000"
+)
+
+func newV2TestServer(t *testing.T) *httptest.Server {
+
+	resources := map[string]string{
+		ResourceLight:        `[{"id":"` + testLightID + `","id_v1":"/lights/1","owner":{"rid":"f1d2b6c8-0a3e-4b5c-9d7e-8f6a5b4c3d21","rtype":"device"},"metadata":{"name":"Hue color lamp 1","archetype":"sultan_bulb"},"on":{"on":true},"dimming":{"brightness":100.0,"min_dim_level":0.2},"color_temperature":{"mirek":null,"mirek_valid":false,"mirek_schema":{"mirek_minimum":153,"mirek_maximum":500}},"color":{"xy":{"x":0.4573,"y":0.41},"gamut":{"red":{"x":0.6915,"y":0.3083},"green":{"x":0.17,"y":0.7},"blue":{"x":0.1532,"y":0.0475}},"gamut_type":"C"},"gradient":{"points":[],"points_capable":5},"mode":"normal","type":"light"}]`,
+		ResourceRoom:         `[{"id":"6f1a2b3c-4d5e-4f6a-8b7c-9d0e1f2a3b4c","id_v1":"/groups/1","children":[{"rid":"f1d2b6c8-0a3e-4b5c-9d7e-8f6a5b4c3d21","rtype":"device"}],"services":[{"rid":"7a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d","rtype":"grouped_light"}],"metadata":{"name":"Living room","archetype":"living_room"},"type":"room"}]`,
+		ResourceZone:         `[{"id":"8b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e","id_v1":"/groups/2","children":[{"rid":"` + testLightID + `","rtype":"light"}],"services":[],"metadata":{"name":"Reading","archetype":"reading"},"type":"zone"}]`,
+		ResourceGroupedLight: `[{"id":"7a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d","id_v1":"/groups/1","owner":{"rid":"6f1a2b3c-4d5e-4f6a-8b7c-9d0e1f2a3b4c","rtype":"room"},"on":{"on":false},"alert":{"action_values":["breathe"]},"type":"grouped_light"}]`,
+		ResourceScene:        `[{"id":"` + testSceneID + `","id_v1":"/scenes/3T2SvsxvwteNNys","actions":[{"target":{"rid":"` + testLightID + `","rtype":"light"},"action":{"on":{"on":true},"dimming":{"brightness":80.0},"color":{"xy":{"x":0.5,"y":0.4}}}}],"palette":{"color":[],"dimming":[],"color_temperature":[]},"metadata":{"name":"Relax"},"group":{"rid":"6f1a2b3c-4d5e-4f6a-8b7c-9d0e1f2a3b4c","rtype":"room"},"speed":0.5,"auto_dynamic":false,"status":{"active":"inactive"},"type":"scene"}]`,
+		ResourceDevice:       `[{"id":"f1d2b6c8-0a3e-4b5c-9d7e-8f6a5b4c3d21","id_v1":"/lights/1","product_data":{"model_id":"LCT015","manufacturer_name":"Signify Netherlands B.V.","product_name":"Hue color lamp","product_archetype":"sultan_bulb","certified":true,"software_version":"1.93.11"},"metadata":{"name":"Hue color lamp 1","archetype":"sultan_bulb"},"services":[{"rid":"` + testLightID + `","rtype":"light"}],"type":"device"}]`,
+		ResourceMotion:       `[{"id":"b6e8a0c2-3f4d-4e5a-9b8c-7d6e5f4a3b21","id_v1":"/sensors/5","owner":{"rid":"a1b2c3d4-e5f6-4a7b-8c9d-0e1f2a3b4c5d","rtype":"device"},"enabled":true,"motion":{"motion":false,"motion_valid":true},"type":"motion"}]`,
+		ResourceButton:       `[{"id":"c7f9b1d3-4a5e-4f6b-8c9d-8e7f6a5b4c32","id_v1":"/sensors/10","owner":{"rid":"b2c3d4e5-f6a7-4b8c-9d0e-1f2a3b4c5d6e","rtype":"device"},"metadata":{"control_id":1},"button":{"last_event":"short_release","event_values":["initial_press","repeat","short_release","long_release"]},"type":"button"}]`,
+		ResourceBridgeHome:   `[{"id":"d3e4f5a6-b7c8-4d9e-8f0a-1b2c3d4e5f6a","id_v1":"/groups/0","children":[{"rid":"6f1a2b3c-4d5e-4f6a-8b7c-9d0e1f2a3b4c","rtype":"room"}],"services":[{"rid":"e4f5a6b7-c8d9-4e0f-8a1b-2c3d4e5f6a7b","rtype":"grouped_light"}],"type":"bridge_home"}]`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "testuser", r.Header.Get(applicationKey))
+
+		var rtype, id string
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, resourcePath+"/"), "/")
+		rtype = parts[0]
+		if len(parts) > 1 {
+			id = parts[1]
+		}
+
+		if id == testUnknownID {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[{"description":"Not Found"}],"data":[]}`)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			if id != "" {
+				var all []json.RawMessage
+				_ = json.Unmarshal([]byte(resources[rtype]), &all)
+				fmt.Fprintf(w, `{"errors":[],"data":[%s]}`, all[0])
+				return
+			}
+			fmt.Fprintf(w, `{"errors":[],"data":%s}`, resources[rtype])
+		case http.MethodPut, http.MethodDelete:
+			fmt.Fprintf(w, `{"errors":[],"data":[{"rid":"%s","rtype":"%s"}]}`, id, rtype)
+		case http.MethodPost:
+			body, _ := ioutil.ReadAll(r.Body)
+			assert.Contains(t, string(body), `"name":"Focus"`)
+			fmt.Fprintf(w, `{"errors":[],"data":[{"rid":"%s","rtype":"%s"}]}`, testSceneID, rtype)
+		}
+	}))
+}
+
+func TestGetLightResources(t *testing.T) {
+	srv := newV2TestServer(t)
+	defer srv.Close()
+
+	b := NewWithClient(srv.URL, "testuser", srv.Client())
+
+	lights, err := b.GetLightResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, lights, 1)
+	l := lights[0]
+	assert.Equal(t, testLightID, l.ID)
+	assert.Equal(t, "/lights/1", l.IDV1)
+	assert.Equal(t, "Hue color lamp 1", l.Metadata.Name)
+	assert.True(t, l.On.On)
+	assert.Equal(t, 100.0, l.Dimming.Brightness)
+	assert.Nil(t, l.ColorTemperature.Mirek)
+	assert.Equal(t, uint16(500), l.ColorTemperature.MirekSchema.MirekMaximum)
+	assert.Equal(t, "C", l.Color.GamutType)
+	assert.Equal(t, 0.6915, l.Color.Gamut.Red.X)
+	assert.Equal(t, 5, l.Gradient.PointsCapable)
+
+	light, err := b.GetLightResource(testLightID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testLightID, light.ID)
+
+	_, err = b.GetLightResource(testUnknownID)
+	assert.EqualError(t, err, `ERROR: "Not Found"`)
+
+	_, err = b.GetLightResource("1")
+	assert.NotNil(t, err)
+}
+
+func TestUpdateLightResource(t *testing.T) {
+	srv := newV2TestServer(t)
+	defer srv.Close()
+
+	b := NewWithClient(srv.URL, "testuser", srv.Client())
+
+	ids, err := b.UpdateLightResource(testLightID, &LightResource{On: &ResourceOn{On: false}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []ResourceIdentifier{{RID: testLightID, RType: ResourceLight}}, ids)
+}
+
+func TestGetGroupResources(t *testing.T) {
+	srv := newV2TestServer(t)
+	defer srv.Close()
+
+	b := NewWithClient(srv.URL, "testuser", srv.Client())
+
+	rooms, err := b.GetRoomResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Living room", rooms[0].Metadata.Name)
+	assert.Equal(t, ResourceGroupedLight, rooms[0].Services[0].RType)
+
+	room, err := b.GetRoomResource(rooms[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/groups/1", room.IDV1)
+
+	zones, err := b.GetZoneResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ResourceLight, zones[0].Children[0].RType)
+
+	zone, err := b.GetZoneResource(zones[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Reading", zone.Metadata.Name)
+
+	grouped, err := b.GetGroupedLightResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, grouped[0].On.On)
+
+	g, err := b.GetGroupedLightResource(grouped[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ResourceRoom, g.Owner.RType)
+
+	_, err = b.UpdateGroupedLightResource(g.ID, &GroupedLightResource{On: &ResourceOn{On: true}})
+	assert.Nil(t, err)
+
+	home, err := b.GetBridgeHomeResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/groups/0", home[0].IDV1)
+}
+
+func TestSceneResources(t *testing.T) {
+	srv := newV2TestServer(t)
+	defer srv.Close()
+
+	b := NewWithClient(srv.URL, "testuser", srv.Client())
+
+	scenes, err := b.GetSceneResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Relax", scenes[0].Metadata.Name)
+	assert.Equal(t, 80.0, scenes[0].Actions[0].Action.Dimming.Brightness)
+
+	s, err := b.GetSceneResource(testSceneID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "inactive", s.Status.Active)
+
+	ids, err := b.CreateSceneResource(&SceneResource{Metadata: &ResourceMetadata{Name: "Focus"}, Group: s.Group, Actions: s.Actions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testSceneID, ids[0].RID)
+
+	_, err = b.UpdateSceneResource(testSceneID, &SceneResource{Speed: 0.8})
+	assert.Nil(t, err)
+
+	_, err = b.RecallSceneResource(testSceneID, "active")
+	assert.Nil(t, err)
+
+	err = b.DeleteSceneResource(testSceneID)
+	assert.Nil(t, err)
+
+	err = b.DeleteSceneResource(testUnknownID)
+	assert.NotNil(t, err)
+}
+
+func TestDeviceAndSensorResources(t *testing.T) {
+	srv := newV2TestServer(t)
+	defer srv.Close()
+
+	b := NewWithClient(srv.URL, "testuser", srv.Client())
+
+	devices, err := b.GetDeviceResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "LCT015", devices[0].ProductData.ModelID)
+
+	d, err := b.GetDeviceResource(devices[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, testLightID, d.Services[0].RID)
+
+	motion, err := b.GetMotionResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, *motion[0].Enabled)
+
+	m, err := b.GetMotionResource(motion[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, m.Motion.MotionValid)
+
+	buttons, err := b.GetButtonResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, buttons[0].Metadata.ControlID)
+
+	btn, err := b.GetButtonResource(buttons[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "short_release", btn.Button.LastEvent)
+}
+
+func TestIsResourceID(t *testing.T) {
+	assert.True(t, IsResourceID(testLightID))
+	assert.False(t, IsResourceID("1"))
+	assert.False(t, IsResourceID(""))
+}
+
+func Test_unmarshalV2Error(t *testing.T) {
+	err := unmarshalV2([]byte("not json"), nil)
+	assert.NotNil(t, err)
+
+	err = unmarshalV2([]byte(`{"errors":[]}`), nil)
+	assert.NotNil(t, err)
+}
+
+func Test_getV2Path(t *testing.T) {
+	b := New("192.168.1.59", "")
+	p, err := b.getV2Path(resourcePath, ResourceLight)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://192.168.1.59/clip/v2/resource/light", p)
+}
@@ -0,0 +1,85 @@
+// Command huego-exporter exposes the lights, groups, sensors and software update state of a Philips Hue bridge
+// as Prometheus metrics.
+//
+// Usage:
+//
+//	huego-exporter -host <host> -user <user> [-listen :9366] [-timeout 10s]
+//
+// The host and user default to $HUEGO_HOST and $HUEGO_USER. Metrics are served at /metrics.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/amimof/huego"
+	"github.com/amimof/huego/huegoprom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var errUsage = errors.New("usage")
+
+func main() {
+	err := run(os.Args[1:], os.Stderr)
+	if err == errUsage {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "huego-exporter: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stderr io.Writer) error {
+
+	fs := flag.NewFlagSet("huego-exporter", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	host := fs.String("host", os.Getenv("HUEGO_HOST"), "bridge host")
+	user := fs.String("user", os.Getenv("HUEGO_USER"), "bridge user")
+	listen := fs.String("listen", ":9366", "address to serve metrics on")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout of each scrape of the bridge")
+
+	err := fs.Parse(args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return errUsage
+	}
+
+	if *host == "" || *user == "" {
+		fmt.Fprintln(stderr, "huego-exporter: -host and -user are required")
+		fs.Usage()
+		return errUsage
+	}
+
+	return http.ListenAndServe(*listen, newHandler(huego.New(*host, *user), *timeout))
+}
+
+// newHandler returns a handler that serves the metrics of b at /metrics
+func newHandler(b *huego.Bridge, timeout time.Duration) http.Handler {
+
+	c := huegoprom.NewCollector(b)
+	c.Timeout = timeout
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, `<html><head><title>huego exporter</title></head><body><a href="/metrics">Metrics</a></body></html>`)
+	})
+
+	return mux
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/amimof/huego/huegotest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+
+	hue := huegotest.NewServer()
+	defer hue.Close()
+
+	srv := httptest.NewServer(newHandler(hue.Bridge(), time.Second))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), `hue_light_on{id="1",name="Hue color lamp 1",type="Extended color light"} 1`)
+	assert.Contains(t, string(body), `hue_scrape_success{resource="config"} 1`)
+
+	res, err = http.Get(srv.URL + "/missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestRunUsage(t *testing.T) {
+	var stderr bytes.Buffer
+	assert.Equal(t, errUsage, run([]string{"-host", "192.168.1.2", "-user", ""}, &stderr))
+	assert.Contains(t, stderr.String(), "-host and -user are required")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	configEnv      = "HUEGO_CONFIG"
+	defaultProfile = "default"
+)
+
+// Profile holds the address and user of one bridge
+type Profile struct {
+	Host string `yaml:"host"`
+	User string `yaml:"user"`
+	ID   string `yaml:"id,omitempty"`
+}
+
+// Config is the content of the configuration file. Current is the name of the profile used when none is given on the command line.
+type Config struct {
+	Current  string              `yaml:"current,omitempty"`
+	Profiles map[string]*Profile `yaml:"profiles"`
+	path     string
+}
+
+// defaultConfigPath returns the path of the configuration file, $HUEGO_CONFIG or huego/config.yaml in the user config directory
+func defaultConfigPath() string {
+	if p := os.Getenv(configEnv); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "huego.yaml"
+	}
+	return filepath.Join(dir, "huego", "config.yaml")
+}
+
+// loadConfig reads the configuration file at path. A missing file results in an empty configuration.
+func loadConfig(path string) (*Config, error) {
+
+	c := &Config{Profiles: map[string]*Profile{}, path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+
+	if c.Profiles == nil {
+		c.Profiles = map[string]*Profile{}
+	}
+
+	return c, nil
+}
+
+// save writes the configuration back to the file it was loaded from
+func (c *Config) save() error {
+
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.path, data, 0600)
+}
+
+// profile returns the profile called name, or the current profile if name is empty
+func (c *Config) profile(name string) (*Profile, error) {
+
+	if name == "" {
+		name = c.Current
+	}
+	if name == "" {
+		name = defaultProfile
+	}
+
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found, use the pair command to create it", name)
+	}
+
+	return p, nil
+}
+
+// names returns the names of all profiles in alphabetical order
+func (c *Config) names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for n := range c.Profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "huego", "config.yaml")
+
+	c, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, c.Profiles)
+
+	_, err = c.profile("")
+	assert.NotNil(t, err)
+
+	c.Profiles["office"] = &Profile{Host: "192.168.1.59", User: "30s1hrMbFs7Ag9hP"}
+	c.Profiles["default"] = &Profile{Host: "192.168.1.60", User: "83b7780291a6ceffb"}
+	assert.Nil(t, c.save())
+
+	c, err = loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"default", "office"}, c.names())
+
+	p, err := c.profile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "192.168.1.60", p.Host)
+
+	c.Current = "office"
+	p, err = c.profile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "192.168.1.59", p.Host)
+
+	p, err = c.profile("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "192.168.1.60", p.Host)
+}
+
+func TestConfigInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("profiles: [1, 2"), 0600))
+	_, err := loadConfig(path)
+	assert.NotNil(t, err)
+}
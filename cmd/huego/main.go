@@ -0,0 +1,368 @@
+// Command huego is a command line interface to the Philips Hue bridge.
+//
+// Usage:
+//
+//	huego [flags] discover [-local]
+//	huego [flags] pair [-devicetype name] <host>
+//	huego [flags] profiles
+//	huego [flags] use <profile>
+//	huego [flags] apply [-dry-run] <file>
+//	huego [flags] <resource> list
+//	huego [flags] <resource> get <id>
+//	huego [flags] <resource> set <id> key=value...
+//
+// Resources are lights, groups, scenes, sensors, rules, schedules and resourcelinks. set only sends the given
+// attributes and converts each value to the type of the attribute, lists are comma separated and objects JSON.
+// The host and user of each bridge are stored as named profiles in the configuration file.
+// pair waits for the link button on the bridge to be pressed until -timeout expires, 1 minute unless given.
+// apply prints the changes needed to make the bridge match a YAML or JSON spec, see huego.Spec, and makes them.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amimof/huego"
+)
+
+const defaultDeviceType = "huego#cli"
+
+// pairTimeout is the default timeout of pair, which leaves time to walk to the bridge and press the link button
+const pairTimeout = time.Minute
+
+var errUsage = errors.New("usage")
+
+// options holds the global flags
+type options struct {
+	config  string
+	profile string
+	host    string
+	user    string
+	output  string
+	timeout time.Duration
+}
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr)
+	if err == errUsage {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "huego: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+
+	opts := &options{}
+
+	fs := flag.NewFlagSet("huego", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.config, "config", defaultConfigPath(), "path to the configuration file")
+	fs.StringVar(&opts.profile, "profile", "", "name of the bridge profile to use, defaults to the current profile")
+	fs.StringVar(&opts.host, "host", "", "bridge host, overrides the profile")
+	fs.StringVar(&opts.user, "user", "", "bridge user, overrides the profile")
+	fs.StringVar(&opts.output, "o", outputTable, "output format, one of table, json or yaml")
+	fs.DurationVar(&opts.timeout, "timeout", 10*time.Second, "timeout of the whole command, pair defaults to 1m")
+	fs.Usage = func() { usage(fs) }
+
+	err := fs.Parse(args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return errUsage
+	}
+
+	if fs.NArg() == 0 {
+		usage(fs)
+		return errUsage
+	}
+
+	p, err := newPrinter(stdout, opts.output)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(opts.config)
+	if err != nil {
+		return err
+	}
+
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout(fs, opts, cmd))
+	defer cancel()
+
+	switch cmd {
+	case "discover":
+		return discover(ctx, p, rest)
+	case "pair":
+		return pair(ctx, cfg, opts, stdout, rest)
+	case "profiles":
+		return profiles(cfg, p)
+	case "use":
+		return use(cfg, rest)
+	}
+
+	r, ok := resources[cmd]
+	if !ok && cmd != "apply" {
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+
+	b, err := bridge(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	if cmd == "apply" {
+		return apply(ctx, b, stdout, rest)
+	}
+
+	return resourceCommand(ctx, b, r, p, rest)
+}
+
+// commandTimeout returns -timeout, or pairTimeout for pair unless -timeout is given
+func commandTimeout(fs *flag.FlagSet, opts *options, cmd string) time.Duration {
+	if cmd != "pair" {
+		return opts.timeout
+	}
+	timeout := pairTimeout
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "timeout" {
+			timeout = opts.timeout
+		}
+	})
+	return timeout
+}
+
+func usage(fs *flag.FlagSet) {
+	names := make([]string, 0, len(resources))
+	for n := range resources {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	w := fs.Output()
+	fmt.Fprintf(w, "Usage:\n")
+	fmt.Fprintf(w, "  huego [flags] discover [-local]\n")
+	fmt.Fprintf(w, "  huego [flags] pair [-devicetype name] <host>\n")
+	fmt.Fprintf(w, "  huego [flags] profiles\n")
+	fmt.Fprintf(w, "  huego [flags] use <profile>\n")
+	fmt.Fprintf(w, "  huego [flags] apply [-dry-run] <file>\n")
+	fmt.Fprintf(w, "  huego [flags] <resource> list|get <id>|set <id> key=value...\n\n")
+	fmt.Fprintf(w, "Resources: %s\n\nFlags:\n", strings.Join(names, ", "))
+	fs.PrintDefaults()
+}
+
+// bridge returns a bridge for the selected profile. Host and user given as flags take precedence.
+func bridge(cfg *Config, opts *options) (*huego.Bridge, error) {
+
+	host, user := opts.host, opts.user
+
+	if host == "" || user == "" {
+		p, err := cfg.profile(opts.profile)
+		if err != nil {
+			return nil, err
+		}
+		if host == "" {
+			host = p.Host
+		}
+		if user == "" {
+			user = p.User
+		}
+	}
+
+	return huego.New(host, user), nil
+}
+
+func resourceCommand(ctx context.Context, b *huego.Bridge, r *resource, p *printer, args []string) error {
+
+	if len(args) == 0 {
+		return errors.New("missing action, must be one of list, get or set")
+	}
+
+	switch args[0] {
+	case "list":
+		v, t, err := r.list(ctx, b)
+		if err != nil {
+			return err
+		}
+		return p.print(v, t)
+	case "get":
+		if len(args) != 2 {
+			return errors.New("get requires exactly one id")
+		}
+		v, err := r.get(ctx, b, args[1])
+		if err != nil {
+			return err
+		}
+		return p.print(v, nil)
+	case "set":
+		if len(args) < 3 {
+			return errors.New("set requires an id and at least one key=value attribute")
+		}
+		attrs, err := parseAttributes(args[2:])
+		if err != nil {
+			return err
+		}
+		resp, err := r.set(ctx, b, args[1], attrs)
+		if err != nil {
+			return err
+		}
+		return p.print(resp.Success, nil)
+	}
+
+	return fmt.Errorf("unknown action %q, must be one of list, get or set", args[0])
+}
+
+func discover(ctx context.Context, p *printer, args []string) error {
+
+	fs := flag.NewFlagSet("discover", flag.ContinueOnError)
+	local := fs.Bool("local", false, "only use mDNS and SSDP, for networks without internet access")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	strategies := huego.AllDiscovery()
+	if *local {
+		strategies = huego.LocalDiscovery()
+	}
+
+	bridges, err := huego.DiscoverAllUsing(ctx, strategies...)
+	if err != nil {
+		return err
+	}
+
+	t := &table{header: []string{"ID", "HOST"}}
+	for _, b := range bridges {
+		t.rows = append(t.rows, []string{b.ID, b.Host})
+	}
+
+	return p.print(bridges, t)
+}
+
+func pair(ctx context.Context, cfg *Config, opts *options, stdout io.Writer, args []string) error {
+
+	fs := flag.NewFlagSet("pair", flag.ContinueOnError)
+	deviceType := fs.String("devicetype", defaultDeviceType, "device type registered on the bridge")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	host := opts.host
+	if fs.NArg() > 0 {
+		host = fs.Arg(0)
+	}
+	if host == "" {
+		return errors.New("pair requires the host of the bridge")
+	}
+
+	name := opts.profile
+	if name == "" {
+		name = defaultProfile
+	}
+
+	b, _, err := huego.New(host, "").Pair(ctx, *deviceType, &huego.PairOptions{
+		Progress: func(attempt int) {
+			if attempt == 1 {
+				fmt.Fprintln(stdout, "Press the link button on the bridge")
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.Profiles[name] = &Profile{Host: host, User: b.User}
+	if cfg.Current == "" {
+		cfg.Current = name
+	}
+
+	err = cfg.save()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Paired with %s, saved as profile %q\n", host, name)
+
+	return nil
+}
+
+func apply(ctx context.Context, b *huego.Bridge, stdout io.Writer, args []string) error {
+
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only print the changes")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return errors.New("apply requires the path of a spec")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	spec, err := huego.ReadSpec(f)
+	if err != nil {
+		return err
+	}
+
+	plan, err := b.Plan(ctx, spec)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(stdout, plan)
+
+	if *dryRun || len(plan.Changes) == 0 {
+		return nil
+	}
+
+	err = b.Apply(ctx, plan)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Applied %d changes\n", len(plan.Changes))
+
+	return nil
+}
+
+func profiles(cfg *Config, p *printer) error {
+	t := &table{header: []string{"CURRENT", "NAME", "HOST"}}
+	for _, n := range cfg.names() {
+		current := ""
+		if n == cfg.Current {
+			current = "*"
+		}
+		t.rows = append(t.rows, []string{current, n, cfg.Profiles[n].Host})
+	}
+	return p.print(cfg.Profiles, t)
+}
+
+func use(cfg *Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("use requires the name of a profile")
+	}
+	if _, ok := cfg.Profiles[args[0]]; !ok {
+		return fmt.Errorf("profile %q not found", args[0])
+	}
+	cfg.Current = args[0]
+	return cfg.save()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amimof/huego/huegotest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestBridge returns a bridge stand-in and a pointer to the body of the last PUT or POST request
+func newTestBridge(t *testing.T) (*httptest.Server, *map[string]interface{}) {
+
+	last := map[string]interface{}{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodPut || r.Method == http.MethodPost {
+			data, _ := ioutil.ReadAll(r.Body)
+			last = map[string]interface{}{}
+			_ = json.Unmarshal(data, &last)
+		}
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api":
+			w.Write([]byte(`[{"success":{"username":"83b7780291a6ceffbe0bd049104df"}}]`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/testuser/lights":
+			w.Write([]byte(`{"2":{"name":"Kitchen","type":"Extended color light","state":{"on":false,"bri":1,"reachable":true}},"1":{"name":"Hallway","type":"Dimmable light","state":{"on":true,"bri":254,"reachable":true}}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/testuser/lights/1":
+			w.Write([]byte(`{"name":"Hallway","type":"Dimmable light","state":{"on":true,"bri":254,"reachable":true}}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/api/testuser/lights/1/state":
+			w.Write([]byte(`[{"success":{"/lights/1/state/bri":100}}]`))
+		case r.Method == http.MethodPut && r.URL.Path == "/api/testuser/scenes/abc":
+			w.Write([]byte(`[{"success":{"/scenes/abc/name":"2020"}}]`))
+		default:
+			w.Write([]byte(`[{"error":{"type":3,"address":"` + r.URL.Path + `","description":"resource, ` + r.URL.Path + `, not available"}}]`))
+		}
+	}))
+
+	return srv, &last
+}
+
+func TestPairAndProfiles(t *testing.T) {
+
+	srv, _ := newTestBridge(t)
+	defer srv.Close()
+
+	cfg := filepath.Join(t.TempDir(), "huego", "config.yaml")
+
+	var out bytes.Buffer
+	err := run(context.Background(), []string{"-config", cfg, "-profile", "home", "pair", srv.URL}, &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, out.String(), `saved as profile "home"`)
+
+	c, err := loadConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "home", c.Current)
+	assert.Equal(t, &Profile{Host: srv.URL, User: "83b7780291a6ceffbe0bd049104df"}, c.Profiles["home"])
+
+	out.Reset()
+	err = run(context.Background(), []string{"-config", cfg, "profiles"}, &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, out.String(), "*        home  "+srv.URL)
+
+	err = run(context.Background(), []string{"-config", cfg, "use", "office"}, &out, &out)
+	assert.NotNil(t, err)
+}
+
+func TestLights(t *testing.T) {
+
+	srv, last := newTestBridge(t)
+	defer srv.Close()
+
+	cfg := filepath.Join(t.TempDir(), "config.yaml")
+	flags := []string{"-config", cfg, "-host", srv.URL, "-user", "testuser"}
+
+	var out bytes.Buffer
+	err := run(context.Background(), append(flags, "lights", "list"), &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "ID"))
+	assert.True(t, strings.HasPrefix(lines[1], "1   Hallway"))
+	assert.True(t, strings.HasPrefix(lines[2], "2   Kitchen"))
+
+	out.Reset()
+	err = run(context.Background(), append(flags, "-o", "json", "lights", "get", "1"), &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &l))
+	assert.Equal(t, "Hallway", l["name"])
+
+	out.Reset()
+	err = run(context.Background(), append(flags, "-o", "yaml", "lights", "set", "1", "bri=100"), &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/lights/1/state/bri: 100\n", out.String())
+	assert.Equal(t, map[string]interface{}{"bri": float64(100)}, *last)
+
+	err = run(context.Background(), append(flags, "lights", "set", "1", "brightness=100"), &out, &out)
+	assert.NotNil(t, err)
+
+	err = run(context.Background(), append(flags, "lights", "get", "3"), &out, &out)
+	assert.NotNil(t, err)
+
+	err = run(context.Background(), append(flags, "lights", "get", "one"), &out, &out)
+	assert.NotNil(t, err)
+
+	err = run(context.Background(), append(flags, "lights", "toggle", "1"), &out, &out)
+	assert.NotNil(t, err)
+}
+
+func TestScenes(t *testing.T) {
+
+	srv, last := newTestBridge(t)
+	defer srv.Close()
+
+	cfg := filepath.Join(t.TempDir(), "config.yaml")
+	flags := []string{"-config", cfg, "-host", srv.URL, "-user", "testuser"}
+
+	// Only the given attributes are sent and names that look like numbers stay strings
+	var out bytes.Buffer
+	err := run(context.Background(), append(flags, "-o", "yaml", "scenes", "set", "abc", "name=2020"), &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/scenes/abc/name: \"2020\"\n", out.String())
+	assert.Equal(t, map[string]interface{}{"name": "2020"}, *last)
+
+	err = run(context.Background(), append(flags, "scenes", "set", "def", "name=2020"), &out, &out)
+	assert.NotNil(t, err)
+}
+
+func TestRunErrors(t *testing.T) {
+
+	cfg := filepath.Join(t.TempDir(), "config.yaml")
+
+	var out bytes.Buffer
+	assert.Equal(t, errUsage, run(context.Background(), []string{}, &out, &out))
+	assert.Equal(t, errUsage, run(context.Background(), []string{"-unknown"}, &out, &out))
+	assert.NotNil(t, run(context.Background(), []string{"-config", cfg, "bulbs", "list"}, &out, &out))
+	assert.NotNil(t, run(context.Background(), []string{"-config", cfg, "-o", "xml", "lights", "list"}, &out, &out))
+	assert.NotNil(t, run(context.Background(), []string{"-config", cfg, "lights", "list"}, &out, &out))
+	assert.NotNil(t, run(context.Background(), []string{"-config", cfg, "pair"}, &out, &out))
+}
+
+func TestApply(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	spec := filepath.Join(dir, "office.yaml")
+	err := ioutil.WriteFile(spec, []byte("owner: office\ngroups:\n  - name: Desk\n    lights: [\"{Hue color lamp 1}\"]\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := []string{"-config", filepath.Join(dir, "config.yaml"), "-host", srv.URL, "-user", huegotest.User}
+
+	var out bytes.Buffer
+	err = run(context.Background(), append(flags, "apply", "-dry-run", spec), &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "+ groups \"Desk\"\n+ resourcelinks \"office\"\n", out.String())
+
+	out.Reset()
+	err = run(context.Background(), append(flags, "apply", spec), &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, out.String(), "Applied 2 changes")
+
+	out.Reset()
+	err = run(context.Background(), append(flags, "apply", spec), &out, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "No changes\n", out.String())
+
+	assert.NotNil(t, run(context.Background(), append(flags, "apply"), &out, &out))
+}
+
+func TestCommandTimeout(t *testing.T) {
+
+	opts := &options{}
+	fs := flag.NewFlagSet("huego", flag.ContinueOnError)
+	fs.DurationVar(&opts.timeout, "timeout", 10*time.Second, "")
+
+	assert.Nil(t, fs.Parse([]string{"pair"}))
+	assert.Equal(t, pairTimeout, commandTimeout(fs, opts, "pair"))
+	assert.Equal(t, 10*time.Second, commandTimeout(fs, opts, "lights"))
+
+	assert.Nil(t, fs.Parse([]string{"-timeout", "5s", "pair"}))
+	assert.Equal(t, 5*time.Second, commandTimeout(fs, opts, "pair"))
+}
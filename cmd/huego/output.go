@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// table is a list of rows printed with aligned columns
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// printer writes values to w in the selected output format. Tables are only used for
+// list output, single objects are printed as yaml when the table format is selected.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case outputTable, outputJSON, outputYAML:
+		return &printer{w: w, format: format}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, must be one of table, json or yaml", format)
+}
+
+// print writes v. t is used when the output format is table and may be nil.
+func (p *printer) print(v interface{}, t *table) error {
+	switch {
+	case p.format == outputJSON:
+		return p.json(v)
+	case p.format == outputTable && t != nil:
+		return p.table(t)
+	}
+	return p.yaml(v)
+}
+
+func (p *printer) json(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(p.w, string(data))
+	return err
+}
+
+// yaml writes v as yaml. v is converted through json first so that keys
+// match the json tags of the huego types and the bridge API.
+func (p *printer) yaml(v interface{}) error {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var generic interface{}
+	err = yaml.Unmarshal(data, &generic)
+	if err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(generic)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.w.Write(out)
+	return err
+}
+
+func (p *printer) table(t *table) error {
+	w := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.header, "\t"))
+	for _, r := range t.rows {
+		fmt.Fprintln(w, strings.Join(r, "\t"))
+	}
+	return w.Flush()
+}
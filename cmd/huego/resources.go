@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amimof/huego"
+)
+
+// resource describes how the list, get and set commands operate on one kind of bridge resource
+type resource struct {
+	list func(ctx context.Context, b *huego.Bridge) (interface{}, *table, error)
+	get  func(ctx context.Context, b *huego.Bridge, id string) (interface{}, error)
+	set  func(ctx context.Context, b *huego.Bridge, id string, attrs map[string]string) (*huego.Response, error)
+}
+
+var resources = map[string]*resource{
+	"lights": {
+		list: func(ctx context.Context, b *huego.Bridge) (interface{}, *table, error) {
+			lights, err := b.GetLightsContext(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			sort.Slice(lights, func(i, j int) bool { return lights[i].ID < lights[j].ID })
+			t := &table{header: []string{"ID", "NAME", "TYPE", "ON", "BRI", "REACHABLE"}}
+			for _, l := range lights {
+				s := l.State
+				if s == nil {
+					s = &huego.State{}
+				}
+				t.rows = append(t.rows, []string{strconv.Itoa(l.ID), l.Name, l.Type, strconv.FormatBool(s.On), strconv.Itoa(int(s.Bri)), strconv.FormatBool(s.Reachable)})
+			}
+			return lights, t, nil
+		},
+		get: func(ctx context.Context, b *huego.Bridge, id string) (interface{}, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			return b.GetLightContext(ctx, i)
+		},
+		set: func(ctx context.Context, b *huego.Bridge, id string, attrs map[string]string) (*huego.Response, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			if name, ok := attrs["name"]; ok {
+				delete(attrs, "name")
+				resp, err := b.UpdateLightContext(ctx, i, huego.Light{Name: name})
+				if err != nil || len(attrs) == 0 {
+					return resp, err
+				}
+			}
+			s := huego.NewStateUpdate()
+			err = convertState(attrs, s)
+			if err != nil {
+				return nil, err
+			}
+			return b.SetLightStateContext(ctx, i, s)
+		},
+	},
+	"groups": {
+		list: func(ctx context.Context, b *huego.Bridge) (interface{}, *table, error) {
+			groups, err := b.GetGroupsContext(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+			t := &table{header: []string{"ID", "NAME", "TYPE", "CLASS", "LIGHTS", "ANY ON", "ALL ON"}}
+			for _, g := range groups {
+				s := g.GroupState
+				if s == nil {
+					s = &huego.GroupState{}
+				}
+				t.rows = append(t.rows, []string{strconv.Itoa(g.ID), g.Name, g.Type, g.Class, strings.Join(g.Lights, ","), strconv.FormatBool(s.AnyOn), strconv.FormatBool(s.AllOn)})
+			}
+			return groups, t, nil
+		},
+		get: func(ctx context.Context, b *huego.Bridge, id string) (interface{}, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			return b.GetGroupContext(ctx, i)
+		},
+		set: func(ctx context.Context, b *huego.Bridge, id string, attrs map[string]string) (*huego.Response, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			if name, ok := attrs["name"]; ok {
+				delete(attrs, "name")
+				resp, err := b.UpdateGroupContext(ctx, i, huego.Group{Name: name})
+				if err != nil || len(attrs) == 0 {
+					return resp, err
+				}
+			}
+			s := huego.NewStateUpdate()
+			err = convertState(attrs, s)
+			if err != nil {
+				return nil, err
+			}
+			return b.SetGroupStateContext(ctx, i, s)
+		},
+	},
+	"scenes": {
+		list: func(ctx context.Context, b *huego.Bridge) (interface{}, *table, error) {
+			scenes, err := b.GetScenesContext(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			sort.Slice(scenes, func(i, j int) bool { return scenes[i].ID < scenes[j].ID })
+			t := &table{header: []string{"ID", "NAME", "TYPE", "GROUP", "LIGHTS"}}
+			for _, s := range scenes {
+				t.rows = append(t.rows, []string{s.ID, s.Name, s.Type, s.Group, strings.Join(s.Lights, ",")})
+			}
+			return scenes, t, nil
+		},
+		get: func(ctx context.Context, b *huego.Bridge, id string) (interface{}, error) {
+			return b.GetSceneContext(ctx, id)
+		},
+		set: func(ctx context.Context, b *huego.Bridge, id string, attrs map[string]string) (*huego.Response, error) {
+			typed, err := typedAttributes(attrs, &huego.Scene{})
+			if err != nil {
+				return nil, err
+			}
+			return update(ctx, b, typed, "scenes", id)
+		},
+	},
+	"sensors": {
+		list: func(ctx context.Context, b *huego.Bridge) (interface{}, *table, error) {
+			sensors, err := b.GetSensorsContext(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			sort.Slice(sensors, func(i, j int) bool { return sensors[i].ID < sensors[j].ID })
+			t := &table{header: []string{"ID", "NAME", "TYPE", "MODEL"}}
+			for _, s := range sensors {
+				t.rows = append(t.rows, []string{strconv.Itoa(s.ID), s.Name, s.Type, s.ModelID})
+			}
+			return sensors, t, nil
+		},
+		get: func(ctx context.Context, b *huego.Bridge, id string) (interface{}, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			return b.GetSensorContext(ctx, i)
+		},
+		set: func(ctx context.Context, b *huego.Bridge, id string, attrs map[string]string) (*huego.Response, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			if name, ok := attrs["name"]; ok {
+				delete(attrs, "name")
+				resp, err := b.UpdateSensorContext(ctx, i, &huego.Sensor{Name: name})
+				if err != nil || len(attrs) == 0 {
+					return resp, err
+				}
+			}
+			s, err := b.GetSensorContext(ctx, i)
+			if err != nil {
+				return nil, err
+			}
+			typed, err := configAttributes(attrs, s.Config)
+			if err != nil {
+				return nil, err
+			}
+			return b.UpdateSensorConfigContext(ctx, i, typed)
+		},
+	},
+	"rules": {
+		list: func(ctx context.Context, b *huego.Bridge) (interface{}, *table, error) {
+			rules, err := b.GetRulesContext(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+			t := &table{header: []string{"ID", "NAME", "STATUS", "TRIGGERED", "LAST TRIGGERED"}}
+			for _, r := range rules {
+				t.rows = append(t.rows, []string{strconv.Itoa(r.ID), r.Name, r.Status, strconv.Itoa(r.TimesTriggered), r.LastTriggered})
+			}
+			return rules, t, nil
+		},
+		get: func(ctx context.Context, b *huego.Bridge, id string) (interface{}, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			return b.GetRuleContext(ctx, i)
+		},
+		set: func(ctx context.Context, b *huego.Bridge, id string, attrs map[string]string) (*huego.Response, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			typed, err := typedAttributes(attrs, &huego.Rule{})
+			if err != nil {
+				return nil, err
+			}
+			return update(ctx, b, typed, "rules", strconv.Itoa(i))
+		},
+	},
+	"schedules": {
+		list: func(ctx context.Context, b *huego.Bridge) (interface{}, *table, error) {
+			schedules, err := b.GetSchedulesContext(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+			t := &table{header: []string{"ID", "NAME", "STATUS", "LOCALTIME"}}
+			for _, s := range schedules {
+				t.rows = append(t.rows, []string{strconv.Itoa(s.ID), s.Name, s.Status, s.LocalTime})
+			}
+			return schedules, t, nil
+		},
+		get: func(ctx context.Context, b *huego.Bridge, id string) (interface{}, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			return b.GetScheduleContext(ctx, i)
+		},
+		set: func(ctx context.Context, b *huego.Bridge, id string, attrs map[string]string) (*huego.Response, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			typed, err := typedAttributes(attrs, &huego.Schedule{})
+			if err != nil {
+				return nil, err
+			}
+			return update(ctx, b, typed, "schedules", strconv.Itoa(i))
+		},
+	},
+	"resourcelinks": {
+		list: func(ctx context.Context, b *huego.Bridge) (interface{}, *table, error) {
+			links, err := b.GetResourcelinksContext(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			sort.Slice(links, func(i, j int) bool { return links[i].ID < links[j].ID })
+			t := &table{header: []string{"ID", "NAME", "TYPE", "CLASS", "LINKS"}}
+			for _, r := range links {
+				t.rows = append(t.rows, []string{strconv.Itoa(r.ID), r.Name, r.Type, strconv.Itoa(int(r.ClassID)), strconv.Itoa(len(r.Links))})
+			}
+			return links, t, nil
+		},
+		get: func(ctx context.Context, b *huego.Bridge, id string) (interface{}, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			return b.GetResourcelinkContext(ctx, i)
+		},
+		set: func(ctx context.Context, b *huego.Bridge, id string, attrs map[string]string) (*huego.Response, error) {
+			i, err := intID(id)
+			if err != nil {
+				return nil, err
+			}
+			typed, err := typedAttributes(attrs, &huego.Resourcelink{})
+			if err != nil {
+				return nil, err
+			}
+			return update(ctx, b, typed, "resourcelinks", strconv.Itoa(i))
+		},
+	},
+}
+
+func intID(id string) (int, error) {
+	i, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q, must be a number", id)
+	}
+	return i, nil
+}
+
+// parseAttributes parses key=value arguments. Values are kept as strings until the attribute they are
+// set on is known, see typedAttributes.
+func parseAttributes(args []string) (map[string]string, error) {
+
+	attrs := map[string]string{}
+
+	for _, a := range args {
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid attribute %q, must be key=value", a)
+		}
+		attrs[kv[0]] = kv[1]
+	}
+
+	if len(attrs) == 0 {
+		return nil, fmt.Errorf("no attributes given")
+	}
+
+	return attrs, nil
+}
+
+// typedAttributes converts the values in attrs to the types of the fields of the struct v points to,
+// matching keys by json name. Keys that v has no field for are rejected.
+func typedAttributes(attrs map[string]string, v interface{}) (map[string]interface{}, error) {
+
+	t := reflect.TypeOf(v).Elem()
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.PkgPath != "" || name == "" || name == "-" {
+			continue
+		}
+		fields[name] = f.Type
+	}
+
+	typed := make(map[string]interface{}, len(attrs))
+	for k, raw := range attrs {
+		ft, ok := fields[k]
+		if !ok {
+			return nil, fmt.Errorf("unknown attribute %q", k)
+		}
+		val, err := parseValue(raw, ft)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s: %v", raw, k, err)
+		}
+		typed[k] = val
+	}
+
+	return typed, nil
+}
+
+// configAttributes converts the values in attrs to the types of the current values in config. Keys that
+// config doesn't have are rejected since the allowed attributes depend on the sensor type.
+func configAttributes(attrs map[string]string, config map[string]interface{}) (map[string]interface{}, error) {
+
+	typed := make(map[string]interface{}, len(attrs))
+	for k, raw := range attrs {
+		cur, ok := config[k]
+		if !ok {
+			return nil, fmt.Errorf("unknown attribute %q", k)
+		}
+		t := reflect.TypeOf(cur)
+		if t == nil {
+			t = reflect.TypeOf(&cur).Elem()
+		}
+		val, err := parseValue(raw, t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s: %v", raw, k, err)
+		}
+		typed[k] = val
+	}
+
+	return typed, nil
+}
+
+// parseValue converts raw to a value of type t. Slices of strings, booleans and numbers are given as
+// comma separated lists, other composite types as JSON.
+func parseValue(raw string, t reflect.Type) (interface{}, error) {
+	v := reflect.New(t).Elem()
+	err := setValue(v, raw)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		return setValue(v.Elem(), raw)
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer of %d bits", v.Type().Bits())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a positive integer of %d bits", v.Type().Bits())
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return errors.New("must be a finite number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if basic(v.Type().Elem()) {
+			parts := []string{}
+			if raw != "" {
+				parts = strings.Split(raw, ",")
+			}
+			s := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+			for i, p := range parts {
+				err := setValue(s.Index(i), strings.TrimSpace(p))
+				if err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		}
+		return json.Unmarshal([]byte(raw), v.Addr().Interface())
+	default:
+		return json.Unmarshal([]byte(raw), v.Addr().Interface())
+	}
+
+	return nil
+}
+
+// basic reports whether t is a string, boolean or number
+func basic(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// convertState sets the attributes in attrs on s so that only the given attributes are sent
+func convertState(attrs map[string]string, s *huego.StateUpdate) error {
+	typed, err := typedAttributes(attrs, s)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(typed)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, s)
+}
+
+// update sends attrs to the resource at the path made of elem. The update methods of huego.Bridge marshal whole structs,
+// which would also send attributes the user didn't give, such as the recycle flag of a scene.
+func update(ctx context.Context, b *huego.Bridge, attrs map[string]interface{}, elem ...string) (*huego.Response, error) {
+
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	host := b.Host
+	if !strings.HasPrefix(strings.ToLower(host), "http://") && !strings.HasPrefix(strings.ToLower(host), "https://") {
+		host = "http://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(append([]string{u.Path, "/api/", b.User}, elem...)...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var a []*huego.APIResponse
+	err = json.NewDecoder(res.Body).Decode(&a)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &huego.Response{Success: map[string]interface{}{}}
+	var errs huego.APIErrors
+	for _, r := range a {
+		if r == nil {
+			continue
+		}
+		for k, v := range r.Success {
+			resp.Success[k] = v
+		}
+		if r.Error != nil {
+			errs = append(errs, r.Error)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return resp, nil
+	case 1:
+		return nil, errs[0]
+	}
+	return nil, errs
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/amimof/huego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAttributes(t *testing.T) {
+
+	attrs, err := parseAttributes([]string{"on=false", "bri=128", "xy=0.3,0.4", "name=Living room", "effect=colorloop", "class="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]string{
+		"on":     "false",
+		"bri":    "128",
+		"xy":     "0.3,0.4",
+		"name":   "Living room",
+		"effect": "colorloop",
+		"class":  "",
+	}, attrs)
+
+	_, err = parseAttributes([]string{"bri"})
+	assert.NotNil(t, err)
+
+	_, err = parseAttributes([]string{"=1"})
+	assert.NotNil(t, err)
+}
+
+func TestTypedAttributes(t *testing.T) {
+
+	// Values keep the type of the field they are set on
+	typed, err := typedAttributes(map[string]string{"name": "007", "lights": "1,2", "transitiontime": "4"}, &huego.Scene{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"name": "007", "lights": []string{"1", "2"}, "transitiontime": uint16(4)}, typed)
+
+	typed, err = typedAttributes(map[string]string{"name": "1e3", "conditions": `[{"address":"/sensors/2/state/buttonevent","operator":"dx"}]`}, &huego.Rule{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "1e3", typed["name"])
+	assert.Equal(t, []*huego.Condition{{Address: "/sensors/2/state/buttonevent", Operator: "dx"}}, typed["conditions"])
+
+	for _, attrs := range []map[string]string{
+		{"nmae": "Hallway"},
+		{"transitiontime": "-1"},
+		{"transitiontime": "1e3"},
+		{"conditions": "dx"},
+	} {
+		_, err = typedAttributes(attrs, &huego.Scene{})
+		assert.NotNil(t, err, "%v", attrs)
+	}
+
+	_, err = typedAttributes(map[string]string{"xy_inc": "Inf"}, &huego.StateUpdate{})
+	assert.NotNil(t, err)
+
+	typed, err = configAttributes(map[string]string{"on": "false", "sunriseoffset": "30", "long": "18.0"}, map[string]interface{}{"on": true, "sunriseoffset": float64(0), "long": "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"on": false, "sunriseoffset": float64(30), "long": "18.0"}, typed)
+
+	_, err = configAttributes(map[string]string{"ledindication": "true"}, map[string]interface{}{"on": true})
+	assert.NotNil(t, err)
+}
+
+func TestConvertState(t *testing.T) {
+
+	s := huego.NewStateUpdate()
+	err := convertState(map[string]string{"bri": "128", "xy": "0.3,0.4"}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, huego.NewStateUpdate().SetBri(128).SetXy(0.3, 0.4), s)
+
+	// Zero values are kept
+	s = huego.NewStateUpdate()
+	err = convertState(map[string]string{"on": "false", "hue": "0", "bri": "1", "transitiontime": "0"}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, huego.NewStateUpdate().SetOn(false).SetHue(0).SetBri(1).SetTransitionTime(0), s)
+
+	err = convertState(map[string]string{"bri": "bright"}, s)
+	assert.NotNil(t, err)
+
+	err = convertState(map[string]string{"brightness": "128"}, s)
+	assert.NotNil(t, err)
+}
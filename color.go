@@ -0,0 +1,332 @@
+package huego
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Color gamuts of Hue lights, https://developers.meethue.com/develop/hue-api/supported-devices/
+var (
+	// GamutA is used by LivingColors and early Friends of Hue lights such as Bloom and Iris
+	GamutA = Gamut{Type: "A", Red: [2]float32{0.704, 0.296}, Green: [2]float32{0.2151, 0.7106}, Blue: [2]float32{0.138, 0.08}}
+
+	// GamutB is used by first generation Hue bulbs
+	GamutB = Gamut{Type: "B", Red: [2]float32{0.675, 0.322}, Green: [2]float32{0.409, 0.518}, Blue: [2]float32{0.167, 0.04}}
+
+	// GamutC is used by Hue bulbs and lightstrips from 2015 and onwards
+	GamutC = Gamut{Type: "C", Red: [2]float32{0.6915, 0.3083}, Green: [2]float32{0.17, 0.7}, Blue: [2]float32{0.1532, 0.0475}}
+)
+
+// whitePoint is the xy coordinate of D65, used for colors without chromaticity such as black
+var whitePoint = []float32{0.3127, 0.329}
+
+// Gamuts of lights that don't report their gamut in capabilities, by model id
+var modelGamuts = map[string]*Gamut{
+	"LST001": &GamutA, "LLC005": &GamutA, "LLC006": &GamutA, "LLC007": &GamutA, "LLC010": &GamutA,
+	"LLC011": &GamutA, "LLC012": &GamutA, "LLC013": &GamutA, "LLC014": &GamutA,
+	"LCT001": &GamutB, "LCT002": &GamutB, "LCT003": &GamutB, "LCT007": &GamutB, "LLM001": &GamutB,
+	"LCT010": &GamutC, "LCT011": &GamutC, "LCT012": &GamutC, "LCT014": &GamutC, "LCT015": &GamutC,
+	"LCT016": &GamutC, "LLC020": &GamutC, "LST002": &GamutC,
+}
+
+// GamutByType returns the gamut with type t, A, B or C. nil is returned for unknown types.
+func GamutByType(t string) *Gamut {
+	switch strings.ToUpper(t) {
+	case "A":
+		g := GamutA
+		return &g
+	case "B":
+		g := GamutB
+		return &g
+	case "C":
+		g := GamutC
+		return &g
+	}
+	return nil
+}
+
+// Contains returns true if xy is inside the gamut
+func (g *Gamut) Contains(xy []float32) bool {
+
+	if len(xy) != 2 {
+		return false
+	}
+
+	p := point(xy)
+	r, gr, b := point(g.Red[:]), point(g.Green[:]), point(g.Blue[:])
+
+	d1 := cross(p, r, gr)
+	d2 := cross(p, gr, b)
+	d3 := cross(p, b, r)
+
+	neg := d1 < 0 || d2 < 0 || d3 < 0
+	pos := d1 > 0 || d2 > 0 || d3 > 0
+
+	return !(neg && pos)
+}
+
+// Clamp returns xy if it is inside the gamut, otherwise the closest point on the edge of the gamut
+func (g *Gamut) Clamp(xy []float32) []float32 {
+
+	if len(xy) != 2 || g.Contains(xy) {
+		return xy
+	}
+
+	p := point(xy)
+	r, gr, b := point(g.Red[:]), point(g.Green[:]), point(g.Blue[:])
+
+	best := closest(p, r, gr)
+	for _, c := range [][2]float64{closest(p, gr, b), closest(p, b, r)} {
+		if distance(p, c) < distance(p, best) {
+			best = c
+		}
+	}
+
+	return []float32{float32(best[0]), float32(best[1])}
+}
+
+// ConvertRGBToXyGamut converts c to xy and brightness like ConvertRGBToXy. The xy color is clamped to g
+// so that the light shows the closest color it is able to reproduce. g may be nil, in which case no clamping is done.
+func ConvertRGBToXyGamut(c color.Color, g *Gamut) ([]float32, uint8) {
+
+	r, gr, b, _ := c.RGBA()
+	if r == 0 && gr == 0 && b == 0 {
+		return []float32{whitePoint[0], whitePoint[1]}, 0
+	}
+
+	xy, bri := ConvertRGBToXy(c)
+	if g != nil {
+		xy = g.Clamp(xy)
+	}
+
+	return xy, bri
+}
+
+// ConvertXyToRGB converts the xy color and brightness of a light to RGB. xy is clamped to g first, g may be nil.
+// implemented as in https://developers.meethue.com/develop/application-design-guidance/color-conversion-formulas-rgb-to-xy-and-back/
+func ConvertXyToRGB(xy []float32, bri uint8, g *Gamut) color.RGBA {
+
+	if len(xy) != 2 || xy[1] == 0 {
+		return color.RGBA{A: 0xff}
+	}
+
+	if g != nil {
+		xy = g.Clamp(xy)
+	}
+
+	x, y := float64(xy[0]), float64(xy[1])
+	z := 1.0 - x - y
+
+	Y := float64(bri) / 254
+	X := (Y / y) * x
+	Z := (Y / y) * z
+
+	// Inverse of the wide gamut matrix used by ConvertRGBToXy
+	rgb := []float64{
+		X*1.611757 - Y*0.202805 - Z*0.302298,
+		-X*0.509057 + Y*1.411914 + Z*0.066070,
+		X*0.026086 - Y*0.072353 + Z*0.962086,
+	}
+
+	max := 0.0
+	for i, v := range rgb {
+		v = reverseGammaCorrect(math.Max(v, 0))
+		rgb[i] = v
+		max = math.Max(max, v)
+	}
+
+	if max > 1 {
+		for i := range rgb {
+			rgb[i] /= max
+		}
+	}
+
+	return color.RGBA{R: to8(rgb[0]), G: to8(rgb[1]), B: to8(rgb[2]), A: 0xff}
+}
+
+// ConvertHSVToRGB converts a color in HSV to RGB. h is in degrees, s and v are between 0 and 1.
+func ConvertHSVToRGB(h, s, v float64) color.RGBA {
+
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	s, v = clamp(s, 0, 1), clamp(v, 0, 1)
+
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.RGBA{R: to8(r + m), G: to8(g + m), B: to8(b + m), A: 0xff}
+}
+
+// ConvertRGBToHSV converts c to HSV. h is in degrees, s and v are between 0 and 1.
+func ConvertRGBToHSV(c color.Color) (float64, float64, float64) {
+
+	r16, g16, b16, _ := c.RGBA()
+	r, g, b := float64(r16)/0xffff, float64(g16)/0xffff, float64(b16)/0xffff
+
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	d := max - min
+
+	var h float64
+	switch {
+	case d == 0:
+		h = 0
+	case max == r:
+		h = 60 * math.Mod((g-b)/d, 6)
+	case max == g:
+		h = 60 * ((b-r)/d + 2)
+	default:
+		h = 60 * ((r-g)/d + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+
+	var s float64
+	if max > 0 {
+		s = d / max
+	}
+
+	return h, s, max
+}
+
+// ConvertHSVToHueSat converts a color in HSV to the hue, sat and bri parameters of a light.
+// h is in degrees, s and v are between 0 and 1.
+func ConvertHSVToHueSat(h, s, v float64) (uint16, uint8, uint8) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	return uint16(math.Round(h / 360 * 65535)), uint8(math.Round(clamp(s, 0, 1) * 254)), uint8(math.Round(clamp(v, 0, 1) * 254))
+}
+
+// ConvertHueSatToHSV converts the hue, sat and bri parameters of a light to HSV.
+// h is in degrees, s and v are between 0 and 1.
+func ConvertHueSatToHSV(hue uint16, sat, bri uint8) (float64, float64, float64) {
+	return float64(hue) / 65535 * 360, math.Min(float64(sat)/254, 1), math.Min(float64(bri)/254, 1)
+}
+
+// ConvertHexToRGB parses a hex color such as #ff8800, ff8800 or #f80
+func ConvertHexToRGB(hex string) (color.RGBA, error) {
+
+	s := strings.TrimPrefix(hex, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid hex color %q", hex)
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid hex color %q", hex)
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// ConvertRGBToHex formats c as a hex color such as #ff8800
+func ConvertRGBToHex(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// ConvertKelvinToMired converts a color temperature in Kelvin to mired, the unit used by the ct parameter of a light
+func ConvertKelvinToMired(k uint16) uint16 {
+	if k == 0 {
+		return 0
+	}
+	return uint16(math.Round(1000000 / float64(k)))
+}
+
+// ConvertMiredToKelvin converts a color temperature in mired to Kelvin
+func ConvertMiredToKelvin(m uint16) uint16 {
+	if m == 0 {
+		return 0
+	}
+	return uint16(math.Round(1000000 / float64(m)))
+}
+
+// ConvertKelvinToXy returns the xy coordinate of a color temperature in Kelvin on the Planckian locus.
+// k is limited to the range 1667-25000 in which the approximation is valid.
+func ConvertKelvinToXy(k uint16) []float32 {
+
+	t := clamp(float64(k), 1667, 25000)
+
+	var x float64
+	if t <= 4000 {
+		x = -0.2661239e9/(t*t*t) - 0.2343589e6/(t*t) + 0.8776956e3/t + 0.179910
+	} else {
+		x = -3.0258469e9/(t*t*t) + 2.1070379e6/(t*t) + 0.2226347e3/t + 0.240390
+	}
+
+	var y float64
+	switch {
+	case t <= 2222:
+		y = -1.1063814*x*x*x - 1.34811020*x*x + 2.18555832*x - 0.20219683
+	case t <= 4000:
+		y = -0.9549476*x*x*x - 1.37418593*x*x + 2.09137015*x - 0.16748867
+	default:
+		y = 3.0817580*x*x*x - 5.87338670*x*x + 3.75112997*x - 0.37001483
+	}
+
+	return []float32{float32(x), float32(y)}
+}
+
+func reverseGammaCorrect(value float64) float64 {
+	if value <= 0.0031308 {
+		return 12.92 * value
+	}
+	return (1.0+0.055)*math.Pow(value, 1.0/2.4) - 0.055
+}
+
+func point(xy []float32) [2]float64 {
+	return [2]float64{float64(xy[0]), float64(xy[1])}
+}
+
+// cross returns the z component of the cross product of (b-a) and (p-a)
+func cross(p, a, b [2]float64) float64 {
+	return (b[0]-a[0])*(p[1]-a[1]) - (b[1]-a[1])*(p[0]-a[0])
+}
+
+// closest returns the point on the line segment between a and b that is closest to p
+func closest(p, a, b [2]float64) [2]float64 {
+	ab := [2]float64{b[0] - a[0], b[1] - a[1]}
+	t := ((p[0]-a[0])*ab[0] + (p[1]-a[1])*ab[1]) / (ab[0]*ab[0] + ab[1]*ab[1])
+	t = clamp(t, 0, 1)
+	return [2]float64{a[0] + ab[0]*t, a[1] + ab[1]*t}
+}
+
+func distance(a, b [2]float64) float64 {
+	return math.Hypot(a[0]-b[0], a[1]-b[1])
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
+
+func to8(v float64) uint8 {
+	return uint8(math.Round(clamp(v, 0, 1) * 255))
+}
@@ -0,0 +1,188 @@
+package huego
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGamutContainsAndClamp(t *testing.T) {
+
+	assert.True(t, GamutC.Contains([]float32{0.3127, 0.329}))
+	assert.True(t, GamutC.Contains(GamutC.Red[:]))
+	assert.False(t, GamutB.Contains([]float32{0.17, 0.7}))
+	assert.False(t, GamutA.Contains([]float32{0.5}))
+
+	// Points inside are returned as is
+	assert.Equal(t, []float32{0.3, 0.3}, GamutA.Clamp([]float32{0.3, 0.3}))
+
+	// Points outside are moved to the closest point on the edge
+	assert.InDeltaSlice(t, []float32{0.675, 0.322}, GamutB.Clamp([]float32{0.8, 0.3}), 0.0001)
+	assert.InDeltaSlice(t, []float32{0.409, 0.518}, GamutB.Clamp([]float32{0.409, 0.7}), 0.0001)
+
+	assert.InDelta(t, 0, distanceToEdge(GamutB, GamutB.Clamp([]float32{0.17, 0.7})), 0.0001)
+}
+
+// distanceToEdge returns the distance from xy to the closest edge of g
+func distanceToEdge(g Gamut, xy []float32) float64 {
+	p := point(xy)
+	r, gr, b := point(g.Red[:]), point(g.Green[:]), point(g.Blue[:])
+	d := distance(p, closest(p, r, gr))
+	for _, c := range [][2]float64{closest(p, gr, b), closest(p, b, r)} {
+		if dc := distance(p, c); dc < d {
+			d = dc
+		}
+	}
+	return d
+}
+
+func TestGamutByType(t *testing.T) {
+	assert.Equal(t, "A", GamutByType("a").Type)
+	assert.Equal(t, GamutB, *GamutByType("B"))
+	assert.Equal(t, GamutC, *GamutByType("C"))
+	assert.Nil(t, GamutByType("other"))
+
+	// Returned gamuts are copies
+	g := GamutByType("C")
+	g.Red[0] = 1
+	assert.Equal(t, float32(0.6915), GamutC.Red[0])
+}
+
+func TestLightGamutFallback(t *testing.T) {
+	l := &Light{ModelID: "LCT001"}
+	assert.Equal(t, "B", l.Gamut().Type)
+
+	l = &Light{ModelID: "unknown", Capabilities: &LightCapabilities{Control: LightControl{ColorGamutType: "A"}}}
+	assert.Equal(t, "A", l.Gamut().Type)
+}
+
+func TestConvertRGBToXyGamut(t *testing.T) {
+
+	red := color.RGBA{R: 0xff, A: 0xff}
+
+	xy, bri := ConvertRGBToXyGamut(red, nil)
+	wide, wideBri := ConvertRGBToXy(red)
+	assert.Equal(t, wide, xy)
+	assert.Equal(t, wideBri, bri)
+
+	xy, _ = ConvertRGBToXyGamut(red, &GamutB)
+	assert.InDelta(t, 0, distanceToEdge(GamutB, xy), 0.0001)
+
+	xy, bri = ConvertRGBToXyGamut(color.Black, &GamutC)
+	assert.Equal(t, []float32{0.3127, 0.329}, xy)
+	assert.Equal(t, uint8(0), bri)
+}
+
+func TestConvertXyToRGB(t *testing.T) {
+
+	tests := []color.RGBA{
+		{R: 0xff, A: 0xff},
+		{G: 0xff, A: 0xff},
+		{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		{R: 0xff, G: 0x88, A: 0xff},
+	}
+
+	for _, c := range tests {
+		xy, bri := ConvertRGBToXy(c)
+		rgb := ConvertXyToRGB(xy, bri, nil)
+		assert.InDelta(t, c.R, rgb.R, 3, "%v", c)
+		assert.InDelta(t, c.G, rgb.G, 3, "%v", c)
+		assert.InDelta(t, c.B, rgb.B, 3, "%v", c)
+	}
+
+	// Blue has a very low brightness so the round trip is less precise
+	xy, bri := ConvertRGBToXy(color.RGBA{B: 0xff, A: 0xff})
+	rgb := ConvertXyToRGB(xy, bri, nil)
+	assert.Greater(t, rgb.B, uint8(0xe0))
+	assert.Less(t, rgb.R, uint8(0x10))
+	assert.Less(t, rgb.G, uint8(0x10))
+
+	assert.Equal(t, color.RGBA{A: 0xff}, ConvertXyToRGB([]float32{0.3, 0}, 254, nil))
+	assert.Equal(t, color.RGBA{A: 0xff}, ConvertXyToRGB(nil, 254, nil))
+
+	// Colors outside of the gamut are clamped first, so pure green is never returned for gamut B
+	rgb = ConvertXyToRGB([]float32{0.17, 0.7}, 254, &GamutB)
+	assert.Greater(t, rgb.R, uint8(0x80))
+}
+
+func TestConvertHSV(t *testing.T) {
+
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, ConvertHSVToRGB(0, 1, 1))
+	assert.Equal(t, color.RGBA{G: 0xff, A: 0xff}, ConvertHSVToRGB(120, 1, 1))
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, ConvertHSVToRGB(-120, 1, 1))
+	assert.Equal(t, color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}, ConvertHSVToRGB(42, 0, 0.5))
+
+	h, s, v := ConvertRGBToHSV(color.RGBA{R: 0xff, G: 0x80, A: 0xff})
+	assert.InDelta(t, 30, h, 0.5)
+	assert.InDelta(t, 1, s, 0.001)
+	assert.InDelta(t, 1, v, 0.001)
+
+	h, s, v = ConvertRGBToHSV(color.RGBA{B: 0xff, R: 0xff, A: 0xff})
+	assert.InDelta(t, 300, h, 0.001)
+
+	hue, sat, bri := ConvertHSVToHueSat(180, 0.5, 1)
+	assert.Equal(t, uint16(32768), hue)
+	assert.Equal(t, uint8(127), sat)
+	assert.Equal(t, uint8(254), bri)
+
+	h, s, v = ConvertHueSatToHSV(65535, 254, 127)
+	assert.InDelta(t, 360, h, 0.001)
+	assert.InDelta(t, 1, s, 0.001)
+	assert.InDelta(t, 0.5, v, 0.001)
+}
+
+func TestConvertHex(t *testing.T) {
+
+	c, err := ConvertHexToRGB("#ff8800")
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0x88, A: 0xff}, c)
+
+	c, err = ConvertHexToRGB("f80")
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0x88, A: 0xff}, c)
+
+	_, err = ConvertHexToRGB("#ff88")
+	assert.NotNil(t, err)
+
+	_, err = ConvertHexToRGB("#gg8800")
+	assert.NotNil(t, err)
+
+	assert.Equal(t, "#ff8800", ConvertRGBToHex(color.RGBA{R: 0xff, G: 0x88, A: 0xff}))
+}
+
+func TestConvertColorTemperature(t *testing.T) {
+
+	assert.Equal(t, uint16(370), ConvertKelvinToMired(2700))
+	assert.Equal(t, uint16(154), ConvertKelvinToMired(6500))
+	assert.Equal(t, uint16(2000), ConvertMiredToKelvin(500))
+	assert.Equal(t, uint16(0), ConvertKelvinToMired(0))
+	assert.Equal(t, uint16(0), ConvertMiredToKelvin(0))
+
+	// Well known points on the Planckian locus
+	assert.InDeltaSlice(t, []float32{0.4599, 0.4106}, ConvertKelvinToXy(2700), 0.002)
+	assert.InDeltaSlice(t, []float32{0.3135, 0.3236}, ConvertKelvinToXy(6500), 0.002)
+	assert.Equal(t, ConvertKelvinToXy(1667), ConvertKelvinToXy(1000))
+}
+
+func TestLightColor(t *testing.T) {
+
+	l := &Light{State: &State{On: true, Bri: 254, Xy: []float32{0.6915, 0.3083}, ColorMode: "xy"}}
+	c := l.Color()
+	assert.Greater(t, c.R, c.G)
+	assert.Greater(t, c.R, c.B)
+
+	l.State = &State{On: true, Bri: 254, Hue: 21845, Sat: 254, ColorMode: "hs"}
+	assert.Equal(t, color.RGBA{G: 0xff, A: 0xff}, l.Color())
+
+	l.State = &State{On: true, Bri: 254, Ct: 500, ColorMode: "ct"}
+	c = l.Color()
+	assert.Greater(t, c.R, c.B)
+
+	l.State = &State{On: true, Bri: 254}
+	c = l.Color()
+	assert.InDelta(t, c.R, c.B, 8)
+
+	l.State = &State{On: false, Bri: 254}
+	assert.Equal(t, color.RGBA{A: 0xff}, l.Color())
+}
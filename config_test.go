@@ -1,7 +1,12 @@
 package huego
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -108,6 +113,57 @@ func TestCreateUserWithClientKeyError(t *testing.T) {
 	}
 }
 
+func TestPair(t *testing.T) {
+
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.Write([]byte(`[{"error":{"type":101,"address":"","description":"link button not pressed"}}]`))
+			return
+		}
+		w.Write([]byte(`[{"success":{"username":"83b7780291a6ceffbe0bd049104df","clientkey":"33DDAEFCF5E18CFB2F2A8D1C2D0D3DB3"}}]`))
+	}))
+	defer srv.Close()
+
+	b := NewWithClient(srv.URL, "", srv.Client())
+
+	var progress []int
+	paired, wl, err := b.Pair(context.Background(), "huego#test", &PairOptions{
+		Interval:          time.Millisecond,
+		GenerateClientKey: true,
+		Progress:          func(attempt int) { progress = append(progress, attempt) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int{1, 2}, progress)
+	assert.Equal(t, "83b7780291a6ceffbe0bd049104df", paired.User)
+	assert.Equal(t, "33DDAEFCF5E18CFB2F2A8D1C2D0D3DB3", wl.ClientKey)
+	assert.Equal(t, "", b.User)
+	assert.Equal(t, b.client, paired.client)
+}
+
+func TestPairTimeout(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"error":{"type":101,"address":"","description":"link button not pressed"}}]`))
+	}))
+	defer srv.Close()
+
+	b := NewWithClient(srv.URL, "", srv.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, _, err := b.Pair(ctx, "huego#test", &PairOptions{Interval: 10 * time.Millisecond})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// Errors other than the link button not being pressed are returned at once
+	b = New(badHostname, "")
+	_, _, err = b.Pair(context.Background(), "huego#test", nil)
+	assert.NotNil(t, err)
+}
+
 func TestGetUsers(t *testing.T) {
 	b := New(hostname, username)
 	users, err := b.GetUsers()
@@ -153,10 +209,19 @@ func TestDeleteUser(t *testing.T) {
 
 func TestGetFullState(t *testing.T) {
 	b := New(hostname, username)
-	_, err := b.GetFullState()
+	s, err := b.GetFullState()
 	if err != nil {
 		t.Fatal(err)
 	}
+	assert.Len(t, s.Lights, 2)
+	assert.Equal(t, 2, s.Lights[2].ID)
+	assert.Equal(t, "HueLamp2", s.Lights[2].Name)
+	assert.Equal(t, b, s.Lights[2].bridge)
+	assert.Equal(t, 1, s.Groups[1].ID)
+	assert.Equal(t, []string{"1", "2"}, s.Groups[1].Lights)
+	assert.Equal(t, "Philipshue", s.Config.Name)
+	assert.Len(t, s.Config.Whitelist, 1)
+	assert.Equal(t, "1028d66426293e821ecfd9ef1a0731df", s.Config.Whitelist[0].Username)
 }
 
 func TestGetFullStateError(t *testing.T) {
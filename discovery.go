@@ -0,0 +1,504 @@
+package huego
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	cloudDiscoveryURL    = "https://discovery.meethue.com"
+	mdnsAddr             = "224.0.0.251:5353"
+	mdnsService          = "_hue._tcp.local."
+	ssdpAddr             = "239.255.255.250:1900"
+	defaultLocalTimeout  = 3 * time.Second
+	bridgeIDHeader       = "hue-bridgeid"
+	bridgeModelName      = "Philips hue bridge"
+	mdnsUnicastResponse  = 1 << 15
+	maxDiscoveryDatagram = 9000
+)
+
+// DiscoveryStrategy is a method of finding bridges on the network
+type DiscoveryStrategy interface {
+	Discover(ctx context.Context) ([]Bridge, error)
+}
+
+// DiscoveryStrategyFunc is an adapter allowing an ordinary function to be used as a DiscoveryStrategy
+type DiscoveryStrategyFunc func(ctx context.Context) ([]Bridge, error)
+
+// Discover calls f(ctx)
+func (f DiscoveryStrategyFunc) Discover(ctx context.Context) ([]Bridge, error) {
+	return f(ctx)
+}
+
+// CloudDiscovery finds bridges using the https://discovery.meethue.com service. Requires internet access.
+type CloudDiscovery struct {
+	// URL of the discovery service. Defaults to https://discovery.meethue.com
+	URL string
+	// Client is the http client used for the request. Defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// MDNSDiscovery finds bridges on the local network by browsing for the _hue._tcp service using multicast DNS
+type MDNSDiscovery struct {
+	// Timeout is how long to wait for responses. Defaults to 3 seconds
+	Timeout time.Duration
+	// Addr is the address queries are sent to. Defaults to the mDNS multicast group 224.0.0.251:5353
+	Addr string
+}
+
+// SSDPDiscovery finds bridges on the local network using the UPnP SSDP protocol.
+// Responses without a bridge id header are verified by reading description.xml from the responding host.
+type SSDPDiscovery struct {
+	// Timeout is how long to wait for responses. Defaults to 3 seconds
+	Timeout time.Duration
+	// Addr is the address the search is sent to. Defaults to the SSDP multicast group 239.255.255.250:1900
+	Addr string
+	// Client is the http client used to read description.xml. Defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// LocalDiscovery returns the strategies that work without internet access, mDNS and SSDP
+func LocalDiscovery() []DiscoveryStrategy {
+	return []DiscoveryStrategy{&MDNSDiscovery{}, &SSDPDiscovery{}}
+}
+
+// AllDiscovery returns all available strategies, cloud, mDNS and SSDP
+func AllDiscovery() []DiscoveryStrategy {
+	return append([]DiscoveryStrategy{&CloudDiscovery{}}, LocalDiscovery()...)
+}
+
+// DiscoverAllUsing performs a discovery using all of the given strategies concurrently.
+// The results are merged and de-duplicated by bridge ID. An error is only returned if all strategies fail.
+func DiscoverAllUsing(ctx context.Context, strategies ...DiscoveryStrategy) ([]Bridge, error) {
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
+	found := map[string]Bridge{}
+
+	for _, s := range strategies {
+		wg.Add(1)
+		go func(s DiscoveryStrategy) {
+			defer wg.Done()
+			bridges, err := s.Discover(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for _, b := range bridges {
+				key := strings.ToLower(b.ID)
+				if key == "" {
+					key = b.Host
+				}
+				if _, ok := found[key]; !ok {
+					found[key] = b
+				}
+			}
+		}(s)
+	}
+
+	wg.Wait()
+
+	if len(strategies) > 0 && len(errs) == len(strategies) {
+		return nil, errs[0]
+	}
+
+	bridges := make([]Bridge, 0, len(found))
+	for _, b := range found {
+		bridges = append(bridges, b)
+	}
+
+	sort.Slice(bridges, func(i, j int) bool {
+		return bridges[i].ID < bridges[j].ID
+	})
+
+	return bridges, nil
+}
+
+// DiscoverUsing performs a discovery using all of the given strategies concurrently
+// and returns the first bridge found by whichever strategy responds first.
+// An error is only returned if all strategies fail.
+func DiscoverUsing(ctx context.Context, strategies ...DiscoveryStrategy) (*Bridge, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		bridges []Bridge
+		err     error
+	}
+
+	results := make(chan result, len(strategies))
+
+	for _, s := range strategies {
+		go func(s DiscoveryStrategy) {
+			bridges, err := s.Discover(ctx)
+			results <- result{bridges, err}
+		}(s)
+	}
+
+	var err error
+	failed := 0
+
+	for range strategies {
+		r := <-results
+		if r.err != nil {
+			err = r.err
+			failed++
+			continue
+		}
+		if len(r.bridges) > 0 {
+			return &r.bridges[0], nil
+		}
+	}
+
+	if failed > 0 && failed == len(strategies) {
+		return nil, err
+	}
+
+	return &Bridge{}, nil
+}
+
+// Discover queries the cloud discovery service
+func (c *CloudDiscovery) Discover(ctx context.Context) ([]Bridge, error) {
+
+	target := c.URL
+	if target == "" {
+		target = cloudDiscoveryURL
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	d, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var bridges []Bridge
+
+	err = json.Unmarshal(d, &bridges)
+	if err != nil {
+		return nil, err
+	}
+
+	return bridges, nil
+}
+
+// Discover sends an mDNS query for the hue service and collects answers until the timeout expires
+func (m *MDNSDiscovery) Discover(ctx context.Context) ([]Bridge, error) {
+
+	addr := m.Addr
+	if addr == "" {
+		addr = mdnsAddr
+	}
+
+	query, err := mdnsQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dnsmessage.Resource
+
+	err = exchangeUDP(ctx, addr, timeoutOrDefault(m.Timeout), query, func(data []byte) {
+		var msg dnsmessage.Message
+		if msg.Unpack(data) != nil || !msg.Header.Response {
+			return
+		}
+		records = append(records, msg.Answers...)
+		records = append(records, msg.Additionals...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bridgesFromMDNS(records), nil
+}
+
+func mdnsQuery() ([]byte, error) {
+
+	name, err := dnsmessage.NewName(mdnsService)
+	if err != nil {
+		return nil, err
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	err = b.StartQuestions()
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.Question(dnsmessage.Question{
+		Name:  name,
+		Type:  dnsmessage.TypePTR,
+		Class: dnsmessage.ClassINET | mdnsUnicastResponse,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Finish()
+}
+
+// bridgesFromMDNS resolves hue service instances to bridges by following PTR, SRV, TXT and A records
+func bridgesFromMDNS(records []dnsmessage.Resource) []Bridge {
+
+	var instances []string
+	targets := map[string]string{}
+	ids := map[string]string{}
+	addrs := map[string]string{}
+
+	for _, r := range records {
+		name := strings.ToLower(r.Header.Name.String())
+		switch body := r.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if name == mdnsService {
+				instances = append(instances, strings.ToLower(body.PTR.String()))
+			}
+		case *dnsmessage.SRVResource:
+			targets[name] = strings.ToLower(body.Target.String())
+		case *dnsmessage.TXTResource:
+			for _, txt := range body.TXT {
+				if strings.HasPrefix(strings.ToLower(txt), "bridgeid=") {
+					ids[name] = strings.ToLower(strings.TrimPrefix(strings.ToLower(txt), "bridgeid="))
+				}
+			}
+		case *dnsmessage.AResource:
+			addrs[name] = net.IP(body.A[:]).String()
+		}
+	}
+
+	bridges := []Bridge{}
+	for _, instance := range instances {
+		addr, ok := addrs[targets[instance]]
+		if !ok {
+			continue
+		}
+		bridges = append(bridges, Bridge{ID: ids[instance], Host: addr})
+	}
+
+	return bridges
+}
+
+// ssdpDescription is the subset of description.xml needed to identify a bridge
+type ssdpDescription struct {
+	URLBase string `xml:"URLBase"`
+	Device  struct {
+		ModelName    string `xml:"modelName"`
+		SerialNumber string `xml:"serialNumber"`
+	} `xml:"device"`
+}
+
+// Discover sends an SSDP search and collects bridges from the responses until the timeout expires
+func (s *SSDPDiscovery) Discover(ctx context.Context) ([]Bridge, error) {
+
+	addr := s.Addr
+	if addr == "" {
+		addr = ssdpAddr
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: urn:schemas-upnp-org:device:basic:1\r\n\r\n"
+
+	var responses []*http.Response
+
+	err := exchangeUDP(ctx, addr, timeoutOrDefault(s.Timeout), []byte(search), func(data []byte) {
+		res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+		if err != nil {
+			return
+		}
+		res.Body.Close()
+		responses = append(responses, res)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bridges := []Bridge{}
+	seen := map[string]bool{}
+
+	for _, res := range responses {
+		location := res.Header.Get("Location")
+		if location == "" || seen[location] {
+			continue
+		}
+		seen[location] = true
+
+		u, err := url.Parse(location)
+		if err != nil {
+			continue
+		}
+
+		if id := res.Header.Get(bridgeIDHeader); id != "" {
+			bridges = append(bridges, Bridge{ID: strings.ToLower(id), Host: u.Hostname()})
+			continue
+		}
+
+		b, err := readSSDPDescription(ctx, client, location)
+		if err != nil {
+			continue
+		}
+		bridges = append(bridges, *b)
+	}
+
+	return bridges, nil
+}
+
+// readSSDPDescription reads description.xml at location and returns the bridge it describes.
+// An error is returned if the device is not a hue bridge.
+func readSSDPDescription(ctx context.Context, client *http.Client, location string) (*Bridge, error) {
+
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.WithContext(ctx)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	var d ssdpDescription
+
+	err = xml.NewDecoder(res.Body).Decode(&d)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(strings.ToLower(d.Device.ModelName), strings.ToLower(bridgeModelName)) {
+		return nil, errors.New("device is not a hue bridge")
+	}
+
+	base := location
+	if d.URLBase != "" {
+		base = d.URLBase
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bridge{ID: bridgeIDFromMAC(d.Device.SerialNumber), Host: u.Hostname()}, nil
+}
+
+// bridgeIDFromMAC converts the mac address of a bridge, as used in its serial number, to its bridge ID
+func bridgeIDFromMAC(mac string) string {
+	mac = strings.ToLower(strings.Replace(mac, ":", "", -1))
+	if len(mac) != 12 {
+		return mac
+	}
+	return mac[:6] + "fffe" + mac[6:]
+}
+
+// exchangeUDP sends msg to addr from an ephemeral port and calls handle for each datagram received until timeout expires or ctx is done.
+// An error is returned if ctx is cancelled before anything was received.
+func exchangeUDP(ctx context.Context, addr string, timeout time.Duration, msg []byte, handle func([]byte)) error {
+
+	raddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+
+	_, err = conn.WriteToUDP(msg, raddr)
+	if err != nil {
+		return err
+	}
+
+	// The deadline ends the collection window, whether it is the timeout or the deadline of ctx.
+	// Only a cancellation before any answer arrived is an error.
+	received := false
+	buf := make([]byte, maxDiscoveryDatagram)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				if ctx.Err() == context.Canceled && !received {
+					return ctx.Err()
+				}
+				return nil
+			}
+			return err
+		}
+		received = true
+		handle(buf[:n])
+	}
+}
+
+func timeoutOrDefault(t time.Duration) time.Duration {
+	if t == 0 {
+		return defaultLocalTimeout
+	}
+	return t
+}
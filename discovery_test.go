@@ -0,0 +1,200 @@
+package huego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveUDP starts a UDP stand-in on localhost that answers every datagram using respond
+func serveUDP(t *testing.T, respond func(req []byte) [][]byte) (string, func()) {
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		buf := make([]byte, maxDiscoveryDatagram)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			for _, res := range respond(buf[:n]) {
+				_, _ = conn.WriteToUDP(res, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func mdnsAnswer(t *testing.T) []byte {
+
+	name := func(s string) dnsmessage.Name {
+		n, err := dnsmessage.NewName(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, Authoritative: true})
+	_ = b.StartAnswers()
+	_ = b.PTRResource(dnsmessage.ResourceHeader{Name: name(mdnsService), Class: dnsmessage.ClassINET}, dnsmessage.PTRResource{PTR: name("Philips Hue - 73FF19._hue._tcp.local.")})
+	_ = b.StartAdditionals()
+	_ = b.SRVResource(dnsmessage.ResourceHeader{Name: name("Philips Hue - 73FF19._hue._tcp.local."), Class: dnsmessage.ClassINET}, dnsmessage.SRVResource{Port: 443, Target: name("001788fffe73ff19.local.")})
+	_ = b.TXTResource(dnsmessage.ResourceHeader{Name: name("Philips Hue - 73FF19._hue._tcp.local."), Class: dnsmessage.ClassINET}, dnsmessage.TXTResource{TXT: []string{"bridgeid=001788FFFE73FF19", "modelid=BSB002"}})
+	_ = b.AResource(dnsmessage.ResourceHeader{Name: name("001788fffe73ff19.local."), Class: dnsmessage.ClassINET}, dnsmessage.AResource{A: [4]byte{192, 168, 13, 112}})
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestMDNSDiscovery(t *testing.T) {
+
+	answer := mdnsAnswer(t)
+	addr, stop := serveUDP(t, func(req []byte) [][]byte {
+		var q dnsmessage.Message
+		if err := q.Unpack(req); err != nil || len(q.Questions) != 1 {
+			return nil
+		}
+		assert.Equal(t, mdnsService, q.Questions[0].Name.String())
+		assert.Equal(t, dnsmessage.TypePTR, q.Questions[0].Type)
+		return [][]byte{[]byte("garbage"), answer}
+	})
+	defer stop()
+
+	d := &MDNSDiscovery{Addr: addr, Timeout: 200 * time.Millisecond}
+	bridges, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Bridge{{ID: "001788fffe73ff19", Host: "192.168.13.112"}}, bridges)
+}
+
+func TestMDNSDiscoveryContext(t *testing.T) {
+
+	answer := mdnsAnswer(t)
+	addr, stop := serveUDP(t, func(req []byte) [][]byte {
+		return [][]byte{answer}
+	})
+	defer stop()
+
+	// A deadline before the timeout ends the collection window and keeps the answers
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	d := &MDNSDiscovery{Addr: addr, Timeout: time.Minute}
+	bridges, err := d.Discover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Bridge{{ID: "001788fffe73ff19", Host: "192.168.13.112"}}, bridges)
+
+	// Cancelling before any answer arrived is an error
+	silent, stopSilent := serveUDP(t, func(req []byte) [][]byte { return nil })
+	defer stopSilent()
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, err = (&MDNSDiscovery{Addr: silent, Timeout: time.Minute}).Discover(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestSSDPDiscovery(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/description.xml", r.URL.Path)
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8" ?><root xmlns="urn:schemas-upnp-org:device-1-0"><URLBase>http://%s:80/</URLBase><device><modelName>Philips hue bridge 2015</modelName><serialNumber>001788a1b2c3</serialNumber></device></root>`, "192.168.13.113")
+	}))
+	defer srv.Close()
+
+	addr, stop := serveUDP(t, func(req []byte) [][]byte {
+		assert.True(t, strings.HasPrefix(string(req), "M-SEARCH * HTTP/1.1\r\n"))
+		return [][]byte{
+			[]byte("HTTP/1.1 200 OK\r\nLOCATION: http://192.168.13.112:80/description.xml\r\nhue-bridgeid: 001788FFFE73FF19\r\n\r\n"),
+			[]byte("HTTP/1.1 200 OK\r\nLOCATION: " + srv.URL + "/description.xml\r\n\r\n"),
+			[]byte("not http"),
+		}
+	})
+	defer stop()
+
+	d := &SSDPDiscovery{Addr: addr, Timeout: 200 * time.Millisecond, Client: srv.Client()}
+	bridges, err := d.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Bridge{
+		{ID: "001788fffe73ff19", Host: "192.168.13.112"},
+		{ID: "001788fffea1b2c3", Host: "192.168.13.113"},
+	}, bridges)
+}
+
+func TestDiscoverAllUsing(t *testing.T) {
+
+	first := DiscoveryStrategyFunc(func(ctx context.Context) ([]Bridge, error) {
+		return []Bridge{{ID: "001788FFFE73FF19", Host: "192.168.13.112"}}, nil
+	})
+	second := DiscoveryStrategyFunc(func(ctx context.Context) ([]Bridge, error) {
+		return []Bridge{{ID: "001788fffe73ff19", Host: "192.168.13.112"}, {ID: "001788fffea1b2c3", Host: "192.168.13.113"}}, nil
+	})
+	failing := DiscoveryStrategyFunc(func(ctx context.Context) ([]Bridge, error) {
+		return nil, errors.New("network unreachable")
+	})
+
+	bridges, err := DiscoverAllUsing(context.Background(), first, second, failing, &CloudDiscovery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, bridges, 2)
+
+	_, err = DiscoverAllUsing(context.Background(), failing, failing)
+	assert.NotNil(t, err)
+}
+
+func TestDiscoverUsing(t *testing.T) {
+
+	slow := DiscoveryStrategyFunc(func(ctx context.Context) ([]Bridge, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	empty := DiscoveryStrategyFunc(func(ctx context.Context) ([]Bridge, error) {
+		return []Bridge{}, nil
+	})
+	fast := DiscoveryStrategyFunc(func(ctx context.Context) ([]Bridge, error) {
+		return []Bridge{{ID: "001788fffea1b2c3", Host: "192.168.13.113"}}, nil
+	})
+	failing := DiscoveryStrategyFunc(func(ctx context.Context) ([]Bridge, error) {
+		return nil, errors.New("network unreachable")
+	})
+
+	b, err := DiscoverUsing(context.Background(), slow, empty, fast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "192.168.13.113", b.Host)
+
+	b, err = DiscoverUsing(context.Background(), empty, failing)
+	assert.Nil(t, err)
+	assert.Equal(t, "", b.Host)
+
+	_, err = DiscoverUsing(context.Background(), failing)
+	assert.NotNil(t, err)
+}
+
+func Test_bridgeIDFromMAC(t *testing.T) {
+	assert.Equal(t, "001788fffe73ff19", bridgeIDFromMAC("00:17:88:73:FF:19"))
+	assert.Equal(t, "001788fffe73ff19", bridgeIDFromMAC("00178873ff19"))
+	assert.Equal(t, "invalid", bridgeIDFromMAC("invalid"))
+}
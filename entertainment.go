@@ -0,0 +1,257 @@
+package huego
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/dtls/v2"
+)
+
+const (
+	streamHeader      = "HueStream"
+	defaultStreamPort = 2100
+	defaultStreamRate = 20 * time.Millisecond
+)
+
+// Versions of the HueStream protocol
+const (
+	// StreamVersion1 addresses lights by their id and is used with entertainment groups of the v1 API
+	StreamVersion1 = 1
+	// StreamVersion2 addresses channels of an entertainment configuration of the CLIP v2 API
+	StreamVersion2 = 2
+)
+
+// ColorSpace defines how the three values of a channel are interpreted
+type ColorSpace byte
+
+const (
+	// ColorSpaceRGB sends red, green and blue per channel
+	ColorSpaceRGB ColorSpace = 0x00
+	// ColorSpaceXY sends x, y and brightness per channel
+	ColorSpaceXY ColorSpace = 0x01
+)
+
+// StreamOptions configures an entertainment stream
+type StreamOptions struct {
+	// ClientKey is the client key returned by CreateUserWithClientKey, hex encoded
+	ClientKey string
+	// Version is the HueStream protocol version, StreamVersion1 if not set
+	Version int
+	// ConfigurationID is the id of the entertainment configuration to stream to. Required for StreamVersion2
+	ConfigurationID string
+	// ColorSpace is the color space of the channel values, ColorSpaceRGB if not set
+	ColorSpace ColorSpace
+	// Rate is the interval between frames, 20ms (50 frames per second) if not set
+	Rate time.Duration
+	// Port is the UDP port of the bridge, 2100 if not set
+	Port int
+}
+
+// Streamer sends light states to an entertainment area of the bridge over DTLS.
+// Channel values are buffered with SetChannel and sent on every tick of the configured rate or when Flush is called.
+type Streamer struct {
+	conn     net.Conn
+	opts     StreamOptions
+	mu       sync.Mutex
+	channels map[uint16][3]uint16
+	order    []uint16
+	seq      uint8
+	done     chan struct{}
+	wg       sync.WaitGroup
+	err      error
+	close    sync.Once
+	closeErr error
+}
+
+// StartStream opens a DTLS session to the bridge and starts sending frames at the configured rate.
+// Streaming must have been enabled on the entertainment group with Group.EnableStreaming before calling StartStream.
+func (b *Bridge) StartStream(opts StreamOptions) (*Streamer, error) {
+	return b.StartStreamContext(context.Background(), opts)
+}
+
+// StartStreamContext opens a DTLS session to the bridge and starts sending frames at the configured rate.
+// Streaming must have been enabled on the entertainment group with Group.EnableStreamingContext before calling StartStreamContext.
+func (b *Bridge) StartStreamContext(ctx context.Context, opts StreamOptions) (*Streamer, error) {
+
+	if opts.Version == 0 {
+		opts.Version = StreamVersion1
+	}
+	if opts.Rate == 0 {
+		opts.Rate = defaultStreamRate
+	}
+	if opts.Port == 0 {
+		opts.Port = defaultStreamPort
+	}
+
+	if opts.Version != StreamVersion1 && opts.Version != StreamVersion2 {
+		return nil, fmt.Errorf("unsupported stream version %d", opts.Version)
+	}
+	if opts.Version == StreamVersion2 && len(opts.ConfigurationID) != 36 {
+		return nil, errors.New("a configuration id is required for stream version 2")
+	}
+
+	psk, err := hex.DecodeString(opts.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client key: %v", err)
+	}
+
+	addr, err := b.streamAddr(opts.Port)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &dtls.Config{
+		PSK: func(hint []byte) ([]byte, error) {
+			return psk, nil
+		},
+		PSKIdentityHint: []byte(b.User),
+		CipherSuites:    []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	}
+
+	conn, err := dtls.DialWithContext(ctx, "udp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Streamer{
+		conn:     conn,
+		opts:     opts,
+		channels: map[uint16][3]uint16{},
+		done:     make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+// streamAddr resolves the UDP address of the bridge from Host
+func (b *Bridge) streamAddr(port int) (*net.UDPAddr, error) {
+
+	host := b.Host
+	if strings.Index(strings.ToLower(host), "http://") <= -1 && strings.Index(strings.ToLower(host), "https://") <= -1 {
+		host = fmt.Sprintf("%s%s", "http://", host)
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.ResolveUDPAddr("udp", net.JoinHostPort(u.Hostname(), fmt.Sprintf("%d", port)))
+}
+
+func (s *Streamer) run() {
+
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.opts.Rate)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			err := s.Flush()
+			if err != nil {
+				s.mu.Lock()
+				s.err = err
+				s.mu.Unlock()
+				return
+			}
+		}
+	}
+}
+
+// SetChannel sets the values of one channel. For StreamVersion1 the channel is the id of a light.
+// The values are r, g and b when using ColorSpaceRGB, or x, y and brightness when using ColorSpaceXY, each in the range 0-1.
+func (s *Streamer) SetChannel(channel uint16, v1, v2, v3 float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.channels[channel]; !ok {
+		s.order = append(s.order, channel)
+	}
+	s.channels[channel] = [3]uint16{scaleStreamValue(v1), scaleStreamValue(v2), scaleStreamValue(v3)}
+}
+
+// SetChannelColor sets one channel to c. c is converted to xy when the stream uses ColorSpaceXY.
+func (s *Streamer) SetChannelColor(channel uint16, c color.Color) {
+	if s.opts.ColorSpace == ColorSpaceXY {
+		xy, bri := ConvertRGBToXy(c)
+		s.SetChannel(channel, float64(xy[0]), float64(xy[1]), float64(bri)/254)
+		return
+	}
+	r, g, b, _ := c.RGBA()
+	s.SetChannel(channel, float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff)
+}
+
+// Flush sends the current channel values to the bridge immediately
+func (s *Streamer) Flush() error {
+	s.mu.Lock()
+	frame := s.encode()
+	s.seq++
+	s.mu.Unlock()
+
+	_, err := s.conn.Write(frame)
+	return err
+}
+
+// Err returns the error that stopped the stream, if any
+func (s *Streamer) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops sending frames and closes the DTLS session. Streaming should be disabled on the group afterwards.
+// Calling Close more than once returns the result of the first call.
+func (s *Streamer) Close() error {
+	s.close.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		s.closeErr = s.conn.Close()
+	})
+	return s.closeErr
+}
+
+// encode returns a HueStream message containing all channels. The caller must hold s.mu.
+func (s *Streamer) encode() []byte {
+
+	var buf bytes.Buffer
+
+	buf.WriteString(streamHeader)
+	buf.Write([]byte{byte(s.opts.Version), 0x00, s.seq, 0x00, 0x00, byte(s.opts.ColorSpace), 0x00})
+
+	if s.opts.Version == StreamVersion2 {
+		buf.WriteString(s.opts.ConfigurationID)
+	}
+
+	for _, id := range s.order {
+		if s.opts.Version == StreamVersion2 {
+			buf.WriteByte(byte(id))
+		} else {
+			buf.WriteByte(0x00)
+			_ = binary.Write(&buf, binary.BigEndian, id)
+		}
+		_ = binary.Write(&buf, binary.BigEndian, s.channels[id])
+	}
+
+	return buf.Bytes()
+}
+
+func scaleStreamValue(v float64) uint16 {
+	return uint16(math.Round(math.Max(0, math.Min(1, v)) * math.MaxUint16))
+}
@@ -0,0 +1,148 @@
+package huego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+const testClientKey = "33DDF493992908E3D97AAAA5A6F189E1"
+
+// newStreamTestServer starts a DTLS-PSK stand-in for the bridge entertainment endpoint.
+// Every message received is sent on the returned channel.
+func newStreamTestServer(t *testing.T) (int, <-chan []byte, func()) {
+
+	frames := make(chan []byte, 100)
+
+	config := &dtls.Config{
+		PSK: func(identity []byte) ([]byte, error) {
+			assert.Equal(t, "testuser", string(identity))
+			return []byte{0x33, 0xDD, 0xF4, 0x93, 0x99, 0x29, 0x08, 0xE3, 0xD9, 0x7A, 0xAA, 0xA5, 0xA6, 0xF1, 0x89, 0xE1}, nil
+		},
+		CipherSuites: []dtls.CipherSuiteID{dtls.TLS_PSK_WITH_AES_128_GCM_SHA256},
+	}
+
+	l, err := dtls.Listen("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			frame := make([]byte, n)
+			copy(frame, buf[:n])
+			frames <- frame
+		}
+	}()
+
+	return l.Addr().(*net.UDPAddr).Port, frames, func() { l.Close() }
+}
+
+func TestStreamVersion1(t *testing.T) {
+
+	port, frames, stop := newStreamTestServer(t)
+	defer stop()
+
+	b := New("127.0.0.1", "testuser")
+	s, err := b.StartStream(StreamOptions{ClientKey: testClientKey, Port: port, Rate: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.SetChannel(3, 1, 0.5, 0)
+	s.SetChannelColor(5, color.RGBA{R: 0, G: 0, B: 255, A: 255})
+	err = s.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame := <-frames
+	assert.Equal(t, []byte("HueStream"), frame[:9])
+	assert.Equal(t, byte(1), frame[9])
+	assert.Equal(t, byte(ColorSpaceRGB), frame[14])
+	assert.Len(t, frame, 16+2*9)
+
+	body := frame[16:]
+	assert.Equal(t, byte(0x00), body[0])
+	assert.Equal(t, uint16(3), binary.BigEndian.Uint16(body[1:3]))
+	assert.Equal(t, uint16(0xffff), binary.BigEndian.Uint16(body[3:5]))
+	assert.Equal(t, uint16(0x8000), binary.BigEndian.Uint16(body[5:7]))
+	assert.Equal(t, uint16(0), binary.BigEndian.Uint16(body[7:9]))
+	assert.Equal(t, uint16(5), binary.BigEndian.Uint16(body[10:12]))
+	assert.Equal(t, uint16(0xffff), binary.BigEndian.Uint16(body[16:18]))
+
+	assert.Nil(t, s.Err())
+
+	// The deferred Close after this one is a no-op
+	err = s.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, err, s.Close())
+}
+
+func TestStreamVersion2(t *testing.T) {
+
+	port, frames, stop := newStreamTestServer(t)
+	defer stop()
+
+	b := New("http://127.0.0.1", "testuser")
+	s, err := b.StartStream(StreamOptions{
+		ClientKey:       testClientKey,
+		Version:         StreamVersion2,
+		ConfigurationID: testLightID,
+		ColorSpace:      ColorSpaceXY,
+		Port:            port,
+		Rate:            10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.SetChannel(0, 0.3, 0.3, 1)
+
+	// Frames are sent on every tick without calling Flush
+	var frame []byte
+	for frame == nil || len(frame) == 52 {
+		frame = <-frames
+	}
+	assert.Equal(t, byte(2), frame[9])
+	assert.Equal(t, byte(ColorSpaceXY), frame[14])
+	assert.True(t, bytes.Equal([]byte(testLightID), frame[16:52]))
+	assert.Len(t, frame, 52+7)
+	assert.Equal(t, byte(0), frame[52])
+	assert.Equal(t, uint16(0xffff), binary.BigEndian.Uint16(frame[57:59]))
+}
+
+func TestStartStreamError(t *testing.T) {
+	b := New("127.0.0.1", "testuser")
+
+	_, err := b.StartStream(StreamOptions{ClientKey: "not hex"})
+	assert.NotNil(t, err)
+
+	_, err = b.StartStream(StreamOptions{ClientKey: testClientKey, Version: StreamVersion2})
+	assert.NotNil(t, err)
+
+	_, err = b.StartStream(StreamOptions{ClientKey: testClientKey, Version: 3})
+	assert.NotNil(t, err)
+
+	b = New("invalid hostname", "testuser")
+	_, err = b.StartStream(StreamOptions{ClientKey: testClientKey})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,101 @@
+package huego
+
+import (
+	"strings"
+)
+
+// Error types returned by the bridge, https://developers.meethue.com/develop/hue-api/error-messages/
+const (
+	ErrorTypeUnauthorizedUser         = 1
+	ErrorTypeInvalidJSON              = 2
+	ErrorTypeResourceNotAvailable     = 3
+	ErrorTypeMethodNotAvailable       = 4
+	ErrorTypeMissingParameters        = 5
+	ErrorTypeParameterNotAvailable    = 6
+	ErrorTypeInvalidValue             = 7
+	ErrorTypeParameterNotModifiable   = 8
+	ErrorTypeTooManyItems             = 11
+	ErrorTypePortalConnectionRequired = 12
+	ErrorTypeLinkButtonNotPressed     = 101
+	ErrorTypeDHCPCannotBeDisabled     = 110
+	ErrorTypeInvalidUpdateState       = 111
+	ErrorTypeDeviceOff                = 201
+	ErrorTypeGroupTableFull           = 301
+	ErrorTypeDeviceGroupTableFull     = 302
+	ErrorTypeGroupNotModifiable       = 305
+	ErrorTypeLightAlreadyInRoom       = 306
+	ErrorTypeSceneNotCreated          = 401
+	ErrorTypeSceneBufferFull          = 402
+	ErrorTypeSensorTypeNotAllowed     = 501
+	ErrorTypeSensorListFull           = 502
+	ErrorTypeRuleEngineFull           = 601
+	ErrorTypeConditionError           = 607
+	ErrorTypeActionError              = 608
+	ErrorTypeUnableToActivate         = 609
+	ErrorTypeScheduleListFull         = 701
+	ErrorTypeScheduleTimezoneInvalid  = 702
+	ErrorTypeScheduleTimeConflict     = 703
+	ErrorTypeScheduleNotCreated       = 704
+	ErrorTypeScheduleTimeInPast       = 705
+	ErrorTypeCommandError             = 706
+	ErrorTypeInternalError            = 901
+)
+
+// Errors to compare an error returned by the bridge with using errors.Is, for example errors.Is(err, huego.ErrDeviceOff).
+// Only the type of the errors is compared.
+var (
+	ErrUnauthorized           = &APIError{Type: ErrorTypeUnauthorizedUser, Description: "unauthorized user"}
+	ErrInvalidJSON            = &APIError{Type: ErrorTypeInvalidJSON, Description: "body contains invalid json"}
+	ErrResourceNotAvailable   = &APIError{Type: ErrorTypeResourceNotAvailable, Description: "resource not available"}
+	ErrMethodNotAvailable     = &APIError{Type: ErrorTypeMethodNotAvailable, Description: "method not available for resource"}
+	ErrMissingParameters      = &APIError{Type: ErrorTypeMissingParameters, Description: "missing parameters in body"}
+	ErrParameterNotAvailable  = &APIError{Type: ErrorTypeParameterNotAvailable, Description: "parameter not available"}
+	ErrInvalidValue           = &APIError{Type: ErrorTypeInvalidValue, Description: "invalid value for parameter"}
+	ErrParameterNotModifiable = &APIError{Type: ErrorTypeParameterNotModifiable, Description: "parameter is not modifiable"}
+	ErrTooManyItems           = &APIError{Type: ErrorTypeTooManyItems, Description: "too many items in list"}
+	ErrLinkButtonNotPressed   = &APIError{Type: ErrorTypeLinkButtonNotPressed, Description: "link button not pressed"}
+	ErrDeviceOff              = &APIError{Type: ErrorTypeDeviceOff, Description: "parameter is not modifiable, device is off"}
+	ErrGroupTableFull         = &APIError{Type: ErrorTypeGroupTableFull, Description: "group could not be created, group table full"}
+	ErrSensorListFull         = &APIError{Type: ErrorTypeSensorListFull, Description: "sensor list is full"}
+	ErrRuleEngineFull         = &APIError{Type: ErrorTypeRuleEngineFull, Description: "rule engine full"}
+	ErrScheduleListFull       = &APIError{Type: ErrorTypeScheduleListFull, Description: "schedule list is full"}
+	ErrInternal               = &APIError{Type: ErrorTypeInternalError, Description: "internal error"}
+)
+
+// Is reports whether target is an APIError of the same type. Used by errors.Is
+func (a *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Type == a.Type
+}
+
+// APIErrors holds every error in a response from the bridge. It is returned instead of an APIError
+// when a request, such as setting several parameters of a light, fails in more than one way.
+type APIErrors []*APIError
+
+// Error returns the errors separated by semicolons
+func (e APIErrors) Error() string {
+	s := make([]string, len(e))
+	for i, a := range e {
+		s[i] = a.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+// Is reports whether any of the errors matches target. Used by errors.Is
+func (e APIErrors) Is(target error) bool {
+	for _, a := range e {
+		if a == target || a.Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As sets target to the first error if it is a **APIError. Used by errors.As
+func (e APIErrors) As(target interface{}) bool {
+	if t, ok := target.(**APIError); ok && len(e) > 0 {
+		*t = e[0]
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package huego
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+
+	err := error(&APIError{Type: ErrorTypeDeviceOff, Address: "/lights/1/state/bri", Description: "parameter, bri, is not modifiable. Device is set to off."})
+	assert.True(t, errors.Is(err, ErrDeviceOff))
+	assert.False(t, errors.Is(err, ErrUnauthorized))
+
+	wrapped := fmt.Errorf("setting light: %w", err)
+	assert.True(t, errors.Is(wrapped, ErrDeviceOff))
+
+	var apiErr *APIError
+	assert.True(t, errors.As(wrapped, &apiErr))
+	assert.Equal(t, "/lights/1/state/bri", apiErr.Address)
+}
+
+func TestHandleResponseErrors(t *testing.T) {
+
+	var a []*APIResponse
+	err := json.Unmarshal([]byte(`[
+		{"success":{"/lights/1/state/on":true}},
+		{"error":{"type":6,"address":"/lights/1/state/xy","description":"parameter, xy, not available"}},
+		{"error":{"type":201,"address":"/lights/1/state/bri","description":"parameter, bri, is not modifiable. Device is set to off."}}
+	]`), &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = handleResponse(a)
+	errs, ok := err.(APIErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 2)
+	assert.True(t, errors.Is(err, ErrParameterNotAvailable))
+	assert.True(t, errors.Is(err, ErrDeviceOff))
+	assert.False(t, errors.Is(err, ErrInvalidValue))
+	assert.Equal(t, `ERROR 6 [/lights/1/state/xy]: "parameter, xy, not available"; ERROR 201 [/lights/1/state/bri]: "parameter, bri, is not modifiable. Device is set to off."`, err.Error())
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, ErrorTypeParameterNotAvailable, apiErr.Type)
+
+	// A single error is returned as an APIError
+	_, err = handleResponse(a[:2])
+	_, ok = err.(*APIError)
+	assert.True(t, ok)
+
+	resp, err := handleResponse(a[:1])
+	assert.Nil(t, err)
+	assert.Equal(t, true, resp.Success["/lights/1/state/on"])
+}
+
+func TestAPIErrorUnmarshalJSON(t *testing.T) {
+
+	var a APIError
+	assert.Nil(t, json.Unmarshal([]byte(`{"type":1}`), &a))
+	assert.Equal(t, APIError{Type: ErrorTypeUnauthorizedUser}, a)
+
+	a = APIError{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"type":"1","address":2,"description":null}`), &a))
+	assert.Equal(t, APIError{}, a)
+
+	assert.NotNil(t, json.Unmarshal([]byte(`[]`), &a))
+
+	err := unmarshal([]byte(`[{"error":{"type":1}}]`), &map[string]interface{}{})
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestUnauthorizedUser(t *testing.T) {
+	b := New(hostname, "invalid_password")
+	_, err := b.GetLights()
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
@@ -0,0 +1,322 @@
+package huego
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	eventStreamPath = "/eventstream/clip/v2"
+	lastEventID     = "Last-Event-ID"
+	textEventStream = "text/event-stream"
+)
+
+// Event types sent by the bridge on the event stream
+const (
+	EventAdd    = "add"
+	EventUpdate = "update"
+	EventDelete = "delete"
+	EventError  = "error"
+)
+
+// The delay between reconnect attempts starts at eventStreamMinBackoff and is doubled
+// for every failed attempt until it reaches eventStreamMaxBackoff.
+var (
+	eventStreamMinBackoff = time.Second
+	eventStreamMaxBackoff = 30 * time.Second
+)
+
+// Event represents a change to one resource received on the bridge event stream https://developers.meethue.com/develop/hue-api-v2/migration-guide-to-the-new-hue-api/
+// Depending on ResourceType one of Light, Motion, Button or Connectivity is set.
+// Data always holds the raw resource data as sent by the bridge.
+// Err is only set on the last event before the stream is closed because the bridge refused the connection.
+type Event struct {
+	ID           string
+	Type         string
+	CreationTime time.Time
+	ResourceID   string
+	ResourceType string
+	IDV1         string
+	Owner        *ResourceIdentifier
+	Light        *LightEvent
+	Motion       *MotionEvent
+	Button       *ButtonEvent
+	Connectivity *ConnectivityEvent
+	Data         json.RawMessage
+	Err          error
+}
+
+// LightEvent holds the light properties that changed. Properties not part of the change are nil.
+type LightEvent struct {
+	On         *bool
+	Brightness *float64
+	Xy         []float64
+	Mirek      *uint16
+}
+
+// MotionEvent holds the state of a motion sensor
+type MotionEvent struct {
+	Motion      bool
+	MotionValid bool
+}
+
+// ButtonEvent holds the last event of a button, for example initial_press, short_release or long_press
+type ButtonEvent struct {
+	LastEvent string
+}
+
+// ConnectivityEvent holds the zigbee connectivity status of a device, for example connected or connectivity_issue
+type ConnectivityEvent struct {
+	Status string
+}
+
+type eventContainer struct {
+	CreationTime time.Time         `json:"creationtime"`
+	ID           string            `json:"id"`
+	Type         string            `json:"type"`
+	Data         []json.RawMessage `json:"data"`
+}
+
+type eventData struct {
+	ID     string              `json:"id"`
+	IDV1   string              `json:"id_v1"`
+	Type   string              `json:"type"`
+	Owner  *ResourceIdentifier `json:"owner"`
+	Status string              `json:"status"`
+	On     *struct {
+		On bool `json:"on"`
+	} `json:"on"`
+	Dimming *struct {
+		Brightness float64 `json:"brightness"`
+	} `json:"dimming"`
+	Color *struct {
+		Xy struct {
+			X float64 `json:"x"`
+			Y float64 `json:"y"`
+		} `json:"xy"`
+	} `json:"color"`
+	ColorTemperature *struct {
+		Mirek      *uint16 `json:"mirek"`
+		MirekValid bool    `json:"mirek_valid"`
+	} `json:"color_temperature"`
+	Motion *struct {
+		Motion      bool `json:"motion"`
+		MotionValid bool `json:"motion_valid"`
+	} `json:"motion"`
+	Button *struct {
+		LastEvent string `json:"last_event"`
+	} `json:"button"`
+}
+
+// Events opens the bridge event stream and returns a channel on which resource changes are delivered.
+// The connection is re-established with an increasing delay whenever it is lost. The channel is closed once ctx is done.
+// If the bridge refuses the connection with 401, 403 or 404, which retrying won't fix, an event of type EventError
+// is delivered with Err set and the channel is closed. Err wraps ErrUnauthorized or ErrResourceNotAvailable.
+// Events requires an https enabled bridge that serves the CLIP v2 API. Use NewWithClient to trust the bridge certificate.
+func (b *Bridge) Events(ctx context.Context) (<-chan *Event, error) {
+
+	target, err := b.getV2Path(eventStreamPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *Event)
+	go b.streamEvents(ctx, target, ch)
+
+	return ch, nil
+}
+
+func (b *Bridge) streamEvents(ctx context.Context, target string, ch chan<- *Event) {
+
+	defer close(ch)
+
+	var last string
+	backoff := eventStreamMinBackoff
+
+	for {
+		n, err := b.readEventStream(ctx, target, &last, ch)
+		if n > 0 {
+			backoff = eventStreamMinBackoff
+		}
+
+		if se, ok := err.(*eventStreamError); ok && se.Unwrap() != nil {
+			select {
+			case ch <- &Event{Type: EventError, Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > eventStreamMaxBackoff {
+			backoff = eventStreamMaxBackoff
+		}
+	}
+}
+
+// readEventStream reads events from one connection to the event stream until it is closed.
+// It returns the number of events delivered on ch. last is updated with the id of each received message
+// so that a reconnect can resume where the previous connection ended.
+func (b *Bridge) readEventStream(ctx context.Context, target string, last *string, ch chan<- *Event) (int, error) {
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	req = req.WithContext(ctx)
+
+	req.Header.Set(applicationKey, b.User)
+	req.Header.Set("Accept", textEventStream)
+	if *last != "" {
+		req.Header.Set(lastEventID, *last)
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return 0, &eventStreamError{code: res.StatusCode, status: res.Status}
+	}
+
+	n := 0
+	var data bytes.Buffer
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			events, err := decodeEvents(data.Bytes())
+			data.Reset()
+			if err != nil {
+				continue
+			}
+			for _, e := range events {
+				select {
+				case ch <- e:
+					n++
+				case <-ctx.Done():
+					return n, ctx.Err()
+				}
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comment, sent by the bridge as a keep-alive
+		case strings.HasPrefix(line, "id:"):
+			*last = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	return n, scanner.Err()
+}
+
+// eventStreamError is the status of a refused connection to the event stream
+type eventStreamError struct {
+	code   int
+	status string
+}
+
+func (e *eventStreamError) Error() string {
+	return "unexpected status from event stream: " + e.status
+}
+
+// Unwrap returns the error for the statuses that retrying won't fix, nil for others
+func (e *eventStreamError) Unwrap() error {
+	switch e.code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrResourceNotAvailable
+	}
+	return nil
+}
+
+// decodeEvents decodes the data of one event stream message into a list of events, one per changed resource.
+func decodeEvents(data []byte) ([]*Event, error) {
+
+	var containers []eventContainer
+
+	err := json.Unmarshal(data, &containers)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*Event{}
+
+	for _, c := range containers {
+		for _, raw := range c.Data {
+			var d eventData
+			err = json.Unmarshal(raw, &d)
+			if err != nil {
+				return nil, err
+			}
+			e := &Event{
+				ID:           c.ID,
+				Type:         c.Type,
+				CreationTime: c.CreationTime,
+				ResourceID:   d.ID,
+				ResourceType: d.Type,
+				IDV1:         d.IDV1,
+				Owner:        d.Owner,
+				Data:         raw,
+			}
+			d.populate(e)
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
+
+func (d *eventData) populate(e *Event) {
+	switch d.Type {
+	case "light":
+		l := &LightEvent{}
+		if d.On != nil {
+			l.On = &d.On.On
+		}
+		if d.Dimming != nil {
+			l.Brightness = &d.Dimming.Brightness
+		}
+		if d.Color != nil {
+			l.Xy = []float64{d.Color.Xy.X, d.Color.Xy.Y}
+		}
+		if d.ColorTemperature != nil && d.ColorTemperature.MirekValid {
+			l.Mirek = d.ColorTemperature.Mirek
+		}
+		e.Light = l
+	case "motion":
+		if d.Motion != nil {
+			e.Motion = &MotionEvent{Motion: d.Motion.Motion, MotionValid: d.Motion.MotionValid}
+		}
+	case "button":
+		if d.Button != nil {
+			e.Button = &ButtonEvent{LastEvent: d.Button.LastEvent}
+		}
+	case "zigbee_connectivity":
+		e.Connectivity = &ConnectivityEvent{Status: d.Status}
+	}
+}
@@ -0,0 +1,137 @@
+package huego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testEventStream = `: hi
+
+id: 1634576695:0
+data: [{"creationtime":"2021-10-18T17:04:55Z","data":[{"id":"9e1c5f2a-7b3a-4d4f-8a1e-3c6b1b0c2d11","id_v1":"/lights/1","on":{"on":true},"dimming":{"brightness":52.5},"color":{"xy":{"x":0.4573,"y":0.41}},"owner":{"rid":"f1d2b6c8-0a3e-4b5c-9d7e-8f6a5b4c3d21","rtype":"device"},"type":"light"}],"id":"ee3c7c25-ab7a-4e2b-8f4b-1d9c6b0a9f01","type":"update"}]
+
+id: 1634576696:0
+data: [{"creationtime":"2021-10-18T17:04:56Z","data":[{"id":"b6e8a0c2-3f4d-4e5a-9b8c-7d6e5f4a3b21","id_v1":"/sensors/5","motion":{"motion":true,"motion_valid":true},"type":"motion"},{"id":"c7f9b1d3-4a5e-4f6b-8c9d-8e7f6a5b4c32","id_v1":"/sensors/10","button":{"last_event":"short_release"},"type":"button"}],"id":"ff4d8d36-bc8b-4f3c-9a5c-2e0d7c1b0a12","type":"update"}]
+
+id: 1634576697:0
+data: [{"creationtime":"2021-10-18T17:04:57Z","data":[{"id":"d8a0c2e4-5b6f-4a7c-9d0e-9f8a7b6c5d43","status":"connectivity_issue","type":"zigbee_connectivity"}],"id":"0a5e9e47-cd9c-4a4d-8b6d-3f1e8d2c1b23","type":"update"}]
+
+`
+
+func TestEvents(t *testing.T) {
+
+	var mu sync.Mutex
+	var lastIDs []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, eventStreamPath, r.URL.Path)
+		assert.Equal(t, "testuser", r.Header.Get(applicationKey))
+		mu.Lock()
+		lastIDs = append(lastIDs, r.Header.Get(lastEventID))
+		mu.Unlock()
+		w.Header().Set(contentType, textEventStream)
+		fmt.Fprint(w, testEventStream)
+	}))
+	defer srv.Close()
+
+	eventStreamMinBackoff = time.Millisecond
+	defer func() { eventStreamMinBackoff = time.Second }()
+
+	b := NewWithClient(srv.URL, "testuser", srv.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := b.Events(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := <-events
+	assert.Equal(t, EventUpdate, e.Type)
+	assert.Equal(t, "light", e.ResourceType)
+	assert.Equal(t, "/lights/1", e.IDV1)
+	assert.Equal(t, "device", e.Owner.RType)
+	if assert.NotNil(t, e.Light) {
+		assert.True(t, *e.Light.On)
+		assert.Equal(t, 52.5, *e.Light.Brightness)
+		assert.Equal(t, []float64{0.4573, 0.41}, e.Light.Xy)
+		assert.Nil(t, e.Light.Mirek)
+	}
+
+	e = <-events
+	assert.Equal(t, "motion", e.ResourceType)
+	if assert.NotNil(t, e.Motion) {
+		assert.True(t, e.Motion.Motion)
+	}
+
+	e = <-events
+	assert.Equal(t, "button", e.ResourceType)
+	if assert.NotNil(t, e.Button) {
+		assert.Equal(t, "short_release", e.Button.LastEvent)
+	}
+
+	e = <-events
+	assert.Equal(t, "zigbee_connectivity", e.ResourceType)
+	if assert.NotNil(t, e.Connectivity) {
+		assert.Equal(t, "connectivity_issue", e.Connectivity.Status)
+	}
+
+	// The server closes the connection after each response so the next event is received on a new connection
+	e = <-events
+	assert.Equal(t, "light", e.ResourceType)
+
+	cancel()
+	for range events {
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, "", lastIDs[0])
+	assert.Equal(t, "1634576697:0", lastIDs[1])
+}
+
+func TestEventsRefused(t *testing.T) {
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	b := NewWithClient(srv.URL, "unknown", srv.Client())
+	events, err := b.Events(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := <-events
+	assert.True(t, ok)
+	assert.Equal(t, EventError, e.Type)
+	assert.True(t, errors.Is(e.Err, ErrUnauthorized))
+	assert.EqualError(t, e.Err, "unexpected status from event stream: 403 Forbidden")
+
+	_, ok = <-events
+	assert.False(t, ok)
+	assert.Equal(t, 1, requests)
+}
+
+func TestEventsError(t *testing.T) {
+	b := New("invalid hostname", "")
+	_, err := b.Events(context.Background())
+	assert.NotNil(t, err)
+}
+
+func Test_decodeEventsError(t *testing.T) {
+	_, err := decodeEvents([]byte("not json"))
+	assert.NotNil(t, err)
+}
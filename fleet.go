@@ -0,0 +1,361 @@
+package huego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Fleet holds several bridges keyed by their bridge id. Operations on all bridges run concurrently and
+// resources are addressed across bridges as <bridge id>/<resource>/<id>, for example 001788fffe73ff19/lights/3.
+// Bridge ids are case insensitive. A Fleet is safe for concurrent use.
+type Fleet struct {
+	mu      sync.RWMutex
+	bridges map[string]*Bridge
+}
+
+// FleetError holds the errors of the bridges that failed in an operation on a Fleet, keyed by bridge id.
+// The results of the other bridges are returned along with it.
+type FleetError map[string]error
+
+// Error returns the errors of the bridges in the order of their ids
+func (e FleetError) Error() string {
+	ids := make([]string, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = fmt.Sprintf("%s: %v", id, e[id])
+	}
+	return strings.Join(s, "; ")
+}
+
+// Is reports whether the error of any bridge matches target. Used by errors.Is
+func (e FleetError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Address is the address of a resource on a bridge in a Fleet
+type Address struct {
+	BridgeID string
+	Resource string
+	ID       string
+}
+
+// ParseAddress parses an address such as 001788fffe73ff19/lights/3
+func ParseAddress(s string) (*Address, error) {
+	p := strings.Split(strings.Trim(s, "/"), "/")
+	if len(p) != 3 || p[0] == "" || p[1] == "" || p[2] == "" {
+		return nil, fmt.Errorf("invalid address %q, must be <bridge id>/<resource>/<id>", s)
+	}
+	return &Address{BridgeID: strings.ToLower(p[0]), Resource: p[1], ID: p[2]}, nil
+}
+
+// String returns the address as <bridge id>/<resource>/<id>
+func (a *Address) String() string {
+	return a.BridgeID + "/" + a.Resource + "/" + a.ID
+}
+
+// FleetLight is a light in a Fleet
+type FleetLight struct {
+	Light
+	// Address is the address of the light in the fleet
+	Address string
+}
+
+// FleetGroup is a group in a Fleet
+type FleetGroup struct {
+	Group
+	// Address is the address of the group in the fleet
+	Address string
+}
+
+// NewFleet returns an empty fleet
+func NewFleet() *Fleet {
+	return &Fleet{bridges: map[string]*Bridge{}}
+}
+
+// Add adds bridge b to the fleet. The id of the bridge is read from the bridge configuration if b.ID is empty.
+func (f *Fleet) Add(b *Bridge) error {
+	return f.AddContext(context.Background(), b)
+}
+
+// AddContext adds bridge b to the fleet. The id of the bridge is read from the bridge configuration if b.ID is empty.
+func (f *Fleet) AddContext(ctx context.Context, b *Bridge) error {
+
+	if b.ID == "" {
+		c, err := b.GetConfigContext(ctx)
+		if err != nil {
+			return err
+		}
+		b.ID = c.BridgeID
+	}
+
+	id := strings.ToLower(b.ID)
+	if id == "" {
+		return errors.New("bridge has no id")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.bridges[id]; ok {
+		return fmt.Errorf("bridge %s is already in the fleet", id)
+	}
+	f.bridges[id] = b
+
+	return nil
+}
+
+// Remove removes the bridge with id from the fleet
+func (f *Fleet) Remove(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.bridges, strings.ToLower(id))
+}
+
+// Bridge returns the bridge with id
+func (f *Fleet) Bridge(id string) (*Bridge, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	b, ok := f.bridges[strings.ToLower(id)]
+	return b, ok
+}
+
+// IDs returns the ids of the bridges in the fleet in order
+func (f *Fleet) IDs() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	ids := make([]string, 0, len(f.bridges))
+	for id := range f.bridges {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Each calls fn concurrently for every bridge in the fleet and waits for the calls to return.
+// The errors returned by fn are returned as a FleetError.
+func (f *Fleet) Each(ctx context.Context, fn func(ctx context.Context, id string, b *Bridge) error) error {
+
+	f.mu.RLock()
+	bridges := make(map[string]*Bridge, len(f.bridges))
+	for id, b := range f.bridges {
+		bridges[id] = b
+	}
+	f.mu.RUnlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := FleetError{}
+
+	for id, b := range bridges {
+		wg.Add(1)
+		go func(id string, b *Bridge) {
+			defer wg.Done()
+			err := fn(ctx, id, b)
+			if err != nil {
+				mu.Lock()
+				errs[id] = err
+				mu.Unlock()
+			}
+		}(id, b)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// GetLights returns the lights of all bridges in the order of their addresses
+func (f *Fleet) GetLights() ([]FleetLight, error) {
+	return f.GetLightsContext(context.Background())
+}
+
+// GetLightsContext returns the lights of all bridges in the order of their addresses. The lights of the
+// bridges that could be reached are returned along with a FleetError if some bridges failed.
+func (f *Fleet) GetLightsContext(ctx context.Context) ([]FleetLight, error) {
+
+	var mu sync.Mutex
+	var lights []FleetLight
+
+	err := f.Each(ctx, func(ctx context.Context, id string, b *Bridge) error {
+		l, err := b.GetLightsContext(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, light := range l {
+			lights = append(lights, FleetLight{Light: light, Address: id + "/lights/" + strconv.Itoa(light.ID)})
+		}
+		return nil
+	})
+
+	sort.Slice(lights, func(i, j int) bool {
+		return lessAddress(lights[i].Address, lights[j].Address)
+	})
+
+	return lights, err
+}
+
+// GetGroups returns the groups of all bridges in the order of their addresses
+func (f *Fleet) GetGroups() ([]FleetGroup, error) {
+	return f.GetGroupsContext(context.Background())
+}
+
+// GetGroupsContext returns the groups of all bridges in the order of their addresses. The groups of the
+// bridges that could be reached are returned along with a FleetError if some bridges failed.
+func (f *Fleet) GetGroupsContext(ctx context.Context) ([]FleetGroup, error) {
+
+	var mu sync.Mutex
+	var groups []FleetGroup
+
+	err := f.Each(ctx, func(ctx context.Context, id string, b *Bridge) error {
+		g, err := b.GetGroupsContext(ctx)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, group := range g {
+			groups = append(groups, FleetGroup{Group: group, Address: id + "/groups/" + strconv.Itoa(group.ID)})
+		}
+		return nil
+	})
+
+	sort.Slice(groups, func(i, j int) bool {
+		return lessAddress(groups[i].Address, groups[j].Address)
+	})
+
+	return groups, err
+}
+
+// SetState sets the state of all lights of all bridges through group 0
+func (f *Fleet) SetState(s StateChange) error {
+	return f.SetStateContext(context.Background(), s)
+}
+
+// SetStateContext sets the state of all lights of all bridges through group 0
+func (f *Fleet) SetStateContext(ctx context.Context, s StateChange) error {
+	return f.Each(ctx, func(ctx context.Context, id string, b *Bridge) error {
+		_, err := b.SetGroupStateContext(ctx, 0, s)
+		return err
+	})
+}
+
+// Off turns off all lights of all bridges
+func (f *Fleet) Off() error {
+	return f.OffContext(context.Background())
+}
+
+// OffContext turns off all lights of all bridges
+func (f *Fleet) OffContext(ctx context.Context) error {
+	return f.SetStateContext(ctx, State{On: false})
+}
+
+// GetLight returns the light at address, for example 001788fffe73ff19/lights/3
+func (f *Fleet) GetLight(address string) (*Light, error) {
+	return f.GetLightContext(context.Background(), address)
+}
+
+// GetLightContext returns the light at address, for example 001788fffe73ff19/lights/3
+func (f *Fleet) GetLightContext(ctx context.Context, address string) (*Light, error) {
+	b, i, err := f.resolve(address, CollectionLights)
+	if err != nil {
+		return nil, err
+	}
+	return b.GetLightContext(ctx, i)
+}
+
+// SetLightState sets the state of the light at address
+func (f *Fleet) SetLightState(address string, s StateChange) (*Response, error) {
+	return f.SetLightStateContext(context.Background(), address, s)
+}
+
+// SetLightStateContext sets the state of the light at address
+func (f *Fleet) SetLightStateContext(ctx context.Context, address string, s StateChange) (*Response, error) {
+	b, i, err := f.resolve(address, CollectionLights)
+	if err != nil {
+		return nil, err
+	}
+	return b.SetLightStateContext(ctx, i, s)
+}
+
+// GetGroup returns the group at address, for example 001788fffe73ff19/groups/1
+func (f *Fleet) GetGroup(address string) (*Group, error) {
+	return f.GetGroupContext(context.Background(), address)
+}
+
+// GetGroupContext returns the group at address, for example 001788fffe73ff19/groups/1
+func (f *Fleet) GetGroupContext(ctx context.Context, address string) (*Group, error) {
+	b, i, err := f.resolve(address, CollectionGroups)
+	if err != nil {
+		return nil, err
+	}
+	return b.GetGroupContext(ctx, i)
+}
+
+// SetGroupState sets the state of the group at address
+func (f *Fleet) SetGroupState(address string, s StateChange) (*Response, error) {
+	return f.SetGroupStateContext(context.Background(), address, s)
+}
+
+// SetGroupStateContext sets the state of the group at address
+func (f *Fleet) SetGroupStateContext(ctx context.Context, address string, s StateChange) (*Response, error) {
+	b, i, err := f.resolve(address, CollectionGroups)
+	if err != nil {
+		return nil, err
+	}
+	return b.SetGroupStateContext(ctx, i, s)
+}
+
+// resolve returns the bridge and numeric id of the resource at address, which must be in collection resource
+func (f *Fleet) resolve(address, resource string) (*Bridge, int, error) {
+
+	a, err := ParseAddress(address)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if a.Resource != resource {
+		return nil, 0, fmt.Errorf("address %q is not one of %s", address, resource)
+	}
+
+	i, err := strconv.Atoi(a.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid id in address %q", address)
+	}
+
+	b, ok := f.Bridge(a.BridgeID)
+	if !ok {
+		return nil, 0, fmt.Errorf("bridge %s is not in the fleet", a.BridgeID)
+	}
+
+	return b, i, nil
+}
+
+// lessAddress orders addresses by bridge id, resource and id
+func lessAddress(a, b string) bool {
+	pa, pb := strings.SplitN(a, "/", 3), strings.SplitN(b, "/", 3)
+	for i := 0; i < 2; i++ {
+		if pa[i] != pb[i] {
+			return pa[i] < pb[i]
+		}
+	}
+	return lessID(pa[2], pb[2])
+}
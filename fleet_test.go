@@ -0,0 +1,94 @@
+package huego_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/amimof/huego"
+	"github.com/amimof/huego/huegotest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFleet(t *testing.T) {
+
+	office := huegotest.NewServer()
+	defer office.Close()
+	lab := huegotest.NewServer()
+	defer lab.Close()
+
+	f := huego.NewFleet()
+
+	// The id is read from the bridge configuration when it isn't set
+	assert.Nil(t, f.Add(office.Bridge()))
+	b := lab.Bridge()
+	b.ID = "ECB5FAFFFE000001"
+	assert.Nil(t, f.Add(b))
+	assert.NotNil(t, f.Add(office.Bridge()))
+	assert.Equal(t, []string{"001788fffe73ff19", "ecb5fafffe000001"}, f.IDs())
+
+	lights, err := f.GetLights()
+	assert.Nil(t, err)
+	assert.Len(t, lights, 6)
+	assert.Equal(t, "001788fffe73ff19/lights/1", lights[0].Address)
+	assert.Equal(t, "ecb5fafffe000001/lights/3", lights[5].Address)
+	assert.Equal(t, "Hue ambiance lamp 1", lights[5].Name)
+
+	_, err = f.SetLightState("ECB5FAFFFE000001/lights/2", huego.State{On: true})
+	assert.Nil(t, err)
+	l, err := f.GetLight("ecb5fafffe000001/lights/2")
+	assert.Nil(t, err)
+	assert.True(t, l.State.On)
+	l, err = f.GetLight("001788fffe73ff19/lights/2")
+	assert.Nil(t, err)
+	assert.False(t, l.State.On)
+
+	assert.Nil(t, f.Off())
+	lights, err = f.GetLights()
+	assert.Nil(t, err)
+	for _, l := range lights {
+		assert.False(t, l.State.On, l.Address)
+	}
+
+	_, err = f.GetLight("001788fffe73ff19/groups/1")
+	assert.NotNil(t, err)
+	_, err = f.GetLight("0000000000000000/lights/1")
+	assert.NotNil(t, err)
+	_, err = f.GetLight("lights/1")
+	assert.NotNil(t, err)
+	_, err = f.GetLight("001788fffe73ff19/lights/7")
+	assert.True(t, errors.Is(err, huego.ErrResourceNotAvailable))
+}
+
+func TestFleetErrors(t *testing.T) {
+
+	office := huegotest.NewServer()
+	defer office.Close()
+	gone := huegotest.NewServer()
+	gone.Close()
+
+	f := huego.NewFleet()
+	assert.Nil(t, f.Add(office.Bridge()))
+	b := gone.Bridge()
+	b.ID = "ecb5fafffe000001"
+	assert.Nil(t, f.Add(b))
+
+	// The lights of the bridges that could be reached are returned
+	lights, err := f.GetLights()
+	assert.Len(t, lights, 3)
+	errs, ok := err.(huego.FleetError)
+	assert.True(t, ok)
+	assert.Len(t, errs, 1)
+	assert.NotNil(t, errs["ecb5fafffe000001"])
+
+	f.Remove("ECB5FAFFFE000001")
+	assert.Nil(t, f.Each(context.Background(), func(ctx context.Context, id string, b *huego.Bridge) error {
+		return nil
+	}))
+
+	err = f.Each(context.Background(), func(ctx context.Context, id string, b *huego.Bridge) error {
+		return huego.ErrDeviceOff
+	})
+	assert.True(t, errors.Is(err, huego.ErrDeviceOff))
+	assert.Equal(t, `001788fffe73ff19: ERROR 201 []: "parameter is not modifiable, device is off"`, err.Error())
+}
@@ -0,0 +1,51 @@
+package huego
+
+// FullState holds every resource of the bridge as returned in a single request by GET /api/<user>.
+// Resources are keyed by their id, which is also set on each resource.
+type FullState struct {
+	Lights        map[int]*Light        `json:"lights"`
+	Groups        map[int]*Group        `json:"groups"`
+	Config        *Config               `json:"config"`
+	Schedules     map[int]*Schedule     `json:"schedules"`
+	Scenes        map[string]*Scene     `json:"scenes"`
+	Rules         map[int]*Rule         `json:"rules"`
+	Sensors       map[int]*Sensor       `json:"sensors"`
+	Resourcelinks map[int]*Resourcelink `json:"resourcelinks"`
+}
+
+// init populates the ids of the resources in s and attaches them to bridge b
+func (s *FullState) init(b *Bridge) {
+	for id, l := range s.Lights {
+		l.ID = id
+		l.bridge = b
+	}
+	for id, g := range s.Groups {
+		g.ID = id
+		g.bridge = b
+	}
+	for id, sc := range s.Schedules {
+		sc.ID = id
+	}
+	for id, sc := range s.Scenes {
+		sc.ID = id
+		sc.bridge = b
+	}
+	for id, r := range s.Rules {
+		r.ID = id
+	}
+	for id, sn := range s.Sensors {
+		sn.ID = id
+		sn.bridge = b
+	}
+	for id, r := range s.Resourcelinks {
+		r.ID = id
+	}
+	if s.Config != nil {
+		wl := make([]Whitelist, 0, len(s.Config.WhitelistMap))
+		for k, v := range s.Config.WhitelistMap {
+			v.Username = k
+			wl = append(wl, v)
+		}
+		s.Config.Whitelist = wl
+	}
+}
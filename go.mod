@@ -4,6 +4,9 @@ go 1.13
 
 require (
 	github.com/jarcoal/httpmock v1.0.4
-	github.com/stretchr/testify v1.4.0
-	golang.org/x/net v0.0.0-20220127200216-cd36cc0744dd
+	github.com/pion/dtls/v2 v2.1.5
+	github.com/prometheus/client_golang v1.11.1
+	github.com/stretchr/testify v1.7.0
+	golang.org/x/net v0.0.0-20220425223048-2871e0cb64e4
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -40,9 +40,11 @@ func (a *APIError) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
-	a.Type = int(aux["type"].(float64))
-	a.Address = aux["address"].(string)
-	a.Description = aux["description"].(string)
+	if t, ok := aux["type"].(float64); ok {
+		a.Type = int(t)
+	}
+	a.Address, _ = aux["address"].(string)
+	a.Description, _ = aux["description"].(string)
 	return nil
 }
 
@@ -51,18 +53,30 @@ func (a *APIError) Error() string {
 	return fmt.Sprintf("ERROR %d [%s]: \"%s\"", a.Type, a.Address, a.Description)
 }
 
+// handleResponse merges the successes in a. An APIError is returned if a contains one error and APIErrors if it contains more.
 func handleResponse(a []*APIResponse) (*Response, error) {
 	success := map[string]interface{}{}
+	var errs APIErrors
 	for _, r := range a {
+		if r == nil {
+			continue
+		}
 		if r.Success != nil {
 			for k, v := range r.Success {
 				success[k] = v
 			}
 		}
 		if r.Error != nil {
-			return nil, r.Error
+			errs = append(errs, r.Error)
 		}
 	}
+	switch len(errs) {
+	case 0:
+	case 1:
+		return nil, errs[0]
+	default:
+		return nil, errs
+	}
 	resp := &Response{Success: success}
 	return resp, nil
 }
@@ -195,54 +209,27 @@ func del(ctx context.Context, url string, client *http.Client) ([]byte, error) {
 }
 
 // DiscoverAll performs a discovery on the network looking for bridges using https://www.meethue.com/api/nupnp service.
-// DiscoverAll returns a list of Bridge objects.
+// DiscoverAll returns a list of Bridge objects. Use DiscoverAllUsing with AllDiscovery to also search the local network using mDNS and SSDP.
 func DiscoverAll() ([]Bridge, error) {
 	return DiscoverAllContext(context.Background())
 }
 
 // DiscoverAllContext performs a discovery on the network looking for bridges using https://www.meethue.com/api/nupnp service.
-// DiscoverAllContext returns a list of Bridge objects.
+// DiscoverAllContext returns a list of Bridge objects. Use DiscoverAllUsing to discover bridges on networks without internet access.
 func DiscoverAllContext(ctx context.Context) ([]Bridge, error) {
-
-	req, err := http.NewRequest(http.MethodGet, "https://discovery.meethue.com", nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req = req.WithContext(ctx)
-
-	client := http.DefaultClient
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	defer res.Body.Close()
-
-	d, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var bridges []Bridge
-
-	err = json.Unmarshal(d, &bridges)
-	if err != nil {
-		return nil, err
-	}
-
-	return bridges, nil
-
+	return (&CloudDiscovery{}).Discover(ctx)
 }
 
 // Discover performs a discovery on the network looking for bridges using https://www.meethue.com/api/nupnp service.
 // Discover uses DiscoverAll() but only returns the first instance in the array of bridges if any.
+// Use DiscoverUsing with AllDiscovery to also search the local network using mDNS and SSDP.
 func Discover() (*Bridge, error) {
 	return DiscoverContext(context.Background())
 }
 
 // DiscoverContext performs a discovery on the network looking for bridges using https://www.meethue.com/api/nupnp service.
 // DiscoverContext uses DiscoverAllContext() but only returns the first instance in the array of bridges if any.
+// Use DiscoverUsing with AllDiscovery to also search the local network using mDNS and SSDP.
 func DiscoverContext(ctx context.Context) (*Bridge, error) {
 
 	b := &Bridge{}
@@ -286,3 +273,43 @@ func NewWithClient(h, u string, client *http.Client) *Bridge {
 		client: client,
 	}
 }
+
+// layer is a transport that huego puts in front of the transport of a bridge's http client, such as the ones
+// installed by NewCache and Bridge.SetScheduler
+type layer interface {
+	http.RoundTripper
+	// unwrap returns the transport the layer sends requests to
+	unwrap() http.RoundTripper
+	// wrap returns a copy of the layer that sends requests to next
+	wrap(next http.RoundTripper) layer
+}
+
+// replaceLayer removes the layers that remove matches from anywhere in the transport chain of b and adds l,
+// if not nil, closest to the underlying transport. The http client of b is copied, and the layers above
+// the removed ones are copied too, so that clients and layers shared with other bridges are left as is.
+func (b *Bridge) replaceLayer(remove func(layer) bool, l layer) {
+
+	var layers []layer
+	next := b.client.Transport
+	for {
+		current, ok := next.(layer)
+		if !ok {
+			break
+		}
+		if !remove(current) {
+			layers = append(layers, current)
+		}
+		next = current.unwrap()
+	}
+
+	if l != nil {
+		layers = append(layers, l)
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		next = layers[i].wrap(next)
+	}
+
+	c := *b.client
+	c.Transport = next
+	b.client = &c
+}
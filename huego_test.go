@@ -1,9 +1,16 @@
 package huego
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/jarcoal/httpmock"
@@ -477,3 +484,93 @@ func Test_unmarshalError(t *testing.T) {
 	err := unmarshal([]byte(`not json`), s)
 	assert.NotNil(t, err)
 }
+
+// recorder is a bridge stand-in that records the requests it receives. Requests are answered by handler,
+// which is given the number of the request since the recorder was created or reset, or with a success if
+// handler is nil.
+type recorder struct {
+	*httptest.Server
+	handler func(w http.ResponseWriter, r *http.Request, n int)
+
+	mu   sync.Mutex
+	reqs []recordedRequest
+}
+
+// recordedRequest is a request received by a recorder
+type recordedRequest struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+func newRecorder(handler func(w http.ResponseWriter, r *http.Request, n int)) *recorder {
+	s := &recorder{handler: handler}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *recorder) serve(w http.ResponseWriter, r *http.Request) {
+	data, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	s.mu.Lock()
+	s.reqs = append(s.reqs, recordedRequest{Method: r.Method, Path: apiPath(r), Body: data})
+	n := len(s.reqs)
+	s.mu.Unlock()
+
+	if s.handler == nil {
+		w.Write([]byte(`[{"success":{}}]`))
+		return
+	}
+	s.handler(w, r, n)
+}
+
+// bridge returns a bridge that sends its requests to the recorder
+func (s *recorder) bridge() *Bridge {
+	return NewWithClient(s.URL, username, s.Client())
+}
+
+// requests returns the requests received since the recorder was created or reset
+func (s *recorder) requests() []recordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]recordedRequest(nil), s.reqs...)
+}
+
+// bodies returns the JSON bodies of the requests received since the recorder was created or reset
+func (s *recorder) bodies() []map[string]interface{} {
+	var bodies []map[string]interface{}
+	for _, r := range s.requests() {
+		body := map[string]interface{}{}
+		json.Unmarshal(r.Body, &body)
+		bodies = append(bodies, body)
+	}
+	return bodies
+}
+
+// count returns the number of requests received with method to path
+func (s *recorder) count(method, path string) int {
+	n := 0
+	for _, r := range s.requests() {
+		if r.Method == method && r.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// reset forgets the requests received so far
+func (s *recorder) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reqs = nil
+}
+
+// apiPath returns the path of r relative to the user, such as /lights/1
+func apiPath(r *http.Request) string {
+	p := strings.TrimPrefix(r.URL.Path, path.Join("/api", username))
+	if p == "" {
+		return "/"
+	}
+	return p
+}
@@ -0,0 +1,200 @@
+// Package huegoprom exposes the state of a Philips Hue bridge as Prometheus metrics.
+//
+//	prometheus.MustRegister(huegoprom.NewCollector(bridge))
+//	http.Handle("/metrics", promhttp.Handler())
+//
+// Every scrape reads the lights, groups, sensors and configuration of the bridge.
+package huegoprom
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/amimof/huego"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "hue"
+
+// UpdateStates are the software update states of the bridge, reported by hue_bridge_swupdate_state
+var UpdateStates = []string{"unknown", "noupdates", "transferring", "anyreadytoinstall", "allreadytoinstall", "installing"}
+
+var (
+	lightLabels  = []string{"id", "name", "type"}
+	groupLabels  = []string{"id", "name", "type"}
+	sensorLabels = []string{"id", "name", "type"}
+
+	lightOn          = newDesc("light_on", "Whether the light is on", lightLabels)
+	lightBrightness  = newDesc("light_brightness", "Brightness of the light, 1-254", lightLabels)
+	lightReachable   = newDesc("light_reachable", "Whether the light can be reached by the bridge", lightLabels)
+	groupAnyOn       = newDesc("group_any_on", "Whether any light in the group is on", groupLabels)
+	groupAllOn       = newDesc("group_all_on", "Whether all lights in the group are on", groupLabels)
+	sensorTemp       = newDesc("sensor_temperature_celsius", "Temperature measured by the sensor", sensorLabels)
+	sensorLightLevel = newDesc("sensor_lightlevel", "Light level measured by the sensor, 10000 log10(lux) + 1", sensorLabels)
+	sensorLux        = newDesc("sensor_light_lux", "Light level measured by the sensor in lux", sensorLabels)
+	sensorPresence   = newDesc("sensor_presence", "Whether the sensor detects presence", sensorLabels)
+	sensorBattery    = newDesc("sensor_battery_percent", "Battery level of the sensor", sensorLabels)
+	bridgeInfo       = newDesc("bridge_info", "Information about the bridge", []string{"id", "name", "modelid", "swversion", "apiversion"})
+	bridgeUpdate     = newDesc("bridge_swupdate_state", "Software update state of the bridge, 1 for the current state", []string{"state"})
+	scrapeSuccess    = newDesc("scrape_success", "Whether the resource could be read from the bridge", []string{"resource"})
+)
+
+func newDesc(name, help string, labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+}
+
+// Collector is a prometheus.Collector for a bridge. Resources that can't be read are left out and
+// reported with hue_scrape_success 0. Use prometheus.WrapRegistererWith to tell several bridges apart.
+type Collector struct {
+	// Timeout of each scrape. Defaults to 10 seconds
+	Timeout time.Duration
+	bridge  *huego.Bridge
+}
+
+// NewCollector returns a collector for b
+func NewCollector(b *huego.Bridge) *Collector {
+	return &Collector{Timeout: 10 * time.Second, bridge: b}
+}
+
+// Describe sends the descriptions of the metrics to ch
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		lightOn, lightBrightness, lightReachable, groupAnyOn, groupAllOn, sensorTemp, sensorLightLevel,
+		sensorLux, sensorPresence, sensorBattery, bridgeInfo, bridgeUpdate, scrapeSuccess,
+	} {
+		ch <- d
+	}
+}
+
+// Collect reads the bridge and sends the metrics to ch
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, r := range []struct {
+		name    string
+		collect func(context.Context, chan<- prometheus.Metric) error
+	}{
+		{"lights", c.collectLights},
+		{"groups", c.collectGroups},
+		{"sensors", c.collectSensors},
+		{"config", c.collectConfig},
+	} {
+		err := r.collect(ctx, ch)
+		ch <- prometheus.MustNewConstMetric(scrapeSuccess, prometheus.GaugeValue, boolValue(err == nil), r.name)
+	}
+}
+
+func (c *Collector) collectLights(ctx context.Context, ch chan<- prometheus.Metric) error {
+
+	lights, err := c.bridge.GetLightsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range lights {
+		if l.State == nil {
+			continue
+		}
+		labels := []string{strconv.Itoa(l.ID), l.Name, l.Type}
+		ch <- prometheus.MustNewConstMetric(lightOn, prometheus.GaugeValue, boolValue(l.State.On), labels...)
+		ch <- prometheus.MustNewConstMetric(lightBrightness, prometheus.GaugeValue, float64(l.State.Bri), labels...)
+		ch <- prometheus.MustNewConstMetric(lightReachable, prometheus.GaugeValue, boolValue(l.State.Reachable), labels...)
+	}
+
+	return nil
+}
+
+func (c *Collector) collectGroups(ctx context.Context, ch chan<- prometheus.Metric) error {
+
+	groups, err := c.bridge.GetGroupsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range groups {
+		if g.GroupState == nil {
+			continue
+		}
+		labels := []string{strconv.Itoa(g.ID), g.Name, g.Type}
+		ch <- prometheus.MustNewConstMetric(groupAnyOn, prometheus.GaugeValue, boolValue(g.GroupState.AnyOn), labels...)
+		ch <- prometheus.MustNewConstMetric(groupAllOn, prometheus.GaugeValue, boolValue(g.GroupState.AllOn), labels...)
+	}
+
+	return nil
+}
+
+func (c *Collector) collectSensors(ctx context.Context, ch chan<- prometheus.Metric) error {
+
+	sensors, err := c.bridge.GetSensorsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sensors {
+
+		labels := []string{strconv.Itoa(s.ID), s.Name, s.Type}
+
+		// Values that the sensor hasn't reported yet are null and left out
+		state, err := s.TypedState()
+		if err == nil {
+			switch st := state.(type) {
+			case *huego.TemperatureState:
+				if s.State["temperature"] != nil {
+					ch <- prometheus.MustNewConstMetric(sensorTemp, prometheus.GaugeValue, st.Celsius(), labels...)
+				}
+			case *huego.LightLevelState:
+				if s.State["lightlevel"] != nil {
+					ch <- prometheus.MustNewConstMetric(sensorLightLevel, prometheus.GaugeValue, float64(st.LightLevel), labels...)
+					ch <- prometheus.MustNewConstMetric(sensorLux, prometheus.GaugeValue, st.Lux(), labels...)
+				}
+			case *huego.PresenceState:
+				if s.State["presence"] != nil {
+					ch <- prometheus.MustNewConstMetric(sensorPresence, prometheus.GaugeValue, boolValue(st.Presence), labels...)
+				}
+			}
+		}
+
+		var config huego.SensorConfig
+		if s.DecodeConfig(&config) == nil && config.Battery != nil {
+			ch <- prometheus.MustNewConstMetric(sensorBattery, prometheus.GaugeValue, float64(*config.Battery), labels...)
+		}
+	}
+
+	return nil
+}
+
+func (c *Collector) collectConfig(ctx context.Context, ch chan<- prometheus.Metric) error {
+
+	config, err := c.bridge.GetConfigContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(bridgeInfo, prometheus.GaugeValue, 1, config.BridgeID, config.Name, config.ModelID, config.SwVersion, config.APIVersion)
+
+	state := config.SwUpdate2.State
+	known := false
+	for _, s := range UpdateStates {
+		known = known || s == state
+		ch <- prometheus.MustNewConstMetric(bridgeUpdate, prometheus.GaugeValue, boolValue(s == state), s)
+	}
+	if !known && state != "" {
+		ch <- prometheus.MustNewConstMetric(bridgeUpdate, prometheus.GaugeValue, 1, state)
+	}
+
+	return nil
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package huegoprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/amimof/huego"
+	"github.com/amimof/huego/huegotest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	defer srv.Close()
+
+	srv.AddSensor(huego.Sensor{
+		Name:   "Hue temperature sensor 1",
+		Type:   huego.SensorTypeZLLTemperature,
+		State:  map[string]interface{}{"temperature": 2150, "lastupdated": "2021-01-01T00:00:00"},
+		Config: map[string]interface{}{"on": true, "battery": 90, "reachable": true},
+	})
+	srv.AddSensor(huego.Sensor{
+		Name:  "Hue ambient light sensor 1",
+		Type:  huego.SensorTypeZLLLightLevel,
+		State: map[string]interface{}{"lightlevel": 10001, "dark": false, "daylight": true, "lastupdated": "2021-01-01T00:00:00"},
+	})
+	// A motion sensor that hasn't reported presence yet
+	srv.AddSensor(huego.Sensor{
+		Name:  "Hue motion sensor 1",
+		Type:  huego.SensorTypeZLLPresence,
+		State: map[string]interface{}{"presence": nil, "lastupdated": "none"},
+	})
+
+	b := srv.Bridge()
+	_, err := b.CreateGroup(huego.Group{Name: "Living room", Type: "Room", Lights: []string{"1", "2"}})
+	assert.Nil(t, err)
+
+	c := NewCollector(b)
+
+	err = testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP hue_light_on Whether the light is on
+# TYPE hue_light_on gauge
+hue_light_on{id="1",name="Hue color lamp 1",type="Extended color light"} 1
+hue_light_on{id="2",name="Hue white lamp 1",type="Dimmable light"} 0
+hue_light_on{id="3",name="Hue ambiance lamp 1",type="Color temperature light"} 0
+# HELP hue_light_brightness Brightness of the light, 1-254
+# TYPE hue_light_brightness gauge
+hue_light_brightness{id="1",name="Hue color lamp 1",type="Extended color light"} 254
+hue_light_brightness{id="2",name="Hue white lamp 1",type="Dimmable light"} 127
+hue_light_brightness{id="3",name="Hue ambiance lamp 1",type="Color temperature light"} 200
+# HELP hue_group_any_on Whether any light in the group is on
+# TYPE hue_group_any_on gauge
+hue_group_any_on{id="1",name="Living room",type="Room"} 1
+# HELP hue_group_all_on Whether all lights in the group are on
+# TYPE hue_group_all_on gauge
+hue_group_all_on{id="1",name="Living room",type="Room"} 0
+# HELP hue_sensor_temperature_celsius Temperature measured by the sensor
+# TYPE hue_sensor_temperature_celsius gauge
+hue_sensor_temperature_celsius{id="2",name="Hue temperature sensor 1",type="ZLLTemperature"} 21.5
+# HELP hue_sensor_lightlevel Light level measured by the sensor, 10000 log10(lux) + 1
+# TYPE hue_sensor_lightlevel gauge
+hue_sensor_lightlevel{id="3",name="Hue ambient light sensor 1",type="ZLLLightLevel"} 10001
+# HELP hue_sensor_light_lux Light level measured by the sensor in lux
+# TYPE hue_sensor_light_lux gauge
+hue_sensor_light_lux{id="3",name="Hue ambient light sensor 1",type="ZLLLightLevel"} 10
+# HELP hue_sensor_battery_percent Battery level of the sensor
+# TYPE hue_sensor_battery_percent gauge
+hue_sensor_battery_percent{id="2",name="Hue temperature sensor 1",type="ZLLTemperature"} 90
+# HELP hue_bridge_info Information about the bridge
+# TYPE hue_bridge_info gauge
+hue_bridge_info{apiversion="1.46.0",id="001788FFFE73FF19",modelid="BSB002",name="Philips hue",swversion="1946157000"} 1
+# HELP hue_scrape_success Whether the resource could be read from the bridge
+# TYPE hue_scrape_success gauge
+hue_scrape_success{resource="config"} 1
+hue_scrape_success{resource="groups"} 1
+hue_scrape_success{resource="lights"} 1
+hue_scrape_success{resource="sensors"} 1
+`),
+		"hue_light_on", "hue_light_brightness", "hue_group_any_on", "hue_group_all_on", "hue_sensor_temperature_celsius",
+		"hue_sensor_lightlevel", "hue_sensor_light_lux", "hue_sensor_presence", "hue_sensor_battery_percent",
+		"hue_bridge_info", "hue_scrape_success")
+	assert.Nil(t, err)
+
+	assert.Equal(t, len(UpdateStates), testutil.CollectAndCount(c, "hue_bridge_swupdate_state"))
+}
+
+func TestCollectorUnreachable(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	b := srv.Bridge()
+	srv.Close()
+
+	err := testutil.CollectAndCompare(NewCollector(b), strings.NewReader(`
+# HELP hue_scrape_success Whether the resource could be read from the bridge
+# TYPE hue_scrape_success gauge
+hue_scrape_success{resource="config"} 0
+hue_scrape_success{resource="groups"} 0
+hue_scrape_success{resource="lights"} 0
+hue_scrape_success{resource="sensors"} 0
+`))
+	assert.Nil(t, err)
+}
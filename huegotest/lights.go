@@ -0,0 +1,433 @@
+package huegotest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amimof/huego"
+)
+
+// Ranges of the numeric light state parameters
+var stateRanges = map[string][2]float64{
+	"bri": {1, 254},
+	"hue": {0, 65535},
+	"sat": {0, 254},
+	"ct":  {153, 500},
+}
+
+// Color modes set by the color parameters
+var colorModes = map[string]string{
+	"xy":  "xy",
+	"hue": "hs",
+	"sat": "hs",
+	"ct":  "ct",
+}
+
+// setLightState applies body to the state of light id
+func (s *Server) setLightState(id string, body object) interface{} {
+
+	address := fmt.Sprintf("/lights/%s/state", id)
+	state := s.resources["lights"][id]["state"].(map[string]interface{})
+
+	var res []interface{}
+	for _, k := range stateKeys(body) {
+
+		a := address + "/" + k
+		base := strings.TrimSuffix(k, "_inc")
+
+		if _, ok := state[base]; (!ok && k != "transitiontime") || base == "reachable" || base == "colormode" {
+			res = append(res, apiError(huego.ErrorTypeParameterNotAvailable, a, fmt.Sprintf("parameter, %s, not available", k)))
+			continue
+		}
+
+		if on, _ := state["on"].(bool); !on && k != "on" && k != "alert" && k != "transitiontime" {
+			res = append(res, apiError(huego.ErrorTypeDeviceOff, a, fmt.Sprintf("parameter, %s, is not modifiable. Device is set to off.", k)))
+			continue
+		}
+
+		if err := applyState(state, k, body[k]); err != nil {
+			res = append(res, apiError(huego.ErrorTypeInvalidValue, a, err.Error()))
+			continue
+		}
+
+		res = append(res, success(a, body[k]))
+	}
+
+	return res
+}
+
+// setGroupAction applies body to all lights in group g. Parameters that a light does not
+// support are silently ignored, the same way the bridge does.
+func (s *Server) setGroupAction(id string, g object, body object) interface{} {
+
+	address := fmt.Sprintf("/groups/%s/action", id)
+
+	action, _ := g["action"].(map[string]interface{})
+	if action == nil {
+		action = map[string]interface{}{}
+		g["action"] = action
+	}
+
+	lights := toStrings(g["lights"])
+
+	var res []interface{}
+	for _, k := range stateKeys(body) {
+
+		a := address + "/" + k
+
+		if k == "scene" {
+			err := s.recallScene(body[k], lights)
+			if err != nil {
+				res = append(res, err)
+				continue
+			}
+			res = append(res, success(a, body[k]))
+			continue
+		}
+
+		if _, ok := stateRanges[strings.TrimSuffix(k, "_inc")]; !ok && !contains([]string{"on", "xy", "xy_inc", "alert", "effect", "transitiontime"}, k) {
+			res = append(res, apiError(huego.ErrorTypeParameterNotAvailable, a, fmt.Sprintf("parameter, %s, not available", k)))
+			continue
+		}
+
+		if err := applyState(map[string]interface{}{}, k, body[k]); err != nil {
+			res = append(res, apiError(huego.ErrorTypeInvalidValue, a, err.Error()))
+			continue
+		}
+
+		for _, lid := range lights {
+			l, ok := s.resources["lights"][lid]
+			if !ok {
+				continue
+			}
+			state := l["state"].(map[string]interface{})
+			if _, ok := state[strings.TrimSuffix(k, "_inc")]; !ok {
+				continue
+			}
+			if on, _ := state["on"].(bool); !on && k != "on" {
+				continue
+			}
+			_ = applyState(state, k, body[k])
+		}
+
+		if !strings.HasSuffix(k, "_inc") && k != "transitiontime" {
+			action[k] = body[k]
+		}
+
+		res = append(res, success(a, body[k]))
+	}
+
+	return res
+}
+
+// applyState sets parameter k of state to v, it returns an error describing an invalid value
+func applyState(state map[string]interface{}, k string, v interface{}) error {
+
+	invalid := fmt.Errorf("invalid value, %v, for parameter, %s", v, k)
+
+	switch k {
+	case "on":
+		if _, ok := v.(bool); !ok {
+			return invalid
+		}
+	case "alert":
+		if !contains([]string{"none", "select", "lselect"}, fmt.Sprint(v)) {
+			return invalid
+		}
+	case "effect":
+		if !contains([]string{"none", "colorloop"}, fmt.Sprint(v)) {
+			return invalid
+		}
+	case "transitiontime":
+		if _, ok := v.(float64); !ok {
+			return invalid
+		}
+		return nil
+	case "xy":
+		xy, ok := v.([]interface{})
+		if !ok || len(xy) != 2 {
+			return invalid
+		}
+		for _, c := range xy {
+			f, ok := c.(float64)
+			if !ok || f < 0 || f > 1 {
+				return invalid
+			}
+		}
+	case "xy_inc":
+		inc, ok := v.(float64)
+		if !ok {
+			return invalid
+		}
+		xy, _ := state["xy"].([]interface{})
+		if len(xy) != 2 {
+			return nil
+		}
+		state["xy"] = []interface{}{clamp(xy[0].(float64)+inc, 0, 1), clamp(xy[1].(float64)+inc, 0, 1)}
+		state["colormode"] = "xy"
+		return nil
+	default:
+		f, ok := v.(float64)
+		if !ok {
+			return invalid
+		}
+		if base := strings.TrimSuffix(k, "_inc"); base != k {
+			r := stateRanges[base]
+			cur, _ := state[base].(float64)
+			state[base] = clamp(cur+f, r[0], r[1])
+			setColorMode(state, base)
+			return nil
+		}
+		r := stateRanges[k]
+		if f < r[0] || f > r[1] {
+			return invalid
+		}
+	}
+
+	state[k] = v
+	setColorMode(state, k)
+
+	return nil
+}
+
+// setColorMode updates the color mode of lights that have one
+func setColorMode(state map[string]interface{}, k string) {
+	if _, ok := state["colormode"]; !ok {
+		return
+	}
+	if m, ok := colorModes[k]; ok {
+		state["colormode"] = m
+	}
+}
+
+// recallScene applies the light states of scene id to the lights that are both in the scene and in lights
+func (s *Server) recallScene(id interface{}, lights []string) map[string]interface{} {
+
+	sid := fmt.Sprint(id)
+
+	sc, ok := s.resources["scenes"][sid]
+	if !ok {
+		return apiError(huego.ErrorTypeInvalidValue, "/scenes", fmt.Sprintf("invalid value, %s, for parameter, scene", sid))
+	}
+
+	states, _ := sc["lightstates"].(map[string]interface{})
+	for _, lid := range lights {
+		ls, ok := states[lid].(map[string]interface{})
+		l, found := s.resources["lights"][lid]
+		if !ok || !found {
+			continue
+		}
+		state := l["state"].(map[string]interface{})
+		for _, k := range stateKeys(ls) {
+			if _, ok := state[k]; ok {
+				_ = applyState(state, k, ls[k])
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) setSceneLightState(id, lid string, sc, body object) interface{} {
+
+	address := fmt.Sprintf("/scenes/%s/lightstates/%s", id, lid)
+
+	if !contains(toStrings(sc["lights"]), lid) {
+		return []interface{}{apiError(huego.ErrorTypeResourceNotAvailable, address, fmt.Sprintf("resource, %s, not available", address))}
+	}
+
+	states, _ := sc["lightstates"].(map[string]interface{})
+	if states == nil {
+		states = map[string]interface{}{}
+		sc["lightstates"] = states
+	}
+	ls, _ := states[lid].(map[string]interface{})
+	if ls == nil {
+		ls = map[string]interface{}{}
+		states[lid] = ls
+	}
+
+	var res []interface{}
+	for _, k := range stateKeys(body) {
+		if err := applyState(map[string]interface{}{}, k, body[k]); err != nil || strings.HasSuffix(k, "_inc") {
+			res = append(res, apiError(huego.ErrorTypeInvalidValue, address+"/"+k, fmt.Sprintf("invalid value, %v, for parameter, %s", body[k], k)))
+			continue
+		}
+		ls[k] = body[k]
+		res = append(res, success(address+"/"+k, body[k]))
+	}
+
+	sc["lastupdated"] = time.Now().UTC().Format(timeFormat)
+
+	return res
+}
+
+// prepareGroup validates a new group and sets the attributes the bridge sets on creation
+func (s *Server) prepareGroup(o object) map[string]interface{} {
+
+	setDefault(o, "type", "LightGroup")
+
+	typ := fmt.Sprint(o["type"])
+	if !contains([]string{"LightGroup", "Room", "Zone", "Entertainment"}, typ) {
+		return apiError(huego.ErrorTypeInvalidValue, "/groups/type", fmt.Sprintf("invalid value, %s, for parameter, type", typ))
+	}
+
+	lights, ok := o["lights"]
+	if !ok && typ != "Room" {
+		return apiError(huego.ErrorTypeMissingParameters, "/groups", "invalid/missing parameters in body")
+	}
+	if !ok {
+		lights = []interface{}{}
+		o["lights"] = lights
+	}
+	if err := s.validateLights(lights); err != nil {
+		return err
+	}
+
+	if typ == "Room" || typ == "Zone" {
+		setDefault(o, "class", "Other")
+	}
+
+	action := map[string]interface{}{"on": false}
+	if l := toStrings(lights); len(l) > 0 {
+		for k, v := range s.resources["lights"][l[0]]["state"].(map[string]interface{}) {
+			if k != "reachable" && k != "mode" {
+				action[k] = v
+			}
+		}
+	}
+	o["action"] = action
+	o["recycle"] = false
+
+	return nil
+}
+
+// prepareScene validates a new scene and stores the current state of its lights
+func (s *Server) prepareScene(user string, o object) map[string]interface{} {
+
+	if g, ok := o["group"]; ok {
+		group, ok := s.get("groups", fmt.Sprint(g))
+		if !ok {
+			return apiError(huego.ErrorTypeInvalidValue, "/scenes/group", fmt.Sprintf("invalid value, %v, for parameter, group", g))
+		}
+		// Without the type the bridge creates a LightScene, which can't have a group
+		if o["type"] != "GroupScene" {
+			return apiError(huego.ErrorTypeParameterNotAvailable, "/scenes/group", "parameter, group, not available")
+		}
+		o["lights"] = group["lights"]
+	} else {
+		o["type"] = "LightScene"
+	}
+
+	lights, ok := o["lights"]
+	if !ok {
+		return apiError(huego.ErrorTypeMissingParameters, "/scenes", "invalid/missing parameters in body")
+	}
+	if err := s.validateLights(lights); err != nil {
+		return err
+	}
+
+	if _, ok := o["lightstates"].(map[string]interface{}); !ok {
+		o["lightstates"] = s.snapshot(lights)
+	}
+
+	delete(o, "storelightstate")
+	setDefault(o, "recycle", false)
+	o["owner"] = user
+	o["locked"] = false
+	o["version"] = 2
+	o["lastupdated"] = time.Now().UTC().Format(timeFormat)
+
+	return nil
+}
+
+// snapshot returns the current states of lights as scene light states
+func (s *Server) snapshot(lights interface{}) map[string]interface{} {
+	states := map[string]interface{}{}
+	for _, id := range toStrings(lights) {
+		l, ok := s.resources["lights"][id]
+		if !ok {
+			continue
+		}
+		state := l["state"].(map[string]interface{})
+		ls := map[string]interface{}{"on": state["on"]}
+		if bri, ok := state["bri"]; ok {
+			ls["bri"] = bri
+		}
+		switch state["colormode"] {
+		case "xy":
+			ls["xy"] = state["xy"]
+		case "hs":
+			ls["hue"], ls["sat"] = state["hue"], state["sat"]
+		case "ct":
+			ls["ct"] = state["ct"]
+		}
+		states[id] = ls
+	}
+	return states
+}
+
+// groupState returns the all_on and any_on state of group g
+func (s *Server) groupState(g object) map[string]interface{} {
+	lights := toStrings(g["lights"])
+	on := 0
+	for _, id := range lights {
+		if l, ok := s.resources["lights"][id]; ok {
+			if v, _ := l["state"].(map[string]interface{})["on"].(bool); v {
+				on++
+			}
+		}
+	}
+	return map[string]interface{}{"all_on": len(lights) > 0 && on == len(lights), "any_on": on > 0}
+}
+
+// validateLights returns an error if lights is not a list of existing light ids
+func (s *Server) validateLights(lights interface{}) map[string]interface{} {
+	l, ok := lights.([]interface{})
+	if !ok {
+		return apiError(huego.ErrorTypeInvalidValue, "/groups/lights", fmt.Sprintf("invalid value, %v, for parameter, lights", lights))
+	}
+	for _, id := range l {
+		if _, ok := s.resources["lights"][fmt.Sprint(id)]; !ok {
+			return apiError(huego.ErrorTypeInvalidValue, "/groups/lights", fmt.Sprintf("invalid value, %v, for parameter, lights", id))
+		}
+	}
+	return nil
+}
+
+// stateKeys returns the keys of body in the order they are applied, on is always applied first
+// so that a light can be turned on and changed in the same request.
+func stateKeys(body map[string]interface{}) []string {
+	keys := make([]string, 0, len(body))
+	for k := range body {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i] == "on" || keys[j] == "on" {
+			return keys[i] == "on"
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func toStrings(l interface{}) []string {
+	items, _ := l.([]interface{})
+	res := make([]string, 0, len(items))
+	for _, v := range items {
+		res = append(res, fmt.Sprint(v))
+	}
+	return res
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
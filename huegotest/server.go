@@ -0,0 +1,736 @@
+// Package huegotest provides an in-process fake Philips Hue bridge for use in tests.
+//
+// The fake bridge keeps state between requests, so resources created through the API
+// show up when listed and light states set through the API are reflected when read back.
+// Errors are returned the same way a real bridge returns them, using the bridge error type codes.
+//
+//	srv := huegotest.NewServer()
+//	defer srv.Close()
+//
+//	b := srv.Bridge()
+//	lights, err := b.GetLights()
+package huegotest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amimof/huego"
+)
+
+const (
+	// User is the name of the user that is whitelisted on every new Server
+	User = "huegotest"
+
+	// LinkButtonTimeout is the duration the link button stays pressed after calling PressLinkButton
+	LinkButtonTimeout = 30 * time.Second
+
+	timeFormat = "2006-01-02T15:04:05"
+)
+
+// Resource collections served below /api/<user>/
+var collections = []string{"lights", "groups", "scenes", "sensors", "rules", "schedules", "resourcelinks"}
+
+// object is a decoded bridge resource
+type object map[string]interface{}
+
+// Server is a fake Hue bridge. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	resources  map[string]map[string]object
+	config     object
+	whitelist  map[string]object
+	linkButton time.Time
+	lastScan   map[string]string
+}
+
+// NewServer starts and returns a new fake bridge with three lights and the daylight sensor
+// that every bridge has. The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+
+	s := &Server{
+		resources: map[string]map[string]object{},
+		config: object{
+			"name":       "Philips hue",
+			"apiversion": "1.46.0",
+			"swversion":  "1946157000",
+			"modelid":    "BSB002",
+			"bridgeid":   "001788FFFE73FF19",
+			"mac":        "00:17:88:73:ff:19",
+			"ipaddress":  "127.0.0.1",
+			"netmask":    "255.255.255.0",
+			"gateway":    "127.0.0.1",
+			"dhcp":       true,
+			"timezone":   "Europe/Stockholm",
+			"linkbutton": false,
+		},
+		whitelist: map[string]object{},
+		lastScan:  map[string]string{"lights": "none", "sensors": "none"},
+	}
+
+	for _, c := range collections {
+		s.resources[c] = map[string]object{}
+	}
+
+	now := time.Now().UTC().Format(timeFormat)
+	s.whitelist[User] = object{"name": "huegotest#test", "create date": now, "last use date": now}
+
+	s.AddLight(huego.Light{
+		Name:             "Hue color lamp 1",
+		Type:             "Extended color light",
+		ModelID:          "LCT016",
+		ManufacturerName: "Signify Netherlands B.V.",
+		ProductName:      "Hue color lamp",
+		State:            &huego.State{On: true, Bri: 254, Hue: 8418, Sat: 140, Xy: []float32{0.4573, 0.41}, Ct: 366, Alert: "none", Effect: "none", ColorMode: "ct"},
+		Capabilities: &huego.LightCapabilities{
+			Certified: true,
+			Control:   huego.LightControl{MinDimLevel: 1000, MaxLumen: 800, ColorGamutType: "C", ColorGamut: [][]float32{{0.6915, 0.3083}, {0.17, 0.7}, {0.1532, 0.0475}}, Ct: &huego.CtRange{Min: 153, Max: 500}},
+			Streaming: huego.LightStreaming{Renderer: true, Proxy: true},
+		},
+		Config: &huego.LightConfig{Archetype: "sultanbulb", Function: "mixed", Direction: "omnidirectional"},
+	})
+	s.AddLight(huego.Light{
+		Name:             "Hue white lamp 1",
+		Type:             "Dimmable light",
+		ModelID:          "LWB010",
+		ManufacturerName: "Signify Netherlands B.V.",
+		ProductName:      "Hue white lamp",
+		State:            &huego.State{On: false, Bri: 127, Alert: "none"},
+		Capabilities:     &huego.LightCapabilities{Certified: true, Control: huego.LightControl{MinDimLevel: 5000, MaxLumen: 800}},
+		Config:           &huego.LightConfig{Archetype: "classicbulb", Function: "functional", Direction: "omnidirectional"},
+	})
+	s.AddLight(huego.Light{
+		Name:             "Hue ambiance lamp 1",
+		Type:             "Color temperature light",
+		ModelID:          "LTW001",
+		ManufacturerName: "Signify Netherlands B.V.",
+		ProductName:      "Hue ambiance lamp",
+		State:            &huego.State{On: false, Bri: 200, Ct: 300, Alert: "none", ColorMode: "ct"},
+		Capabilities:     &huego.LightCapabilities{Certified: true, Control: huego.LightControl{MinDimLevel: 1000, MaxLumen: 806, Ct: &huego.CtRange{Min: 153, Max: 454}}},
+		Config:           &huego.LightConfig{Archetype: "classicbulb", Function: "functional", Direction: "omnidirectional"},
+	})
+	s.AddSensor(huego.Sensor{
+		Name:             "Daylight",
+		Type:             "Daylight",
+		ModelID:          "PHDL00",
+		ManufacturerName: "Signify Netherlands B.V.",
+		SwVersion:        "1.0",
+		State:            map[string]interface{}{"daylight": nil, "lastupdated": "none"},
+		Config:           map[string]interface{}{"on": true, "configured": false, "sunriseoffset": 30, "sunsetoffset": -30},
+	})
+
+	s.Server = httptest.NewServer(s)
+
+	return s
+}
+
+// Bridge returns a huego Bridge that talks to the fake bridge as User
+func (s *Server) Bridge() *huego.Bridge {
+	return huego.NewWithClient(s.URL, User, s.Client())
+}
+
+// PressLinkButton simulates pressing the link button on the bridge. New users can be created
+// during LinkButtonTimeout after the button has been pressed.
+func (s *Server) PressLinkButton() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.linkButton = time.Now().Add(LinkButtonTimeout)
+}
+
+// AddLight adds l to the bridge as if it had been paired and returns its id. The parameters present in
+// l.State determine which parameters the light accepts, a light without xy for example cannot be set to a color.
+func (s *Server) AddLight(l huego.Light) int {
+
+	o := toObject(l)
+
+	state, _ := o["state"].(map[string]interface{})
+	if state == nil {
+		state = map[string]interface{}{"on": false, "alert": "none"}
+	}
+	state["reachable"] = true
+	o["state"] = state
+
+	if _, ok := o["uniqueid"]; !ok {
+		o["uniqueid"] = s.uniqueID("lights")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID("lights")
+	s.resources["lights"][id] = o
+
+	i, _ := strconv.Atoi(id)
+	return i
+}
+
+// AddSensor adds sn to the bridge as if it had been paired and returns its id
+func (s *Server) AddSensor(sn huego.Sensor) int {
+
+	o := toObject(sn)
+	delete(o, "ID")
+
+	if _, ok := o["state"]; !ok {
+		o["state"] = map[string]interface{}{"lastupdated": "none"}
+	}
+	if _, ok := o["config"]; !ok {
+		o["config"] = map[string]interface{}{"on": true, "reachable": true}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID("sensors")
+	s.resources["sensors"][id] = o
+
+	i, _ := strconv.Atoi(id)
+	return i
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	parts := strings.Split(strings.Trim(path.Clean(r.URL.Path), "/"), "/")
+	if parts[0] != "api" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var body object
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (r.Method == http.MethodPut || r.Method == http.MethodPost) && len(strings.TrimSpace(string(data))) > 0 {
+		err = json.Unmarshal(data, &body)
+		if err != nil {
+			writeJSON(w, []interface{}{apiError(huego.ErrorTypeInvalidJSON, "", "body contains invalid json")})
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var res interface{}
+	if len(parts) == 1 {
+		res = s.createUser(r.Method, body)
+	} else {
+		res = s.serveUser(r.Method, parts[1], parts[2:], body)
+	}
+
+	writeJSON(w, res)
+}
+
+func (s *Server) serveUser(method, user string, rest []string, body object) interface{} {
+
+	address := "/" + strings.Join(rest, "/")
+
+	wl, ok := s.whitelist[user]
+	if !ok {
+		return []interface{}{apiError(huego.ErrorTypeUnauthorizedUser, address, "unauthorized user")}
+	}
+	wl["last use date"] = time.Now().UTC().Format(timeFormat)
+
+	if len(rest) == 0 {
+		if method != http.MethodGet {
+			return methodNotAvailable(method, address)
+		}
+		return s.fullState()
+	}
+
+	switch rest[0] {
+	case "config":
+		return s.serveConfig(method, rest, body)
+	case "capabilities":
+		if method != http.MethodGet || len(rest) != 1 {
+			return methodNotAvailable(method, address)
+		}
+		return s.capabilities()
+	}
+
+	if _, ok := s.resources[rest[0]]; !ok {
+		return []interface{}{apiError(huego.ErrorTypeResourceNotAvailable, address, fmt.Sprintf("resource, %s, not available", address))}
+	}
+
+	return s.serveCollection(method, user, rest, body)
+}
+
+func (s *Server) createUser(method string, body object) interface{} {
+
+	if method != http.MethodPost {
+		return methodNotAvailable(method, "/")
+	}
+
+	deviceType, _ := body["devicetype"].(string)
+	if deviceType == "" {
+		return []interface{}{apiError(huego.ErrorTypeInvalidValue, "/devicetype", "invalid value, , for parameter, devicetype")}
+	}
+
+	if time.Now().After(s.linkButton) {
+		return []interface{}{apiError(huego.ErrorTypeLinkButtonNotPressed, "", "link button not pressed")}
+	}
+
+	username := randomHex(20)
+	now := time.Now().UTC().Format(timeFormat)
+	s.whitelist[username] = object{"name": deviceType, "create date": now, "last use date": now}
+
+	success := object{"username": username}
+	if generate, _ := body["generateclientkey"].(bool); generate {
+		success["clientkey"] = strings.ToUpper(randomHex(16))
+	}
+
+	return []interface{}{object{"success": success}}
+}
+
+func (s *Server) serveConfig(method string, rest []string, body object) interface{} {
+
+	address := "/" + strings.Join(rest, "/")
+
+	switch {
+	case len(rest) == 1 && method == http.MethodGet:
+		return s.fullConfig()
+	case len(rest) == 1 && method == http.MethodPut:
+		var res []interface{}
+		for _, k := range sortedKeys(body) {
+			if contains(readOnly["config"], k) {
+				continue
+			}
+			s.config[k] = body[k]
+			res = append(res, success(address+"/"+k, body[k]))
+		}
+		return res
+	case len(rest) == 3 && rest[1] == "whitelist" && method == http.MethodDelete:
+		if _, ok := s.whitelist[rest[2]]; !ok {
+			return []interface{}{apiError(huego.ErrorTypeResourceNotAvailable, address, fmt.Sprintf("resource, %s, not available", address))}
+		}
+		delete(s.whitelist, rest[2])
+		return []interface{}{object{"success": address + " deleted"}}
+	}
+
+	return methodNotAvailable(method, address)
+}
+
+func (s *Server) fullConfig() object {
+	c := object{}
+	for k, v := range s.config {
+		c[k] = v
+	}
+	now := time.Now()
+	c["UTC"] = now.UTC().Format(timeFormat)
+	c["localtime"] = now.Format(timeFormat)
+	c["linkbutton"] = now.Before(s.linkButton)
+	c["whitelist"] = s.whitelist
+	return c
+}
+
+func (s *Server) fullState() object {
+	state := object{"config": s.fullConfig()}
+	for _, c := range collections {
+		state[c] = s.list(c)
+	}
+	return state
+}
+
+func (s *Server) capabilities() object {
+	limits := map[string]int{"lights": 63, "groups": 64, "scenes": 200, "sensors": 250, "rules": 250, "schedules": 100, "resourcelinks": 64}
+	c := object{"streaming": object{"available": 1, "total": 1, "channels": 10}}
+	for k, total := range limits {
+		c[k] = object{"available": total - len(s.resources[k]), "total": total}
+	}
+	return c
+}
+
+func (s *Server) serveCollection(method, user string, rest []string, body object) interface{} {
+
+	c := rest[0]
+	address := "/" + strings.Join(rest, "/")
+
+	switch {
+	case len(rest) == 1 && method == http.MethodGet:
+		return s.list(c)
+	case len(rest) == 1 && method == http.MethodPost && len(body) == 0 && (c == "lights" || c == "sensors"):
+		s.lastScan[c] = time.Now().UTC().Format(timeFormat)
+		return []interface{}{success(address, "Searching for new devices")}
+	case len(rest) == 1 && method == http.MethodPost:
+		return s.create(c, user, body)
+	case len(rest) == 2 && rest[1] == "new" && method == http.MethodGet && (c == "lights" || c == "sensors"):
+		return object{"lastscan": s.lastScan[c]}
+	}
+
+	o, ok := s.get(c, rest[1])
+	if !ok {
+		return []interface{}{apiError(huego.ErrorTypeResourceNotAvailable, address, fmt.Sprintf("resource, %s, not available", address))}
+	}
+
+	switch {
+	case len(rest) == 2 && method == http.MethodGet:
+		return s.output(c, rest[1], o, true)
+	case len(rest) == 2 && method == http.MethodPut:
+		return s.update(c, rest[1], o, body)
+	case len(rest) == 2 && method == http.MethodDelete:
+		return s.delete(c, rest[1])
+	case len(rest) == 3 && c == "lights" && rest[2] == "state" && method == http.MethodPut:
+		return s.setLightState(rest[1], body)
+	case len(rest) == 3 && c == "groups" && rest[2] == "action" && method == http.MethodPut:
+		return s.setGroupAction(rest[1], o, body)
+	case len(rest) == 3 && c == "sensors" && (rest[2] == "config" || rest[2] == "state") && method == http.MethodPut:
+		return s.updateSensor(rest[1], rest[2], o, body)
+	case len(rest) == 4 && c == "scenes" && rest[2] == "lightstates" && method == http.MethodPut:
+		return s.setSceneLightState(rest[1], rest[3], o, body)
+	}
+
+	return methodNotAvailable(method, address)
+}
+
+// get returns the resource with id in collection c. Group 0 is the special group of all lights.
+func (s *Server) get(c, id string) (object, bool) {
+	if c == "groups" && id == "0" {
+		return object{"name": "Group 0", "type": "LightGroup", "lights": s.lightIDs(), "action": object{"on": false}}, true
+	}
+	o, ok := s.resources[c][id]
+	return o, ok
+}
+
+func (s *Server) list(c string) object {
+	l := object{}
+	for id, o := range s.resources[c] {
+		l[id] = s.output(c, id, o, false)
+	}
+	return l
+}
+
+// output returns o as it is presented by the bridge
+func (s *Server) output(c, id string, o object, single bool) object {
+
+	out := object{}
+	for k, v := range o {
+		out[k] = v
+	}
+
+	switch c {
+	case "groups":
+		out["state"] = s.groupState(o)
+	case "scenes":
+		if !single {
+			delete(out, "lightstates")
+		}
+	}
+
+	return out
+}
+
+func (s *Server) create(c, user string, body object) interface{} {
+
+	if body == nil {
+		return []interface{}{apiError(huego.ErrorTypeMissingParameters, "/"+c, "invalid/missing parameters in body")}
+	}
+
+	o := object{}
+	for k, v := range body {
+		if k != "ID" {
+			o[k] = v
+		}
+	}
+
+	var err map[string]interface{}
+	switch c {
+	case "lights":
+		return methodNotAvailable(http.MethodPost, "/lights")
+	case "groups":
+		err = s.prepareGroup(o)
+	case "scenes":
+		err = s.prepareScene(user, o)
+	case "sensors":
+		err = requireKeys(c, o, "name", "type", "modelid", "manufacturername", "uniqueid", "swversion")
+		setDefault(o, "state", map[string]interface{}{"lastupdated": "none"})
+		setDefault(o, "config", map[string]interface{}{"on": true, "reachable": true})
+	case "rules":
+		err = requireKeys(c, o, "conditions", "actions")
+		setDefault(o, "status", "enabled")
+		setDefault(o, "timestriggered", 0)
+		setDefault(o, "lasttriggered", "none")
+		o["owner"] = user
+		o["created"] = time.Now().UTC().Format(timeFormat)
+	case "schedules":
+		// The bridge runs in UTC, so time and localtime are the same
+		switch {
+		case o["localtime"] != nil && o["localtime"] != "":
+			o["time"] = o["localtime"]
+		case o["time"] != nil && o["time"] != "":
+			o["localtime"] = o["time"]
+		default:
+			delete(o, "localtime")
+		}
+		err = requireKeys(c, o, "command", "localtime")
+		setDefault(o, "description", "")
+		setDefault(o, "status", "enabled")
+		o["created"] = time.Now().UTC().Format(timeFormat)
+	case "resourcelinks":
+		err = requireKeys(c, o, "name", "classid", "links")
+		o["type"] = "Link"
+		o["owner"] = user
+	}
+	if err != nil {
+		return []interface{}{err}
+	}
+
+	var id string
+	if c == "scenes" {
+		id = randomHex(8)[:15]
+	} else {
+		id = s.nextID(c)
+	}
+
+	if _, ok := o["name"]; !ok {
+		o["name"] = fmt.Sprintf("%s %s", defaultNames[c], id)
+	}
+
+	s.resources[c][id] = o
+
+	return []interface{}{object{"success": object{"id": id}}}
+}
+
+// Names given to resources created without one
+var defaultNames = map[string]string{
+	"groups":        "Group",
+	"scenes":        "Scene",
+	"sensors":       "Sensor",
+	"rules":         "Rule",
+	"schedules":     "Schedule",
+	"resourcelinks": "Resourcelink",
+}
+
+// Attributes that are ignored when updating a resource
+var readOnly = map[string][]string{
+	"lights":        {"state", "type", "modelid", "manufacturername", "uniqueid", "swversion", "swconfigid", "productname", "capabilities", "config", "swupdate"},
+	"groups":        {"state", "type", "recycle"},
+	"scenes":        {"type", "group", "owner", "locked", "lastupdated", "version", "recycle"},
+	"sensors":       {"state", "config", "type", "modelid", "manufacturername", "uniqueid", "swversion"},
+	"rules":         {"owner", "created", "creationtime", "lasttriggered", "timestriggered"},
+	"schedules":     {"created"},
+	"resourcelinks": {"type", "owner"},
+	"config":        {"whitelist", "apiversion", "swversion", "modelid", "bridgeid", "mac", "UTC", "localtime", "linkbutton", "swupdate", "swupdate2", "portalstate", "internetservices"},
+}
+
+func (s *Server) update(c, id string, o, body object) interface{} {
+
+	address := fmt.Sprintf("/%s/%s", c, id)
+
+	if c == "groups" && id == "0" {
+		return methodNotAvailable(http.MethodPut, address)
+	}
+
+	if c == "groups" {
+		if lights, ok := body["lights"]; ok {
+			if err := s.validateLights(lights); err != nil {
+				return []interface{}{err}
+			}
+		}
+	}
+
+	var res []interface{}
+	for _, k := range sortedKeys(body) {
+		if k == "ID" || contains(readOnly[c], k) {
+			continue
+		}
+		o[k] = body[k]
+		res = append(res, success(address+"/"+k, body[k]))
+	}
+
+	if c == "scenes" {
+		o["lastupdated"] = time.Now().UTC().Format(timeFormat)
+		if store, _ := body["storelightstate"].(bool); store {
+			o["lightstates"] = s.snapshot(o["lights"])
+		}
+	}
+
+	return res
+}
+
+func (s *Server) delete(c, id string) interface{} {
+
+	address := fmt.Sprintf("/%s/%s", c, id)
+
+	if c == "groups" && id == "0" {
+		return methodNotAvailable(http.MethodDelete, address)
+	}
+
+	delete(s.resources[c], id)
+
+	// Deleted lights are removed from groups and scenes
+	if c == "lights" {
+		for _, g := range s.resources["groups"] {
+			g["lights"] = remove(g["lights"], id)
+		}
+		for _, sc := range s.resources["scenes"] {
+			sc["lights"] = remove(sc["lights"], id)
+			if ls, ok := sc["lightstates"].(map[string]interface{}); ok {
+				delete(ls, id)
+			}
+		}
+	}
+
+	return []interface{}{object{"success": address + " deleted"}}
+}
+
+func (s *Server) updateSensor(id, attr string, o, body object) interface{} {
+
+	address := fmt.Sprintf("/sensors/%s/%s", id, attr)
+
+	m, _ := o[attr].(map[string]interface{})
+	if m == nil {
+		m = map[string]interface{}{}
+		o[attr] = m
+	}
+
+	// Only the state of CLIP sensors can be set, other sensors report their own state
+	typ, _ := o["type"].(string)
+	clip := strings.HasPrefix(typ, "CLIP")
+
+	var res []interface{}
+	for _, k := range sortedKeys(body) {
+		if _, ok := m[k]; !ok || k == "lastupdated" || k == "reachable" {
+			res = append(res, apiError(huego.ErrorTypeParameterNotAvailable, address+"/"+k, fmt.Sprintf("parameter, %s, not available", k)))
+			continue
+		}
+		if attr == "state" && !clip {
+			res = append(res, apiError(huego.ErrorTypeParameterNotModifiable, address+"/"+k, fmt.Sprintf("parameter, %s, is not modifiable", k)))
+			continue
+		}
+		m[k] = body[k]
+		res = append(res, success(address+"/"+k, body[k]))
+	}
+
+	if attr == "state" && clip {
+		m["lastupdated"] = time.Now().UTC().Format(timeFormat)
+	}
+
+	return res
+}
+
+// nextID returns the lowest unused numeric id in collection c
+func (s *Server) nextID(c string) string {
+	for i := 1; ; i++ {
+		id := strconv.Itoa(i)
+		if _, ok := s.resources[c][id]; !ok {
+			return id
+		}
+	}
+}
+
+func (s *Server) uniqueID(c string) string {
+	return fmt.Sprintf("00:17:88:01:00:00:00:%02x-0b", len(s.resources[c])+1)
+}
+
+func (s *Server) lightIDs() []interface{} {
+	ids := make([]string, 0, len(s.resources["lights"]))
+	for id := range s.resources["lights"] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	res := make([]interface{}, len(ids))
+	for i, id := range ids {
+		res[i] = id
+	}
+	return res
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+func success(address string, v interface{}) object {
+	return object{"success": object{address: v}}
+}
+
+func apiError(typ int, address, description string) object {
+	return object{"error": object{"type": typ, "address": address, "description": description}}
+}
+
+func methodNotAvailable(method, address string) []interface{} {
+	return []interface{}{apiError(huego.ErrorTypeMethodNotAvailable, address, fmt.Sprintf("method, %s, not available for resource, %s", method, address))}
+}
+
+func requireKeys(c string, o object, keys ...string) map[string]interface{} {
+	for _, k := range keys {
+		if _, ok := o[k]; !ok {
+			return apiError(huego.ErrorTypeMissingParameters, "/"+c, "invalid/missing parameters in body")
+		}
+	}
+	return nil
+}
+
+func setDefault(o object, k string, v interface{}) {
+	if _, ok := o[k]; !ok {
+		o[k] = v
+	}
+}
+
+func sortedKeys(o object) []string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// remove returns the list l without the string id
+func remove(l interface{}, id string) []interface{} {
+	items, _ := l.([]interface{})
+	res := make([]interface{}, 0, len(items))
+	for _, v := range items {
+		if v != id {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// toObject converts v to an object through its json representation
+func toObject(v interface{}) object {
+	var o object
+	data, _ := json.Marshal(v)
+	_ = json.Unmarshal(data, &o)
+	return o
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,334 @@
+package huegotest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/amimof/huego"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLights(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	b := s.Bridge()
+
+	lights, err := b.GetLights()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, lights, 3)
+
+	_, err = b.SetLightState(1, huego.State{On: true, Xy: []float32{0.3, 0.3}, Bri: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := b.GetLight(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, l.State.On)
+	assert.Equal(t, uint8(100), l.State.Bri)
+	assert.Equal(t, []float32{0.3, 0.3}, l.State.Xy)
+	assert.Equal(t, "xy", l.State.ColorMode)
+
+	_, err = b.SetLightState(1, huego.State{On: true, BriInc: 200})
+	assert.Nil(t, err)
+	l, _ = b.GetLight(1)
+	assert.Equal(t, uint8(254), l.State.Bri)
+
+	// Light 2 is off and can't be dimmed without turning it on
+	_, err = b.SetLightState(2, huego.State{Bri: 100})
+	assert.True(t, errors.Is(err, huego.ErrDeviceOff), "%v", err)
+
+	// Light 2 is a dimmable light without color
+	_, err = b.SetLightState(2, huego.State{On: true, Xy: []float32{0.3, 0.3}})
+	assert.True(t, errors.Is(err, huego.ErrParameterNotAvailable), "%v", err)
+
+	_, err = b.SetLightState(1, huego.State{On: true, Xy: []float32{0.3, 1.3}})
+	assert.True(t, errors.Is(err, huego.ErrInvalidValue), "%v", err)
+
+	_, err = b.UpdateLight(1, huego.Light{Name: "Desk"})
+	assert.Nil(t, err)
+	l, _ = b.GetLight(1)
+	assert.Equal(t, "Desk", l.Name)
+
+	_, err = b.GetLight(9)
+	assert.True(t, errors.Is(err, huego.ErrResourceNotAvailable), "%v", err)
+
+	id := s.AddLight(huego.Light{Name: "Hue go 1", Type: "Extended color light"})
+	assert.Equal(t, 4, id)
+
+	assert.Nil(t, b.DeleteLight(4))
+	lights, _ = b.GetLights()
+	assert.Len(t, lights, 3)
+
+	_, err = b.FindLights()
+	assert.Nil(t, err)
+	n, err := b.GetNewLights()
+	assert.Nil(t, err)
+	assert.NotEqual(t, "none", n.LastScan)
+}
+
+func TestGroups(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	b := s.Bridge()
+
+	resp, err := b.CreateGroup(huego.Group{Name: "Living room", Type: "Room", Lights: []string{"1", "2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "1", resp.Success["id"])
+
+	groups, err := b.GetGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, groups, 1)
+	assert.Equal(t, "Living room", groups[0].Name)
+	assert.Equal(t, "Other", groups[0].Class)
+	assert.True(t, groups[0].GroupState.AnyOn)
+	assert.False(t, groups[0].GroupState.AllOn)
+
+	_, err = b.SetGroupState(1, huego.State{On: true})
+	assert.Nil(t, err)
+	g, err := b.GetGroup(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, g.GroupState.AllOn)
+
+	// Parameters the lights in the group don't support are ignored
+	_, err = b.SetGroupState(1, huego.State{On: true, Ct: 400})
+	assert.Nil(t, err)
+	l, _ := b.GetLight(1)
+	assert.Equal(t, uint16(400), l.State.Ct)
+	assert.Equal(t, "ct", l.State.ColorMode)
+
+	_, err = b.SetGroupState(0, huego.State{On: false})
+	assert.Nil(t, err)
+	lights, _ := b.GetLights()
+	for _, l := range lights {
+		assert.False(t, l.State.On)
+	}
+
+	_, err = b.CreateGroup(huego.Group{Name: "Broken", Lights: []string{"7"}})
+	assert.True(t, errors.Is(err, huego.ErrInvalidValue), "%v", err)
+
+	_, err = b.CreateGroup(huego.Group{Name: "Empty"})
+	assert.True(t, errors.Is(err, huego.ErrMissingParameters), "%v", err)
+
+	assert.Nil(t, b.DeleteGroup(1))
+	groups, _ = b.GetGroups()
+	assert.Len(t, groups, 0)
+}
+
+func TestScenes(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	b := s.Bridge()
+
+	resp, err := b.CreateScene(&huego.Scene{Name: "Bright", Lights: []string{"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := resp.Success["id"].(string)
+
+	sc, err := b.GetScene(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Bright", sc.Name)
+	assert.Equal(t, uint8(254), sc.LightStates[1].Bri)
+
+	_, err = b.SetSceneLightState(id, 1, &huego.State{On: true, Bri: 10})
+	assert.Nil(t, err)
+
+	_, err = b.RecallScene(id, 0)
+	assert.Nil(t, err)
+	l, _ := b.GetLight(1)
+	assert.Equal(t, uint8(10), l.State.Bri)
+
+	_, err = b.RecallScene("unknown", 0)
+	assert.True(t, errors.Is(err, huego.ErrInvalidValue), "%v", err)
+
+	scenes, err := b.GetScenes()
+	assert.Nil(t, err)
+	assert.Len(t, scenes, 1)
+
+	assert.Nil(t, b.DeleteScene(id))
+	_, err = b.GetScene(id)
+	assert.True(t, errors.Is(err, huego.ErrResourceNotAvailable), "%v", err)
+}
+
+func TestSensors(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	b := s.Bridge()
+
+	resp, err := b.CreateSensor(&huego.Sensor{
+		Name:             "Presence",
+		Type:             "CLIPPresence",
+		ModelID:          "PHA_PRESENCE",
+		ManufacturerName: "huegotest",
+		UniqueID:         "presence-1",
+		SwVersion:        "1.0",
+		State:            map[string]interface{}{"presence": false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "2", resp.Success["id"])
+
+	_, err = b.UpdateSensorConfig(1, map[string]interface{}{"sunriseoffset": 10})
+	assert.Nil(t, err)
+	sn, err := b.GetSensor(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, float64(10), sn.Config["sunriseoffset"])
+
+	_, err = b.UpdateSensorConfig(1, map[string]interface{}{"sensitivity": 2})
+	assert.True(t, errors.Is(err, huego.ErrParameterNotAvailable), "%v", err)
+
+	_, err = b.UpdateSensor(2, &huego.Sensor{Name: "Hallway presence"})
+	assert.Nil(t, err)
+
+	sensors, err := b.GetSensors()
+	assert.Nil(t, err)
+	assert.Len(t, sensors, 2)
+
+	_, err = b.CreateSensor(&huego.Sensor{Name: "Incomplete"})
+	assert.True(t, errors.Is(err, huego.ErrMissingParameters), "%v", err)
+}
+
+func TestRulesSchedulesResourcelinks(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	b := s.Bridge()
+
+	resp, err := b.CreateRule(&huego.Rule{
+		Name:       "Daylight on",
+		Conditions: []*huego.Condition{{Address: "/sensors/1/state/daylight", Operator: "eq", Value: "true"}},
+		Actions:    []*huego.RuleAction{{Address: "/groups/0/action", Method: "PUT", Body: map[string]interface{}{"on": false}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "1", resp.Success["id"])
+
+	r, err := b.GetRule(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "enabled", r.Status)
+	assert.Equal(t, User, r.Owner)
+
+	_, err = b.CreateRule(&huego.Rule{Name: "Nothing"})
+	assert.True(t, errors.Is(err, huego.ErrMissingParameters), "%v", err)
+
+	_, err = b.CreateSchedule(&huego.Schedule{
+		Name:      "Wake up",
+		Command:   &huego.Command{Address: "/api/huegotest/groups/0/action", Method: "PUT", Body: map[string]interface{}{"on": true}},
+		LocalTime: "W124/T06:30:00",
+	})
+	assert.Nil(t, err)
+	schedules, err := b.GetSchedules()
+	assert.Nil(t, err)
+	assert.Len(t, schedules, 1)
+
+	_, err = b.CreateResourcelink(&huego.Resourcelink{Name: "Morning", ClassID: 1, Links: []string{"/rules/1", "/schedules/1"}})
+	assert.Nil(t, err)
+	rl, err := b.GetResourcelink(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Link", rl.Type)
+
+	assert.Nil(t, b.DeleteRule(1))
+	assert.Nil(t, b.DeleteSchedule(1))
+	assert.Nil(t, b.DeleteResourcelink(1))
+}
+
+func TestUsers(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	b := huego.NewWithClient(s.URL, "", s.Client())
+
+	_, err := b.CreateUser("huegotest#pairing")
+	assert.True(t, errors.Is(err, huego.ErrLinkButtonNotPressed), "%v", err)
+
+	s.PressLinkButton()
+	wl, err := b.CreateUserWithClientKey("huegotest#pairing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, wl.ClientKey, 32)
+
+	b = huego.NewWithClient(s.URL, wl.Username, s.Client())
+	users, err := b.GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, users, 2)
+
+	assert.Nil(t, b.DeleteUser(User))
+	_, err = s.Bridge().GetLights()
+	assert.True(t, errors.Is(err, huego.ErrUnauthorized), "%v", err)
+}
+
+func TestConfigAndState(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+	b := s.Bridge()
+
+	_, err := b.UpdateConfig(&huego.Config{Name: "Test bridge"})
+	assert.Nil(t, err)
+
+	c, err := b.GetConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Test bridge", c.Name)
+	assert.Equal(t, "001788FFFE73FF19", c.BridgeID)
+
+	state, err := b.GetFullState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, state.Lights, 3)
+
+	caps, err := b.GetCapabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 60, caps.Lights.Available)
+}
+
+func TestInvalidRequests(t *testing.T) {
+
+	s := NewServer()
+	defer s.Close()
+
+	res, err := s.Client().Post(s.URL+"/api/"+User+"/groups", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, _ := ioutil.ReadAll(res.Body)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `[{"error":{"address":"","description":"body contains invalid json","type":2}}]`, string(data))
+
+	_, err = s.Bridge().IdentifyLight(9)
+	assert.True(t, errors.Is(err, huego.ErrResourceNotAvailable), "%v", err)
+}
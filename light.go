@@ -4,23 +4,84 @@ import (
 	"context"
 	"image/color"
 	"math"
+	"strings"
 )
 
 // Light represents a bridge light https://developers.meethue.com/documentation/lights-api
 type Light struct {
-	State            *State `json:"state,omitempty"`
-	Type             string `json:"type,omitempty"`
-	Name             string `json:"name,omitempty"`
-	ModelID          string `json:"modelid,omitempty"`
-	ManufacturerName string `json:"manufacturername,omitempty"`
-	UniqueID         string `json:"uniqueid,omitempty"`
-	SwVersion        string `json:"swversion,omitempty"`
-	SwConfigID       string `json:"swconfigid,omitempty"`
-	ProductName      string `json:"productname,omitempty"`
-	ID               int    `json:"-"`
+	State            *State             `json:"state,omitempty"`
+	Type             string             `json:"type,omitempty"`
+	Name             string             `json:"name,omitempty"`
+	ModelID          string             `json:"modelid,omitempty"`
+	ManufacturerName string             `json:"manufacturername,omitempty"`
+	UniqueID         string             `json:"uniqueid,omitempty"`
+	SwVersion        string             `json:"swversion,omitempty"`
+	SwConfigID       string             `json:"swconfigid,omitempty"`
+	ProductName      string             `json:"productname,omitempty"`
+	Capabilities     *LightCapabilities `json:"capabilities,omitempty"`
+	Config           *LightConfig       `json:"config,omitempty"`
+	SwUpdate         *LightSwUpdate     `json:"swupdate,omitempty"`
+	ID               int                `json:"-"`
 	bridge           *Bridge
 }
 
+// LightCapabilities describes what a light is capable of, such as its color gamut and color temperature range
+type LightCapabilities struct {
+	Certified bool           `json:"certified"`
+	Control   LightControl   `json:"control"`
+	Streaming LightStreaming `json:"streaming"`
+}
+
+// LightControl holds the dimming and color capabilities of a light. ColorGamut is a list of the red, green and blue xy points.
+type LightControl struct {
+	MinDimLevel    int         `json:"mindimlevel,omitempty"`
+	MaxLumen       int         `json:"maxlumen,omitempty"`
+	ColorGamutType string      `json:"colorgamuttype,omitempty"`
+	ColorGamut     [][]float32 `json:"colorgamut,omitempty"`
+	Ct             *CtRange    `json:"ct,omitempty"`
+}
+
+// CtRange is the range of color temperatures, in mired, supported by a light
+type CtRange struct {
+	Min uint16 `json:"min"`
+	Max uint16 `json:"max"`
+}
+
+// LightStreaming defines if a light can be used with the entertainment API
+type LightStreaming struct {
+	Renderer bool `json:"renderer"`
+	Proxy    bool `json:"proxy"`
+}
+
+// LightConfig holds the configuration of a light such as its archetype and power on behavior
+type LightConfig struct {
+	Archetype string        `json:"archetype,omitempty"`
+	Function  string        `json:"function,omitempty"`
+	Direction string        `json:"direction,omitempty"`
+	Startup   *LightStartup `json:"startup,omitempty"`
+}
+
+// LightStartup defines the state of a light after power on. CustomSettings is used when Mode is custom.
+type LightStartup struct {
+	Mode           string `json:"mode,omitempty"`
+	Configured     bool   `json:"configured,omitempty"`
+	CustomSettings *State `json:"customsettings,omitempty"`
+}
+
+// LightSwUpdate contains the software update state of a light
+type LightSwUpdate struct {
+	State       string `json:"state,omitempty"`
+	LastInstall string `json:"lastinstall,omitempty"`
+}
+
+// Gamut is the triangle in the CIE xy color space that a light is able to reproduce
+type Gamut struct {
+	Type  string
+	Red   [2]float32
+	Green [2]float32
+	Blue  [2]float32
+}
+
 // State defines the attributes and properties of a light
 type State struct {
 	On             bool      `json:"on"`
@@ -202,14 +263,14 @@ func (l *Light) CtContext(ctx context.Context, new uint16) error {
 	return nil
 }
 
-// Col sets the light color as RGB (will be converted to xy)
+// Col sets the light color as RGB (will be converted to xy within the gamut of the light)
 func (l *Light) Col(new color.Color) error {
 	return l.ColContext(context.Background(), new)
 }
 
-// ColContext sets the light color as RGB (will be converted to xy)
+// ColContext sets the light color as RGB (will be converted to xy within the gamut of the light)
 func (l *Light) ColContext(ctx context.Context, new color.Color) error {
-	xy, bri := ConvertRGBToXy(new)
+	xy, bri := ConvertRGBToXyGamut(new, l.Gamut())
 
 	update := State{On: true, Xy: xy, Bri: bri}
 	_, err := l.bridge.SetLightStateContext(ctx, l.ID, update)
@@ -278,6 +339,88 @@ func (l *Light) AlertContext(ctx context.Context, new string) error {
 	return nil
 }
 
+// SupportsColor returns true if the color of the light can be set using xy or hue and saturation
+func (l *Light) SupportsColor() bool {
+	if l.Capabilities != nil {
+		return l.Capabilities.Control.ColorGamutType != "" || len(l.Capabilities.Control.ColorGamut) > 0
+	}
+	t := lightType(l.Type)
+	return t == "extendedcolorlight" || t == "colorlight"
+}
+
+// SupportsCt returns true if the color temperature of the light can be set
+func (l *Light) SupportsCt() bool {
+	if l.Capabilities != nil {
+		return l.Capabilities.Control.Ct != nil
+	}
+	t := lightType(l.Type)
+	return t == "extendedcolorlight" || t == "colortemperaturelight"
+}
+
+// CtRange returns the range of color temperatures supported by the light or nil if the light doesn't support color temperature
+func (l *Light) CtRange() *CtRange {
+	if l.Capabilities == nil || l.Capabilities.Control.Ct == nil {
+		return nil
+	}
+	r := *l.Capabilities.Control.Ct
+	return &r
+}
+
+// Gamut returns the color gamut of the light. The gamut reported in the capabilities of the light is used if
+// present, otherwise the gamut is looked up by gamut type and model id. nil is returned for lights without color.
+func (l *Light) Gamut() *Gamut {
+	if l.Capabilities != nil {
+		c := l.Capabilities.Control
+		if g := gamutFromPoints(c.ColorGamutType, c.ColorGamut); g != nil {
+			return g
+		}
+		if g := GamutByType(c.ColorGamutType); g != nil {
+			return g
+		}
+	}
+	if g, ok := modelGamuts[l.ModelID]; ok {
+		gamut := *g
+		return &gamut
+	}
+	return nil
+}
+
+// Color returns the current color of the light as RGB, based on the color mode of the light
+func (l *Light) Color() color.RGBA {
+	s := l.State
+	if s == nil || !s.On {
+		return color.RGBA{A: 0xff}
+	}
+	switch s.ColorMode {
+	case "hs":
+		return ConvertHSVToRGB(ConvertHueSatToHSV(s.Hue, s.Sat, s.Bri))
+	case "ct":
+		return ConvertXyToRGB(ConvertKelvinToXy(ConvertMiredToKelvin(s.Ct)), s.Bri, nil)
+	case "xy":
+		return ConvertXyToRGB(s.Xy, s.Bri, l.Gamut())
+	}
+	return ConvertXyToRGB(whitePoint, s.Bri, nil)
+}
+
+func gamutFromPoints(t string, points [][]float32) *Gamut {
+	if len(points) != 3 {
+		return nil
+	}
+	g := &Gamut{Type: t}
+	for i, p := range []*[2]float32{&g.Red, &g.Green, &g.Blue} {
+		if len(points[i]) != 2 {
+			return nil
+		}
+		copy(p[:], points[i])
+	}
+	return g
+}
+
+// lightType normalizes a light type so that "Extended color light" and "Extendedcolorlight" compare equal
+func lightType(t string) string {
+	return strings.ToLower(strings.Replace(t, " ", "", -1))
+}
+
 // ConvertRGBToXy converts a given RGB color to the xy color of the ligth.
 // implemented as in https://developers.meethue.com/develop/application-design-guidance/color-conversion-formulas-rgb-to-xy-and-back/
 func ConvertRGBToXy(newcolor color.Color) ([]float32, uint8) {
@@ -1,6 +1,7 @@
 package huego
 
 import (
+	"encoding/json"
 	"image/color"
 	"testing"
 
@@ -429,3 +430,73 @@ func TestConvertRGBToXY(t *testing.T) {
 
 	t.Logf("Xy of light %+v set to xy: %+v, bright: %d ", color, xy, brightness)
 }
+
+func TestLightCapabilities(t *testing.T) {
+	b := New(hostname, username)
+	l, err := b.GetLight(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotNil(t, l.Capabilities)
+	assert.True(t, l.Capabilities.Certified)
+	assert.Equal(t, 10000, l.Capabilities.Control.MinDimLevel)
+	assert.Equal(t, 120, l.Capabilities.Control.MaxLumen)
+	assert.True(t, l.Capabilities.Streaming.Renderer)
+	assert.Equal(t, "huebloom", l.Config.Archetype)
+	assert.Equal(t, "decorative", l.Config.Function)
+	assert.Equal(t, "upwards", l.Config.Direction)
+	assert.Equal(t, "noupdates", l.SwUpdate.State)
+
+	assert.True(t, l.SupportsColor())
+	assert.False(t, l.SupportsCt())
+	assert.Nil(t, l.CtRange())
+	assert.Equal(t, &Gamut{Type: "A", Red: [2]float32{0.704, 0.296}, Green: [2]float32{0.2151, 0.7106}, Blue: [2]float32{0.138, 0.08}}, l.Gamut())
+
+	lights, err := b.GetLights()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range lights {
+		assert.True(t, l.SupportsCt())
+		assert.Equal(t, &CtRange{Min: 153, Max: 500}, l.CtRange())
+	}
+}
+
+func TestLightCapabilitiesFromType(t *testing.T) {
+	tests := []struct {
+		typ   string
+		color bool
+		ct    bool
+	}{
+		{"Extended color light", true, true},
+		{"Extendedcolorlight", true, true},
+		{"Color light", true, false},
+		{"Color temperature light", false, true},
+		{"Dimmable light", false, false},
+		{"On/Off plug-in unit", false, false},
+	}
+	for _, tt := range tests {
+		l := &Light{Type: tt.typ}
+		assert.Equal(t, tt.color, l.SupportsColor(), tt.typ)
+		assert.Equal(t, tt.ct, l.SupportsCt(), tt.typ)
+		assert.Nil(t, l.Gamut())
+	}
+}
+
+func TestLightStartup(t *testing.T) {
+	var l Light
+	err := json.Unmarshal([]byte(`{"config":{"archetype":"classicbulb","function":"functional","direction":"omnidirectional","startup":{"mode":"custom","configured":true,"customsettings":{"bri":254,"ct":366}}}}`), &l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "custom", l.Config.Startup.Mode)
+	assert.True(t, l.Config.Startup.Configured)
+	assert.Equal(t, uint16(366), l.Config.Startup.CustomSettings.Ct)
+
+	data, err := json.Marshal(&Light{Name: "Desk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `{"name":"Desk"}`, string(data))
+}
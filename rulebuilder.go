@@ -0,0 +1,539 @@
+package huego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the bridge on the size of a rule
+const (
+	MaxRuleConditions = 8
+	MaxRuleActions    = 8
+)
+
+// Condition operators supported by the bridge
+const (
+	OperatorEq        = "eq"
+	OperatorGt        = "gt"
+	OperatorLt        = "lt"
+	OperatorDx        = "dx"
+	OperatorDdx       = "ddx"
+	OperatorStable    = "stable"
+	OperatorNotStable = "not stable"
+	OperatorIn        = "in"
+	OperatorNotIn     = "not in"
+)
+
+// RuleBuilder builds a rule from conditions and actions. Errors are collected and returned by Build.
+// Actions are made with GroupAction, LightAction and the other action functions rather than Group.SetState
+// and Light.SetState, which send the state at once.
+//
+//	r, err := huego.When(tap).ButtonEvent(34).
+//		And(daylight).Daylight(false).
+//		Then(huego.GroupAction(group, huego.NewStateUpdate().SetOn(true).SetBri(254))).
+//		Build()
+type RuleBuilder struct {
+	rule Rule
+	err  error
+}
+
+// ConditionBuilder adds conditions on the state of a light, group or sensor to a rule
+type ConditionBuilder struct {
+	rb      *RuleBuilder
+	address string
+}
+
+// NewRule returns a rule builder for a rule with name
+func NewRule(name string) *RuleBuilder {
+	return &RuleBuilder{rule: Rule{Name: name}}
+}
+
+// When returns a rule builder with conditions on sensor s
+func When(s *Sensor) *ConditionBuilder {
+	return NewRule("").And(s)
+}
+
+// Name sets the name of the rule
+func (rb *RuleBuilder) Name(name string) *RuleBuilder {
+	rb.rule.Name = name
+	return rb
+}
+
+// And adds conditions on sensor s
+func (rb *RuleBuilder) And(s *Sensor) *ConditionBuilder {
+	if s == nil {
+		rb.fail(errors.New("rule condition on a nil sensor"))
+		return &ConditionBuilder{rb: rb}
+	}
+	return &ConditionBuilder{rb: rb, address: "/sensors/" + strconv.Itoa(s.ID)}
+}
+
+// AndGroup adds conditions on group g
+func (rb *RuleBuilder) AndGroup(g *Group) *ConditionBuilder {
+	if g == nil {
+		rb.fail(errors.New("rule condition on a nil group"))
+		return &ConditionBuilder{rb: rb}
+	}
+	return &ConditionBuilder{rb: rb, address: "/groups/" + strconv.Itoa(g.ID)}
+}
+
+// AndLight adds conditions on light l
+func (rb *RuleBuilder) AndLight(l *Light) *ConditionBuilder {
+	if l == nil {
+		rb.fail(errors.New("rule condition on a nil light"))
+		return &ConditionBuilder{rb: rb}
+	}
+	return &ConditionBuilder{rb: rb, address: "/lights/" + strconv.Itoa(l.ID)}
+}
+
+// Between adds a condition that the local time of the bridge is between from and to, such as T20:00:00
+func (rb *RuleBuilder) Between(from, to string) *RuleBuilder {
+	return rb.Condition("/config/localtime", OperatorIn, timeInterval(from, to))
+}
+
+// NotBetween adds a condition that the local time of the bridge is not between from and to, such as T20:00:00
+func (rb *RuleBuilder) NotBetween(from, to string) *RuleBuilder {
+	return rb.Condition("/config/localtime", OperatorNotIn, timeInterval(from, to))
+}
+
+// Condition adds a condition with an address, operator and value as is
+func (rb *RuleBuilder) Condition(address, operator, value string) *RuleBuilder {
+	rb.rule.Conditions = append(rb.rule.Conditions, &Condition{Address: address, Operator: operator, Value: value})
+	return rb
+}
+
+// Then adds actions that are executed when all conditions of the rule are met
+func (rb *RuleBuilder) Then(actions ...*RuleAction) *RuleBuilder {
+	for _, a := range actions {
+		if a == nil {
+			rb.fail(errors.New("rule action is nil"))
+			continue
+		}
+		rb.rule.Actions = append(rb.rule.Actions, a)
+	}
+	return rb
+}
+
+// Build validates and returns the rule
+func (rb *RuleBuilder) Build() (*Rule, error) {
+	if rb.err != nil {
+		return nil, rb.err
+	}
+	r := rb.rule
+	err := r.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Create validates the rule against the resources of bridge b and creates it
+func (rb *RuleBuilder) Create(b *Bridge) (*Response, error) {
+	return rb.CreateContext(context.Background(), b)
+}
+
+// CreateContext validates the rule against the resources of bridge b and creates it
+func (rb *RuleBuilder) CreateContext(ctx context.Context, b *Bridge) (*Response, error) {
+	r, err := rb.Build()
+	if err != nil {
+		return nil, err
+	}
+	err = b.ValidateRuleContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return b.CreateRuleContext(ctx, r)
+}
+
+func (rb *RuleBuilder) fail(err error) {
+	if rb.err == nil {
+		rb.err = err
+	}
+}
+
+func (c *ConditionBuilder) add(attr, operator, value string) *RuleBuilder {
+	if c.address == "" {
+		return c.rb
+	}
+	return c.rb.Condition(c.address+"/state/"+attr, operator, value)
+}
+
+// Eq adds a condition that the state attribute attr equals v
+func (c *ConditionBuilder) Eq(attr string, v interface{}) *RuleBuilder {
+	return c.add(attr, OperatorEq, conditionValue(v))
+}
+
+// Gt adds a condition that the state attribute attr is greater than v
+func (c *ConditionBuilder) Gt(attr string, v int) *RuleBuilder {
+	return c.add(attr, OperatorGt, strconv.Itoa(v))
+}
+
+// Lt adds a condition that the state attribute attr is less than v
+func (c *ConditionBuilder) Lt(attr string, v int) *RuleBuilder {
+	return c.add(attr, OperatorLt, strconv.Itoa(v))
+}
+
+// Dx adds a condition that the state attribute attr has changed
+func (c *ConditionBuilder) Dx(attr string) *RuleBuilder {
+	return c.add(attr, OperatorDx, "")
+}
+
+// Ddx adds a condition that the state attribute attr has changed d ago
+func (c *ConditionBuilder) Ddx(attr string, d time.Duration) *RuleBuilder {
+	return c.add(attr, OperatorDdx, ruleDurationValue(d))
+}
+
+// Stable adds a condition that the state attribute attr hasn't changed for d
+func (c *ConditionBuilder) Stable(attr string, d time.Duration) *RuleBuilder {
+	return c.add(attr, OperatorStable, ruleDurationValue(d))
+}
+
+// NotStable adds a condition that the state attribute attr has changed within d
+func (c *ConditionBuilder) NotStable(attr string, d time.Duration) *RuleBuilder {
+	return c.add(attr, OperatorNotStable, ruleDurationValue(d))
+}
+
+// Changed adds a condition that the state of the sensor was updated
+func (c *ConditionBuilder) Changed() *RuleBuilder {
+	return c.Dx("lastupdated")
+}
+
+// ButtonEvent adds a condition that the button with event code was pressed. The lastupdated
+// attribute is included so that the rule triggers when the same button is pressed again.
+func (c *ConditionBuilder) ButtonEvent(code int) *RuleBuilder {
+	c.Eq("buttonevent", code)
+	return c.Changed()
+}
+
+// Presence adds a condition on the presence state of a motion sensor
+func (c *ConditionBuilder) Presence(v bool) *RuleBuilder {
+	return c.Eq("presence", v)
+}
+
+// Daylight adds a condition on the daylight state of a daylight or light level sensor
+func (c *ConditionBuilder) Daylight(v bool) *RuleBuilder {
+	return c.Eq("daylight", v)
+}
+
+// Dark adds a condition on the dark state of a light level sensor
+func (c *ConditionBuilder) Dark(v bool) *RuleBuilder {
+	return c.Eq("dark", v)
+}
+
+// Status adds a condition on the status state of a generic status sensor
+func (c *ConditionBuilder) Status(v int) *RuleBuilder {
+	return c.Eq("status", v)
+}
+
+// Flag adds a condition on the flag state of a generic flag sensor
+func (c *ConditionBuilder) Flag(v bool) *RuleBuilder {
+	return c.Eq("flag", v)
+}
+
+// On adds a condition on the on state of a light
+func (c *ConditionBuilder) On(v bool) *RuleBuilder {
+	return c.Eq("on", v)
+}
+
+// AnyOn adds a condition that any light in a group is on
+func (c *ConditionBuilder) AnyOn(v bool) *RuleBuilder {
+	return c.Eq("any_on", v)
+}
+
+// AllOn adds a condition that all lights in a group are on
+func (c *ConditionBuilder) AllOn(v bool) *RuleBuilder {
+	return c.Eq("all_on", v)
+}
+
+// LightAction returns a rule action that sets the state of light l to body, a StateChange or a map of attributes
+func LightAction(l *Light, body interface{}) *RuleAction {
+	if l == nil {
+		return nil
+	}
+	return &RuleAction{Address: fmt.Sprintf("/lights/%d/state", l.ID), Method: "PUT", Body: actionBody(body)}
+}
+
+// GroupAction returns a rule action that sets the state of the lights in group g to body, a StateChange or a map of attributes
+func GroupAction(g *Group, body interface{}) *RuleAction {
+	if g == nil {
+		return nil
+	}
+	return &RuleAction{Address: fmt.Sprintf("/groups/%d/action", g.ID), Method: "PUT", Body: actionBody(body)}
+}
+
+// actionBody returns the attributes that body sends as a map if it is a StateChange
+func actionBody(body interface{}) interface{} {
+	s, ok := body.(StateChange)
+	if !ok {
+		return body
+	}
+	if isNilState(s) {
+		return nil
+	}
+	var m map[string]interface{}
+	if convert(s.stateUpdate(), &m) != nil {
+		return body
+	}
+	return m
+}
+
+// RecallSceneAction returns a rule action that recalls scene s in group g
+func RecallSceneAction(g *Group, s *Scene) *RuleAction {
+	if s == nil {
+		return nil
+	}
+	return GroupAction(g, map[string]interface{}{"scene": s.ID})
+}
+
+// SensorStateAction returns a rule action that sets the state of CLIP sensor s to body
+func SensorStateAction(s *Sensor, body interface{}) *RuleAction {
+	if s == nil {
+		return nil
+	}
+	return &RuleAction{Address: fmt.Sprintf("/sensors/%d/state", s.ID), Method: "PUT", Body: body}
+}
+
+// SensorConfigAction returns a rule action that sets the config of sensor s to body
+func SensorConfigAction(s *Sensor, body interface{}) *RuleAction {
+	if s == nil {
+		return nil
+	}
+	return &RuleAction{Address: fmt.Sprintf("/sensors/%d/config", s.ID), Method: "PUT", Body: body}
+}
+
+// Validate checks the conditions and actions of the rule without contacting the bridge
+func (r *Rule) Validate() error {
+	if len(r.Conditions) == 0 {
+		return errors.New("rule has no conditions")
+	}
+	if len(r.Conditions) > MaxRuleConditions {
+		return fmt.Errorf("rule has %d conditions, the bridge allows at most %d", len(r.Conditions), MaxRuleConditions)
+	}
+	if len(r.Actions) == 0 {
+		return errors.New("rule has no actions")
+	}
+	if len(r.Actions) > MaxRuleActions {
+		return fmt.Errorf("rule has %d actions, the bridge allows at most %d", len(r.Actions), MaxRuleActions)
+	}
+	for i, c := range r.Conditions {
+		err := c.validate()
+		if err != nil {
+			return fmt.Errorf("condition %d: %v", i+1, err)
+		}
+	}
+	for i, a := range r.Actions {
+		err := a.validate()
+		if err != nil {
+			return fmt.Errorf("action %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// ValidateRule validates rule r and checks that the lights, groups, sensors and scenes it refers to exist on the bridge
+func (b *Bridge) ValidateRule(r *Rule) error {
+	return b.ValidateRuleContext(context.Background(), r)
+}
+
+// ValidateRuleContext validates rule r and checks that the lights, groups, sensors and scenes it refers to exist on the bridge
+func (b *Bridge) ValidateRuleContext(ctx context.Context, r *Rule) error {
+
+	err := r.Validate()
+	if err != nil {
+		return err
+	}
+
+	var refs [][]string
+	for _, c := range r.Conditions {
+		refs = append(refs, strings.Split(strings.TrimPrefix(c.Address, "/"), "/"))
+	}
+	for _, a := range r.Actions {
+		refs = append(refs, strings.Split(strings.TrimPrefix(a.Address, "/"), "/"))
+		if body, ok := a.Body.(map[string]interface{}); ok {
+			if scene, ok := body["scene"].(string); ok {
+				refs = append(refs, []string{"scenes", scene})
+			}
+		}
+	}
+
+	known := map[string]map[string]map[string]interface{}{}
+	for _, ref := range refs {
+		if _, ok := known[ref[0]]; ok {
+			continue
+		}
+		switch ref[0] {
+		case "lights":
+			lights, err := b.GetLightsContext(ctx)
+			if err != nil {
+				return err
+			}
+			known[ref[0]] = map[string]map[string]interface{}{}
+			for _, l := range lights {
+				known[ref[0]][strconv.Itoa(l.ID)] = nil
+			}
+		case "groups":
+			groups, err := b.GetGroupsContext(ctx)
+			if err != nil {
+				return err
+			}
+			known[ref[0]] = map[string]map[string]interface{}{"0": nil}
+			for _, g := range groups {
+				known[ref[0]][strconv.Itoa(g.ID)] = nil
+			}
+		case "sensors":
+			sensors, err := b.GetSensorsContext(ctx)
+			if err != nil {
+				return err
+			}
+			known[ref[0]] = map[string]map[string]interface{}{}
+			for _, s := range sensors {
+				known[ref[0]][strconv.Itoa(s.ID)] = map[string]interface{}{"state": s.State, "config": s.Config}
+			}
+		case "scenes":
+			scenes, err := b.GetScenesContext(ctx)
+			if err != nil {
+				return err
+			}
+			known[ref[0]] = map[string]map[string]interface{}{}
+			for _, s := range scenes {
+				known[ref[0]][s.ID] = nil
+			}
+		}
+	}
+
+	for _, ref := range refs {
+		resources, ok := known[ref[0]]
+		if !ok {
+			continue
+		}
+		attrs, ok := resources[ref[1]]
+		if !ok {
+			return fmt.Errorf("rule refers to /%s/%s which doesn't exist on the bridge", ref[0], ref[1])
+		}
+		// Sensors only have the state and config attributes of their type
+		if ref[0] == "sensors" && len(ref) == 4 {
+			if m, ok := attrs[ref[2]].(map[string]interface{}); ok && m != nil {
+				if _, ok := m[ref[3]]; !ok {
+					return fmt.Errorf("sensor %s has no %s attribute %q", ref[1], ref[2], ref[3])
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Condition) validate() error {
+
+	if c == nil {
+		return errors.New("condition is nil")
+	}
+
+	p := strings.Split(strings.TrimPrefix(c.Address, "/"), "/")
+	switch {
+	case !strings.HasPrefix(c.Address, "/"):
+		return fmt.Errorf("address %q doesn't start with /", c.Address)
+	case p[0] == "config":
+		if c.Address != "/config/localtime" {
+			return fmt.Errorf("address %q is not a valid condition address", c.Address)
+		}
+	case p[0] == "sensors" || p[0] == "lights" || p[0] == "groups":
+		if len(p) != 4 || p[1] == "" || p[3] == "" || (p[2] != "state" && !(p[0] == "sensors" && p[2] == "config")) {
+			return fmt.Errorf("address %q is not a valid condition address", c.Address)
+		}
+	default:
+		return fmt.Errorf("address %q is not a valid condition address", c.Address)
+	}
+
+	switch c.Operator {
+	case OperatorEq:
+		if c.Value == "" {
+			return fmt.Errorf("operator %s requires a value", c.Operator)
+		}
+	case OperatorGt, OperatorLt:
+		if _, err := strconv.Atoi(c.Value); err != nil {
+			return fmt.Errorf("operator %s requires an integer value, got %q", c.Operator, c.Value)
+		}
+	case OperatorDx:
+		if c.Value != "" {
+			return fmt.Errorf("operator %s doesn't take a value", c.Operator)
+		}
+	case OperatorDdx, OperatorStable, OperatorNotStable:
+		if tp, err := ParseTimePattern(c.Value); err != nil || tp.Type != Timer || tp.Repeat != 0 || tp.Random != 0 {
+			return fmt.Errorf("operator %s requires a duration such as PT00:00:10, got %q", c.Operator, c.Value)
+		}
+	case OperatorIn, OperatorNotIn:
+		if c.Address != "/config/localtime" {
+			return fmt.Errorf("operator %s can only be used with /config/localtime", c.Operator)
+		}
+		if tp, err := ParseTimePattern(c.Value); err != nil || tp.Type != TimeInterval {
+			return fmt.Errorf("operator %s requires a time interval such as T20:00:00/T23:00:00, got %q", c.Operator, c.Value)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q", c.Operator)
+	}
+
+	return nil
+}
+
+func (a *RuleAction) validate() error {
+
+	if a == nil {
+		return errors.New("action is nil")
+	}
+
+	p := strings.Split(strings.TrimPrefix(a.Address, "/"), "/")
+	if !strings.HasPrefix(a.Address, "/") || len(p) < 2 || p[1] == "" {
+		return fmt.Errorf("address %q is not a valid action address", a.Address)
+	}
+	switch p[0] {
+	case "lights", "groups", "sensors", "scenes", "schedules", "rules", "resourcelinks":
+	default:
+		return fmt.Errorf("address %q is not a valid action address", a.Address)
+	}
+
+	switch a.Method {
+	case "PUT", "POST":
+		if a.Body == nil {
+			return fmt.Errorf("method %s requires a body", a.Method)
+		}
+	case "DELETE":
+	default:
+		return fmt.Errorf("unknown method %q", a.Method)
+	}
+
+	return nil
+}
+
+// conditionValue formats v as a condition value
+func conditionValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case bool:
+		return strconv.FormatBool(t)
+	case int:
+		return strconv.Itoa(t)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ruleDurationValue formats d as PThh:mm:ss
+func ruleDurationValue(d time.Duration) string {
+	return NewTimer(d, 0).String()
+}
+
+func timeInterval(from, to string) string {
+	if !strings.HasPrefix(from, "T") {
+		from = "T" + from
+	}
+	if !strings.HasPrefix(to, "T") {
+		to = "T" + to
+	}
+	return from + "/" + to
+}
@@ -0,0 +1,159 @@
+package huego
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleBuilder(t *testing.T) {
+
+	tap := &Sensor{ID: 2}
+	daylight := &Sensor{ID: 1}
+	group := &Group{ID: 1}
+
+	r, err := When(tap).ButtonEvent(34).
+		And(daylight).Daylight(false).
+		Between("20:00:00", "T23:00:00").
+		Name("Evening tap").
+		Then(GroupAction(group, map[string]interface{}{"on": true}), RecallSceneAction(group, &Scene{ID: "4e1c6b20e-on-0"})).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Evening tap", r.Name)
+	assert.Equal(t, []*Condition{
+		{Address: "/sensors/2/state/buttonevent", Operator: "eq", Value: "34"},
+		{Address: "/sensors/2/state/lastupdated", Operator: "dx"},
+		{Address: "/sensors/1/state/daylight", Operator: "eq", Value: "false"},
+		{Address: "/config/localtime", Operator: "in", Value: "T20:00:00/T23:00:00"},
+	}, r.Conditions)
+	assert.Equal(t, []*RuleAction{
+		{Address: "/groups/1/action", Method: "PUT", Body: map[string]interface{}{"on": true}},
+		{Address: "/groups/1/action", Method: "PUT", Body: map[string]interface{}{"scene": "4e1c6b20e-on-0"}},
+	}, r.Actions)
+
+	r, err = NewRule("Stable").
+		AndGroup(group).AnyOn(true).
+		AndLight(&Light{ID: 1}).On(true).
+		And(tap).Stable("buttonevent", 90*time.Minute).
+		Then(LightAction(&Light{ID: 1}, map[string]interface{}{"on": false})).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/groups/1/state/any_on", r.Conditions[0].Address)
+	assert.Equal(t, "/lights/1/state/on", r.Conditions[1].Address)
+	assert.Equal(t, "PT01:30:00", r.Conditions[2].Value)
+
+	// Errors from the builder are returned by Build
+	_, err = When(nil).Presence(true).Then(GroupAction(group, map[string]interface{}{"on": true})).Build()
+	assert.NotNil(t, err)
+
+	_, err = When(tap).ButtonEvent(34).Then(GroupAction(nil, map[string]interface{}{"on": true})).Build()
+	assert.NotNil(t, err)
+
+	// State changes are sent as the attributes they set
+	r, err = When(tap).ButtonEvent(16).
+		Then(GroupAction(group, NewStateUpdate().SetOn(true).SetScene("abc")), LightAction(&Light{ID: 1}, State{Bri: 100, Reachable: true})).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{"on": true, "scene": "abc"}, r.Actions[0].Body)
+	assert.Equal(t, map[string]interface{}{"on": false, "bri": float64(100)}, r.Actions[1].Body)
+
+	var s *StateUpdate
+	_, err = When(tap).ButtonEvent(16).Then(GroupAction(group, s)).Build()
+	assert.NotNil(t, err)
+}
+
+func TestRuleValidate(t *testing.T) {
+
+	action := &RuleAction{Address: "/groups/0/action", Method: "PUT", Body: map[string]interface{}{"on": true}}
+
+	tests := []struct {
+		condition *Condition
+		valid     bool
+	}{
+		{&Condition{Address: "/sensors/1/state/presence", Operator: "eq", Value: "true"}, true},
+		{&Condition{Address: "/sensors/1/config/on", Operator: "eq", Value: "true"}, true},
+		{&Condition{Address: "/sensors/1/state/temperature", Operator: "gt", Value: "2000"}, true},
+		{&Condition{Address: "/sensors/1/state/temperature", Operator: "lt", Value: "warm"}, false},
+		{&Condition{Address: "/sensors/1/state/lastupdated", Operator: "dx", Value: "1"}, false},
+		{&Condition{Address: "/sensors/1/state/presence", Operator: "ddx", Value: "PT00:05:00"}, true},
+		{&Condition{Address: "/sensors/1/state/presence", Operator: "not stable", Value: "5m"}, false},
+		{&Condition{Address: "/config/localtime", Operator: "not in", Value: "W124/T08:00:00/T17:00:00"}, true},
+		{&Condition{Address: "/sensors/1/state/presence", Operator: "in", Value: "T08:00:00/T17:00:00"}, false},
+		{&Condition{Address: "/sensors/1/state/presence", Operator: "is", Value: "true"}, false},
+		{&Condition{Address: "/sensors/1/state/presence", Operator: "eq"}, false},
+		{&Condition{Address: "/lights/1/config/on", Operator: "eq", Value: "true"}, false},
+		{&Condition{Address: "sensors/1/state/presence", Operator: "eq", Value: "true"}, false},
+		{&Condition{Address: "/config/name", Operator: "eq", Value: "bridge"}, false},
+	}
+
+	for _, tt := range tests {
+		r := &Rule{Conditions: []*Condition{tt.condition}, Actions: []*RuleAction{action}}
+		err := r.Validate()
+		assert.Equal(t, tt.valid, err == nil, "%+v: %v", tt.condition, err)
+	}
+
+	r := &Rule{Conditions: []*Condition{tests[0].condition}, Actions: []*RuleAction{{Address: "/api/user/groups/0/action", Method: "PUT", Body: "{}"}}}
+	assert.NotNil(t, r.Validate())
+	r.Actions = []*RuleAction{{Address: "/groups/0/action", Method: "GET"}}
+	assert.NotNil(t, r.Validate())
+	r.Actions = []*RuleAction{{Address: "/groups/0/action", Method: "PUT"}}
+	assert.NotNil(t, r.Validate())
+	r.Actions = []*RuleAction{{Address: "/schedules/1", Method: "DELETE"}}
+	assert.Nil(t, r.Validate())
+
+	r.Actions = nil
+	assert.NotNil(t, r.Validate())
+
+	rb := NewRule("Too many")
+	for i := 0; i <= MaxRuleConditions; i++ {
+		rb.And(&Sensor{ID: i + 1}).Presence(true)
+	}
+	_, err := rb.Then(action).Build()
+	assert.True(t, strings.Contains(err.Error(), "at most 8"))
+}
+
+func TestValidateRule(t *testing.T) {
+	b := New(hostname, username)
+
+	rb := When(&Sensor{ID: 1}).Daylight(true).Then(GroupAction(&Group{ID: 0}, map[string]interface{}{"on": false}))
+	r, err := rb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, b.ValidateRule(r))
+
+	resp, err := rb.Create(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "3", resp.Success["id"])
+
+	// Sensor 1 is a daylight sensor without presence
+	r, _ = When(&Sensor{ID: 1}).Presence(true).Then(LightAction(&Light{ID: 1}, map[string]interface{}{"on": false})).Build()
+	assert.NotNil(t, b.ValidateRule(r))
+
+	r, _ = When(&Sensor{ID: 9}).Changed().Then(LightAction(&Light{ID: 1}, map[string]interface{}{"on": false})).Build()
+	assert.NotNil(t, b.ValidateRule(r))
+
+	r, _ = When(&Sensor{ID: 2}).Changed().Then(LightAction(&Light{ID: 9}, map[string]interface{}{"on": false})).Build()
+	assert.NotNil(t, b.ValidateRule(r))
+
+	r, _ = When(&Sensor{ID: 2}).Changed().Then(RecallSceneAction(&Group{ID: 1}, &Scene{ID: "unknown"})).Build()
+	assert.NotNil(t, b.ValidateRule(r))
+
+	_, err = When(&Sensor{ID: 2}).Changed().Create(b)
+	assert.NotNil(t, err)
+
+	b.Host = badHostname
+	r, _ = When(&Sensor{ID: 2}).Changed().Then(LightAction(&Light{ID: 1}, map[string]interface{}{"on": false})).Build()
+	assert.NotNil(t, b.ValidateRule(r))
+}
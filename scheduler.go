@@ -0,0 +1,374 @@
+package huego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheduler limits the rate of requests to the bridge, retries failed requests and coalesces state updates
+// to the same light. The bridge handles roughly 10 light commands and 1 group command per second, bursts above
+// that are dropped. Use NewScheduler for recommended defaults and Bridge.SetScheduler to use it. A scheduler may be
+// shared by several bridges, the limits then apply to their requests together.
+// Zero values mean no limit, no retries and no coalescing. Fields must not be changed once the scheduler is in use.
+type Scheduler struct {
+	// LightRate is the number of light state updates per second
+	LightRate float64
+	// GroupRate is the number of group actions per second
+	GroupRate float64
+	// Rate is the number of other requests per second
+	Rate float64
+	// Burst is the number of requests of each kind that may be sent at once. Defaults to 1
+	Burst int
+	// Retries is the number of times a request is retried on transport errors and 5xx responses.
+	// POST requests are only retried when the bridge responds with 503 since they aren't idempotent.
+	Retries int
+	// Backoff is the delay before the first retry, doubled for each retry and randomized by up to 50%
+	Backoff time.Duration
+	// MaxBackoff is the maximum delay between retries
+	MaxBackoff time.Duration
+	// Coalesce merges state updates to a light that are waiting to be sent into one request.
+	// All requests that are merged return the response of the merged request, which is sent as long as any of them is waiting.
+	Coalesce bool
+	// Transport is used to send requests. Defaults to the transport of the http client of each bridge,
+	// or http.DefaultTransport when the scheduler is used as an http.RoundTripper on its own
+	Transport http.RoundTripper
+
+	once    sync.Once
+	mu      sync.Mutex
+	buckets map[requestKind]*bucket
+	pending map[string]*pendingUpdate
+}
+
+type requestKind int
+
+const (
+	kindOther requestKind = iota
+	kindLight
+	kindGroup
+)
+
+// NewScheduler returns a scheduler with the rate limits documented for the bridge, 3 retries and coalescing of light state updates
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		LightRate:  10,
+		GroupRate:  1,
+		Burst:      1,
+		Retries:    3,
+		Backoff:    100 * time.Millisecond,
+		MaxBackoff: 2 * time.Second,
+		Coalesce:   true,
+	}
+}
+
+// SetScheduler makes the bridge send all requests through s, replacing the current scheduler. A nil s removes it.
+// The http client of the bridge is copied so that clients shared with other code are left as is.
+func (b *Bridge) SetScheduler(s *Scheduler) {
+	var l layer
+	if s != nil {
+		l = &schedulerTransport{scheduler: s}
+	}
+	b.replaceLayer(func(l layer) bool {
+		_, ok := l.(*schedulerTransport)
+		return ok
+	}, l)
+}
+
+// schedulerTransport sends the requests of one bridge through a scheduler
+type schedulerTransport struct {
+	scheduler *Scheduler
+	next      http.RoundTripper
+}
+
+func (t *schedulerTransport) unwrap() http.RoundTripper {
+	return t.next
+}
+
+func (t *schedulerTransport) wrap(next http.RoundTripper) layer {
+	return &schedulerTransport{scheduler: t.scheduler, next: next}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *schedulerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if t.scheduler.Transport != nil {
+		next = t.scheduler.Transport
+	}
+	return t.scheduler.roundTrip(req, next)
+}
+
+// RoundTrip sends req once a token for its kind is available. Implements http.RoundTripper
+func (s *Scheduler) RoundTrip(req *http.Request) (*http.Response, error) {
+	return s.roundTrip(req, s.Transport)
+}
+
+// roundTrip sends req through next, or http.DefaultTransport if next is nil, once a token for its kind is available
+func (s *Scheduler) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+
+	s.once.Do(s.init)
+
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	var data []byte
+	if req.Body != nil {
+		var err error
+		data, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	kind := kindOf(req)
+	if s.Coalesce && kind == kindLight && req.Method == http.MethodPut {
+		var body map[string]interface{}
+		if json.Unmarshal(data, &body) == nil {
+			return s.coalesce(req, body, next)
+		}
+	}
+
+	return s.send(req, kind, data, next)
+}
+
+func (s *Scheduler) init() {
+	s.buckets = map[requestKind]*bucket{
+		kindOther: newBucket(s.Rate, s.Burst),
+		kindLight: newBucket(s.LightRate, s.Burst),
+		kindGroup: newBucket(s.GroupRate, s.Burst),
+	}
+	s.pending = map[string]*pendingUpdate{}
+}
+
+// pendingUpdate is a light state update waiting to be sent, that later updates are merged into.
+// It is sent with a context of its own that is only cancelled once every request merged into it has given up.
+type pendingUpdate struct {
+	body    map[string]interface{}
+	waiters int
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	res     *http.Response
+	data    []byte
+	err     error
+}
+
+func (s *Scheduler) coalesce(req *http.Request, body map[string]interface{}, next http.RoundTripper) (*http.Response, error) {
+
+	key := req.URL.String()
+
+	s.mu.Lock()
+	p, ok := s.pending[key]
+	if ok {
+		for k, v := range body {
+			p.body[k] = v
+		}
+	} else {
+		ctx, cancel := context.WithCancel(context.Background())
+		p = &pendingUpdate{body: body, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+		s.pending[key] = p
+		go s.flush(key, p, req, next)
+	}
+	p.waiters++
+	s.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return p.response(req)
+	case <-req.Context().Done():
+		s.mu.Lock()
+		p.waiters--
+		if p.waiters == 0 {
+			if s.pending[key] == p {
+				delete(s.pending, key)
+			}
+			p.cancel()
+		}
+		s.mu.Unlock()
+		return nil, req.Context().Err()
+	}
+}
+
+// flush sends p once a token is available, using req for everything but the body and context
+func (s *Scheduler) flush(key string, p *pendingUpdate, req *http.Request, next http.RoundTripper) {
+
+	defer close(p.done)
+	defer p.cancel()
+
+	err := s.buckets[kindLight].wait(p.ctx)
+
+	s.mu.Lock()
+	if s.pending[key] == p {
+		delete(s.pending, key)
+	}
+	data, _ := json.Marshal(p.body)
+	s.mu.Unlock()
+
+	if err != nil {
+		p.err = err
+		return
+	}
+
+	res, err := s.retry(req.WithContext(p.ctx), data, next)
+	if err != nil {
+		p.err = err
+		return
+	}
+	defer res.Body.Close()
+
+	p.res = res
+	p.data, p.err = ioutil.ReadAll(res.Body)
+}
+
+// response returns a copy of the response of the merged request
+func (p *pendingUpdate) response(req *http.Request) (*http.Response, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	res := *p.res
+	res.Request = req
+	res.Body = ioutil.NopCloser(bytes.NewReader(p.data))
+	return &res, nil
+}
+
+func (s *Scheduler) send(req *http.Request, kind requestKind, data []byte, next http.RoundTripper) (*http.Response, error) {
+	err := s.buckets[kind].wait(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	return s.retry(req, data, next)
+}
+
+// retry sends req with data through next until it succeeds or the retries are exhausted. A token must be taken before the first attempt.
+func (s *Scheduler) retry(req *http.Request, data []byte, next http.RoundTripper) (*http.Response, error) {
+
+	kind := kindOf(req)
+
+	for attempt := 0; ; attempt++ {
+
+		if attempt > 0 {
+			err := sleep(req.Context(), s.backoff(attempt))
+			if err != nil {
+				return nil, err
+			}
+			err = s.buckets[kind].wait(req.Context())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		r := req.Clone(req.Context())
+		if req.Body != nil {
+			r.Body = ioutil.NopCloser(bytes.NewReader(data))
+			r.ContentLength = int64(len(data))
+		}
+
+		res, err := next.RoundTrip(r)
+
+		retryable := false
+		switch {
+		case err != nil:
+			retryable = req.Method != http.MethodPost
+		case res.StatusCode == http.StatusServiceUnavailable:
+			retryable = true
+		case res.StatusCode >= 500:
+			retryable = req.Method != http.MethodPost
+		}
+
+		if !retryable || attempt >= s.Retries || req.Context().Err() != nil {
+			return res, err
+		}
+
+		if res != nil {
+			ioutil.ReadAll(res.Body)
+			res.Body.Close()
+		}
+	}
+}
+
+// backoff returns the delay before retry attempt, between 50% and 100% of the exponential backoff
+func (s *Scheduler) backoff(attempt int) time.Duration {
+	d := float64(s.Backoff) * math.Pow(2, float64(attempt-1))
+	if s.MaxBackoff > 0 && d > float64(s.MaxBackoff) {
+		d = float64(s.MaxBackoff)
+	}
+	return time.Duration(d/2 + rand.Float64()*d/2)
+}
+
+// kindOf returns the kind of req based on its path, /api/<user>/lights/<id>/state or /api/<user>/groups/<id>/action
+func kindOf(req *http.Request) requestKind {
+	p := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if req.Method != http.MethodPut || len(p) < 4 || p[0] != "api" {
+		return kindOther
+	}
+	p = p[len(p)-3:]
+	switch {
+	case p[0] == "lights" && p[2] == "state":
+		return kindLight
+	case p[0] == "groups" && p[2] == "action":
+		return kindGroup
+	}
+	return kindOther
+}
+
+// bucket is a token bucket that refills with rate tokens per second up to burst tokens
+type bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a token is available and takes it. A bucket without rate never blocks.
+func (b *bucket) wait(ctx context.Context) error {
+
+	if b.rate <= 0 {
+		return ctx.Err()
+	}
+
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		d := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		err := sleep(ctx, d)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,218 @@
+package huego
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerRateLimit(t *testing.T) {
+
+	s := newRecorder(nil)
+	defer s.Close()
+
+	b := s.bridge()
+	b.SetScheduler(&Scheduler{LightRate: 20, GroupRate: 10})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := b.SetLightState(1, State{On: true})
+		assert.Nil(t, err)
+	}
+	// The first request is sent at once and the other four are spaced by 50ms
+	assert.True(t, time.Since(start) >= 190*time.Millisecond, "%v", time.Since(start))
+
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := b.SetGroupState(1, State{On: true})
+		assert.Nil(t, err)
+	}
+	assert.True(t, time.Since(start) >= 190*time.Millisecond, "%v", time.Since(start))
+
+	// Other requests are not limited
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		b.GetConfig()
+	}
+	assert.True(t, time.Since(start) < 150*time.Millisecond, "%v", time.Since(start))
+
+	// Waiting for a token is cancelled with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	b.SetGroupState(1, State{On: true})
+	_, err := b.SetGroupStateContext(ctx, 1, State{On: true})
+	assert.NotNil(t, err)
+}
+
+func TestSchedulerRetry(t *testing.T) {
+
+	// The first failures requests fail with status
+	failures, status := 2, http.StatusServiceUnavailable
+	s := newRecorder(func(w http.ResponseWriter, r *http.Request, n int) {
+		if n <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`[{"success":{"/lights/1/state/on":true}}]`))
+	})
+	defer s.Close()
+
+	b := s.bridge()
+	b.SetScheduler(&Scheduler{Retries: 3, Backoff: time.Millisecond})
+
+	_, err := b.SetLightState(1, State{On: true, Bri: 100})
+	assert.Nil(t, err)
+	assert.Len(t, s.bodies(), 3)
+	assert.Equal(t, float64(100), s.bodies()[2]["bri"])
+
+	// Requests are not retried more than Retries times
+	s.reset()
+	failures = 5
+	_, err = b.SetLightState(1, State{On: true})
+	assert.NotNil(t, err)
+	assert.Len(t, s.bodies(), 4)
+
+	// POST is only retried on 503
+	s.reset()
+	status = http.StatusInternalServerError
+	_, err = b.CreateGroup(Group{Name: "Room"})
+	assert.NotNil(t, err)
+	assert.Len(t, s.bodies(), 1)
+
+	sch := &Scheduler{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	for i := 0; i < 10; i++ {
+		assert.True(t, sch.backoff(1) >= 50*time.Millisecond && sch.backoff(1) <= 100*time.Millisecond)
+		assert.True(t, sch.backoff(5) >= 150*time.Millisecond && sch.backoff(5) <= 300*time.Millisecond)
+	}
+}
+
+func TestSchedulerCoalesce(t *testing.T) {
+
+	s := newRecorder(nil)
+	defer s.Close()
+
+	b := s.bridge()
+	b.SetScheduler(&Scheduler{LightRate: 5, Coalesce: true})
+
+	// Takes the only token
+	_, err := b.SetLightState(1, State{On: true})
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i, st := range []State{{On: true, Bri: 10}, {On: true, Bri: 20}, {On: true, Hue: 300}} {
+		wg.Add(1)
+		go func(i int, st State) {
+			defer wg.Done()
+			_, errs[i] = b.SetLightState(1, st)
+		}(i, st)
+		time.Sleep(20 * time.Millisecond)
+	}
+	wg.Wait()
+
+	assert.Equal(t, []error{nil, nil, nil}, errs)
+	assert.Len(t, s.bodies(), 2)
+	assert.Equal(t, float64(20), s.bodies()[1]["bri"])
+	assert.Equal(t, float64(300), s.bodies()[1]["hue"])
+}
+
+func TestSchedulerCoalesceCancel(t *testing.T) {
+
+	s := newRecorder(nil)
+	defer s.Close()
+
+	b := s.bridge()
+	b.SetScheduler(&Scheduler{LightRate: 5, Coalesce: true})
+
+	// Takes the only token
+	_, err := b.SetLightState(1, State{On: true})
+	assert.Nil(t, err)
+
+	// The first request gives up while waiting, the update merged into it is still sent
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := b.SetLightStateContext(ctx, 1, NewStateUpdate().SetBri(10))
+		first <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := b.SetLightState(1, NewStateUpdate().SetHue(300))
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	assert.Equal(t, context.Canceled, errors.Unwrap(<-first))
+	assert.Nil(t, <-second)
+	assert.Len(t, s.bodies(), 2)
+	assert.Equal(t, map[string]interface{}{"bri": float64(10), "hue": float64(300)}, s.bodies()[1])
+
+	// Nothing is sent once every merged request has given up
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = b.SetLightStateContext(ctx, 1, NewStateUpdate().SetBri(30))
+	assert.NotNil(t, err)
+	time.Sleep(250 * time.Millisecond)
+	assert.Len(t, s.bodies(), 2)
+}
+
+func TestSetScheduler(t *testing.T) {
+
+	client := &http.Client{}
+	b := NewWithClient(hostname, username, client)
+
+	s := NewScheduler()
+	b.SetScheduler(s)
+	st, ok := b.client.Transport.(*schedulerTransport)
+	assert.True(t, ok)
+	assert.Same(t, s, st.scheduler)
+	assert.Nil(t, st.next)
+	assert.Nil(t, client.Transport)
+
+	// The scheduler is replaced below the cache rather than stacked
+	c := NewCache(b)
+	s2 := NewScheduler()
+	b.SetScheduler(s2)
+	ct, ok := b.client.Transport.(*cacheTransport)
+	assert.True(t, ok)
+	assert.Same(t, c, ct.cache)
+	st, ok = ct.next.(*schedulerTransport)
+	assert.True(t, ok)
+	assert.Same(t, s2, st.scheduler)
+	assert.Nil(t, st.next)
+
+	b.SetScheduler(nil)
+	ct, ok = b.client.Transport.(*cacheTransport)
+	assert.True(t, ok)
+	assert.Nil(t, ct.next)
+
+	// A scheduler shared by two bridges sends through the transport of each
+	t1, t2 := &countingTransport{}, &countingTransport{}
+	b1 := NewWithClient(hostname, username, &http.Client{Transport: t1})
+	b2 := NewWithClient(hostname, username, &http.Client{Transport: t2})
+	shared := &Scheduler{Rate: 100}
+	b1.SetScheduler(shared)
+	b2.SetScheduler(shared)
+	_, _ = b1.GetLights()
+	_, _ = b2.GetLights()
+	assert.Equal(t, 1, t1.count)
+	assert.Equal(t, 1, t2.count)
+}
+
+// countingTransport counts requests and fails them
+type countingTransport struct {
+	count int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.count++
+	return nil, errors.New("no bridge")
+}
@@ -1,5 +1,13 @@
 package huego
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+)
+
 // Sensor represents a bridge sensor https://developers.meethue.com/documentation/sensors-api
 type Sensor struct {
 	State            map[string]interface{} `json:"state,omitempty"`
@@ -11,6 +19,7 @@ type Sensor struct {
 	UniqueID         string                 `json:"uniqueid,omitempty"`
 	SwVersion        string                 `json:"swversion,omitempty"`
 	ID               int                    `json:",omitempty"`
+	bridge           *Bridge
 }
 
 // NewSensor defines a list of sensors discovered the last time the bridge performed a sensor discovery.
@@ -19,3 +28,318 @@ type NewSensor struct {
 	Sensors  []*Sensor
 	LastScan string `json:"lastscan"`
 }
+
+// Rename sets the name property of the sensor
+func (s *Sensor) Rename(new string) error {
+	return s.RenameContext(context.Background(), new)
+}
+
+// RenameContext sets the name property of the sensor
+func (s *Sensor) RenameContext(ctx context.Context, new string) error {
+	update := Sensor{Name: new}
+	_, err := s.bridge.UpdateSensorContext(ctx, s.ID, &update)
+	if err != nil {
+		return err
+	}
+	s.Name = new
+	return nil
+}
+
+// SetConfig updates the config of the sensor with the attributes in c. The allowed attributes depend on the sensor type
+func (s *Sensor) SetConfig(c interface{}) error {
+	return s.SetConfigContext(context.Background(), c)
+}
+
+// SetConfigContext updates the config of the sensor with the attributes in c. The allowed attributes depend on the sensor type
+func (s *Sensor) SetConfigContext(ctx context.Context, c interface{}) error {
+	_, err := s.bridge.UpdateSensorConfigContext(ctx, s.ID, c)
+	if err != nil {
+		return err
+	}
+	s.Config, err = mergeMap(s.Config, c)
+	return err
+}
+
+// SetState updates the state of the sensor with the attributes in st. Only the state of CLIP sensors can be set
+func (s *Sensor) SetState(st interface{}) error {
+	return s.SetStateContext(context.Background(), st)
+}
+
+// SetStateContext updates the state of the sensor with the attributes in st. Only the state of CLIP sensors can be set
+func (s *Sensor) SetStateContext(ctx context.Context, st interface{}) error {
+	if !strings.HasPrefix(s.Type, "CLIP") {
+		return errors.New("only the state of CLIP sensors can be set")
+	}
+	_, err := s.bridge.UpdateSensorStateContext(ctx, s.ID, st)
+	if err != nil {
+		return err
+	}
+	s.State, err = mergeMap(s.State, st)
+	return err
+}
+
+// Enable turns the sensor on
+func (s *Sensor) Enable() error {
+	return s.EnableContext(context.Background())
+}
+
+// EnableContext turns the sensor on
+func (s *Sensor) EnableContext(ctx context.Context) error {
+	return s.SetConfigContext(ctx, map[string]interface{}{"on": true})
+}
+
+// Disable turns the sensor off. A disabled sensor doesn't update its state and doesn't trigger rules
+func (s *Sensor) Disable() error {
+	return s.DisableContext(context.Background())
+}
+
+// DisableContext turns the sensor off. A disabled sensor doesn't update its state and doesn't trigger rules
+func (s *Sensor) DisableContext(ctx context.Context) error {
+	return s.SetConfigContext(ctx, map[string]interface{}{"on": false})
+}
+
+// IsOn returns true if the sensor is enabled
+func (s *Sensor) IsOn() bool {
+	on, _ := s.Config["on"].(bool)
+	return on
+}
+
+// SetSensitivity sets the sensitivity of a motion sensor, between 0 and the sensitivitymax config attribute of the sensor
+func (s *Sensor) SetSensitivity(new int) error {
+	return s.SetSensitivityContext(context.Background(), new)
+}
+
+// SetSensitivityContext sets the sensitivity of a motion sensor, between 0 and the sensitivitymax config attribute of the sensor
+func (s *Sensor) SetSensitivityContext(ctx context.Context, new int) error {
+	return s.SetConfigContext(ctx, map[string]interface{}{"sensitivity": new})
+}
+
+// Refresh reads the current state and config of the sensor from the bridge
+func (s *Sensor) Refresh() error {
+	return s.RefreshContext(context.Background())
+}
+
+// RefreshContext reads the current state and config of the sensor from the bridge
+func (s *Sensor) RefreshContext(ctx context.Context) error {
+	r, err := s.bridge.GetSensorContext(ctx, s.ID)
+	if err != nil {
+		return err
+	}
+	*s = *r
+	return nil
+}
+
+// mergeMap returns m with the attributes of v, v is converted to a map through its json representation
+func mergeMap(m map[string]interface{}, v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return m, err
+	}
+	var attrs map[string]interface{}
+	err = json.Unmarshal(data, &attrs)
+	if err != nil {
+		return m, err
+	}
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+	for k, a := range attrs {
+		m[k] = a
+	}
+	return m, nil
+}
+
+// Sensor types known to the bridge, https://developers.meethue.com/develop/hue-api/supported-devices/
+const (
+	SensorTypeDaylight          = "Daylight"
+	SensorTypeZLLPresence       = "ZLLPresence"
+	SensorTypeZLLTemperature    = "ZLLTemperature"
+	SensorTypeZLLLightLevel     = "ZLLLightLevel"
+	SensorTypeZLLSwitch         = "ZLLSwitch"
+	SensorTypeZGPSwitch         = "ZGPSwitch"
+	SensorTypeCLIPGenericStatus = "CLIPGenericStatus"
+	SensorTypeCLIPGenericFlag   = "CLIPGenericFlag"
+	SensorTypeCLIPPresence      = "CLIPPresence"
+	SensorTypeCLIPTemperature   = "CLIPTemperature"
+	SensorTypeCLIPLightLevel    = "CLIPLightLevel"
+	SensorTypeCLIPSwitch        = "CLIPSwitch"
+	SensorTypeCLIPOpenClose     = "CLIPOpenClose"
+	SensorTypeCLIPHumidity      = "CLIPHumidity"
+)
+
+// SensorConfig holds the configuration attributes shared by most sensors. Attributes that are nil or empty
+// are left out so that it can be passed to Sensor.SetConfig to change some attributes only.
+type SensorConfig struct {
+	On            *bool    `json:"on,omitempty"`
+	Reachable     *bool    `json:"reachable,omitempty"`
+	Battery       *int     `json:"battery,omitempty"`
+	Alert         string   `json:"alert,omitempty"`
+	LedIndication *bool    `json:"ledindication,omitempty"`
+	UserTest      *bool    `json:"usertest,omitempty"`
+	URL           string   `json:"url,omitempty"`
+	Pending       []string `json:"pending,omitempty"`
+}
+
+// DaylightState is the state of the built-in Daylight sensor. Daylight is nil until the sensor has been configured.
+type DaylightState struct {
+	Daylight    *bool  `json:"daylight"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// DaylightConfig is the configuration of the built-in Daylight sensor
+type DaylightConfig struct {
+	On            bool   `json:"on"`
+	Configured    bool   `json:"configured"`
+	Long          string `json:"long,omitempty"`
+	Lat           string `json:"lat,omitempty"`
+	SunriseOffset int8   `json:"sunriseoffset"`
+	SunsetOffset  int8   `json:"sunsetoffset"`
+}
+
+// PresenceState is the state of a motion sensor
+type PresenceState struct {
+	Presence    bool   `json:"presence"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// PresenceConfig is the configuration of a motion sensor
+type PresenceConfig struct {
+	SensorConfig
+	Sensitivity    int `json:"sensitivity,omitempty"`
+	SensitivityMax int `json:"sensitivitymax,omitempty"`
+}
+
+// TemperatureState is the state of a temperature sensor. Temperature is in 0.01 degrees Celsius.
+type TemperatureState struct {
+	Temperature int    `json:"temperature"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// Celsius returns the temperature in degrees Celsius
+func (t *TemperatureState) Celsius() float64 {
+	return float64(t.Temperature) / 100
+}
+
+// LightLevelState is the state of a light level sensor. LightLevel is 10000 log10(lux) + 1.
+type LightLevelState struct {
+	LightLevel  int    `json:"lightlevel"`
+	Dark        bool   `json:"dark"`
+	Daylight    bool   `json:"daylight"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// Lux returns the light level in lux
+func (l *LightLevelState) Lux() float64 {
+	return math.Pow(10, float64(l.LightLevel-1)/10000)
+}
+
+// LightLevelConfig is the configuration of a light level sensor
+type LightLevelConfig struct {
+	SensorConfig
+	TholdDark   int `json:"tholddark"`
+	TholdOffset int `json:"tholdoffset"`
+}
+
+// SwitchState is the state of a switch such as the Hue dimmer switch or Hue tap. ButtonEvent is the last button event.
+type SwitchState struct {
+	ButtonEvent int    `json:"buttonevent"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// GenericStatusState is the state of a CLIPGenericStatus sensor
+type GenericStatusState struct {
+	Status      int    `json:"status"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// GenericFlagState is the state of a CLIPGenericFlag sensor
+type GenericFlagState struct {
+	Flag        bool   `json:"flag"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// OpenCloseState is the state of a CLIPOpenClose sensor
+type OpenCloseState struct {
+	Open        bool   `json:"open"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// HumidityState is the state of a humidity sensor. Humidity is in 0.01 percent.
+type HumidityState struct {
+	Humidity    int    `json:"humidity"`
+	LastUpdated string `json:"lastupdated"`
+}
+
+// sensorType holds constructors of the typed state and config of one sensor type
+type sensorType struct {
+	state  func() interface{}
+	config func() interface{}
+}
+
+var sensorTypes = map[string]sensorType{}
+
+func init() {
+	newSensorConfig := func() interface{} { return &SensorConfig{} }
+	RegisterSensorType(SensorTypeDaylight, func() interface{} { return &DaylightState{} }, func() interface{} { return &DaylightConfig{} })
+	RegisterSensorType(SensorTypeZLLPresence, func() interface{} { return &PresenceState{} }, func() interface{} { return &PresenceConfig{} })
+	RegisterSensorType(SensorTypeCLIPPresence, func() interface{} { return &PresenceState{} }, newSensorConfig)
+	RegisterSensorType(SensorTypeZLLTemperature, func() interface{} { return &TemperatureState{} }, newSensorConfig)
+	RegisterSensorType(SensorTypeCLIPTemperature, func() interface{} { return &TemperatureState{} }, newSensorConfig)
+	RegisterSensorType(SensorTypeZLLLightLevel, func() interface{} { return &LightLevelState{} }, func() interface{} { return &LightLevelConfig{} })
+	RegisterSensorType(SensorTypeCLIPLightLevel, func() interface{} { return &LightLevelState{} }, func() interface{} { return &LightLevelConfig{} })
+	RegisterSensorType(SensorTypeZLLSwitch, func() interface{} { return &SwitchState{} }, newSensorConfig)
+	RegisterSensorType(SensorTypeZGPSwitch, func() interface{} { return &SwitchState{} }, newSensorConfig)
+	RegisterSensorType(SensorTypeCLIPSwitch, func() interface{} { return &SwitchState{} }, newSensorConfig)
+	RegisterSensorType(SensorTypeCLIPGenericStatus, func() interface{} { return &GenericStatusState{} }, newSensorConfig)
+	RegisterSensorType(SensorTypeCLIPGenericFlag, func() interface{} { return &GenericFlagState{} }, newSensorConfig)
+	RegisterSensorType(SensorTypeCLIPOpenClose, func() interface{} { return &OpenCloseState{} }, newSensorConfig)
+	RegisterSensorType(SensorTypeCLIPHumidity, func() interface{} { return &HumidityState{} }, newSensorConfig)
+}
+
+// RegisterSensorType registers the typed state and config returned by TypedState and TypedConfig for sensors of type typ.
+// state and config must return pointers to new values that the raw state and config can be decoded into.
+// Registering a type that is already registered replaces it. RegisterSensorType is not safe for concurrent use and
+// is typically called from an init function.
+func RegisterSensorType(typ string, state, config func() interface{}) {
+	sensorTypes[typ] = sensorType{state: state, config: config}
+}
+
+// TypedState returns the state of the sensor decoded into the type registered for s.Type, for example *PresenceState
+// for ZLLPresence sensors. The raw State map is returned for sensor types that are not registered.
+func (s *Sensor) TypedState() (interface{}, error) {
+	t, ok := sensorTypes[s.Type]
+	if !ok || t.state == nil {
+		return s.State, nil
+	}
+	v := t.state()
+	return v, s.DecodeState(v)
+}
+
+// TypedConfig returns the config of the sensor decoded into the type registered for s.Type, for example *PresenceConfig
+// for ZLLPresence sensors. The raw Config map is returned for sensor types that are not registered.
+func (s *Sensor) TypedConfig() (interface{}, error) {
+	t, ok := sensorTypes[s.Type]
+	if !ok || t.config == nil {
+		return s.Config, nil
+	}
+	v := t.config()
+	return v, s.DecodeConfig(v)
+}
+
+// DecodeState decodes the raw state of the sensor into v, which must be a pointer
+func (s *Sensor) DecodeState(v interface{}) error {
+	return decodeMap(s.State, v)
+}
+
+// DecodeConfig decodes the raw config of the sensor into v, which must be a pointer
+func (s *Sensor) DecodeConfig(v interface{}) error {
+	return decodeMap(s.Config, v)
+}
+
+func decodeMap(m map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
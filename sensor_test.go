@@ -177,3 +177,173 @@ func TestDeleteSensor(t *testing.T) {
 	}
 	t.Logf("Sensor %d deleted", id)
 }
+
+func TestSensorTypedState(t *testing.T) {
+	b := New(hostname, username)
+	sensors, err := b.GetSensors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range sensors {
+		state, err := s.TypedState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := s.TypedConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch s.Type {
+		case SensorTypeDaylight:
+			st := state.(*DaylightState)
+			assert.False(t, *st.Daylight)
+			assert.Equal(t, "2014-06-27T07:38:51", st.LastUpdated)
+			c := config.(*DaylightConfig)
+			assert.True(t, c.On)
+			assert.Equal(t, int8(50), c.SunriseOffset)
+			assert.Equal(t, "none", c.Lat)
+		case SensorTypeZGPSwitch:
+			assert.Equal(t, &SwitchState{ButtonEvent: 0, LastUpdated: "none"}, state)
+			assert.True(t, *config.(*SensorConfig).On)
+		default:
+			t.Fatalf("unexpected sensor type %s", s.Type)
+		}
+	}
+}
+
+func TestSensorTypedStateTypes(t *testing.T) {
+
+	s := &Sensor{
+		Type:   SensorTypeZLLPresence,
+		State:  map[string]interface{}{"presence": true, "lastupdated": "2020-01-01T12:00:00"},
+		Config: map[string]interface{}{"on": true, "battery": float64(87), "reachable": true, "sensitivity": float64(2), "sensitivitymax": float64(2), "pending": []interface{}{}},
+	}
+	state, err := s.TypedState()
+	assert.Nil(t, err)
+	assert.True(t, state.(*PresenceState).Presence)
+	config, err := s.TypedConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, 87, *config.(*PresenceConfig).Battery)
+	assert.Equal(t, 2, config.(*PresenceConfig).Sensitivity)
+
+	s = &Sensor{Type: SensorTypeZLLTemperature, State: map[string]interface{}{"temperature": float64(2134)}}
+	state, _ = s.TypedState()
+	assert.Equal(t, 21.34, state.(*TemperatureState).Celsius())
+
+	s = &Sensor{Type: SensorTypeZLLLightLevel, State: map[string]interface{}{"lightlevel": float64(20001), "dark": false, "daylight": true}}
+	state, _ = s.TypedState()
+	assert.InDelta(t, 100, state.(*LightLevelState).Lux(), 0.0001)
+
+	s = &Sensor{Type: SensorTypeCLIPGenericStatus, State: map[string]interface{}{"status": float64(3)}}
+	state, _ = s.TypedState()
+	assert.Equal(t, 3, state.(*GenericStatusState).Status)
+
+	s = &Sensor{Type: SensorTypeCLIPGenericFlag, State: map[string]interface{}{"flag": true}}
+	state, _ = s.TypedState()
+	assert.True(t, state.(*GenericFlagState).Flag)
+
+	// Unknown types return the raw maps
+	s = &Sensor{Type: "ZLLRelativeRotary", State: map[string]interface{}{"rotaryevent": float64(1)}}
+	state, err = s.TypedState()
+	assert.Nil(t, err)
+	assert.Equal(t, s.State, state)
+
+	// Wrongly typed values are reported
+	s = &Sensor{Type: SensorTypeZLLSwitch, State: map[string]interface{}{"buttonevent": "1002"}}
+	_, err = s.TypedState()
+	assert.NotNil(t, err)
+}
+
+func TestRegisterSensorType(t *testing.T) {
+
+	type rotaryState struct {
+		RotaryEvent    int `json:"rotaryevent"`
+		ExpectedRotary int `json:"expectedrotation"`
+	}
+
+	RegisterSensorType("ZLLRelativeRotary", func() interface{} { return &rotaryState{} }, nil)
+	defer delete(sensorTypes, "ZLLRelativeRotary")
+
+	s := &Sensor{Type: "ZLLRelativeRotary", State: map[string]interface{}{"rotaryevent": float64(2), "expectedrotation": float64(90)}, Config: map[string]interface{}{"on": true}}
+	state, err := s.TypedState()
+	assert.Nil(t, err)
+	assert.Equal(t, &rotaryState{RotaryEvent: 2, ExpectedRotary: 90}, state)
+
+	config, err := s.TypedConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, s.Config, config)
+}
+
+func TestUpdateSensorState(t *testing.T) {
+	b := New(hostname, username)
+	id := 1
+	resp, err := b.UpdateSensorState(id, map[string]interface{}{"presence": false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range resp.Success {
+		t.Logf("%v: %s", k, v)
+	}
+
+	b.Host = badHostname
+	_, err = b.UpdateSensorState(id, map[string]interface{}{"presence": false})
+	assert.NotNil(t, err)
+}
+
+func TestSensorMethods(t *testing.T) {
+	b := New(hostname, username)
+	sensors, err := b.GetSensors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sensors[0]
+	for _, sn := range sensors {
+		if sn.ID == 1 {
+			s = sn
+		}
+	}
+
+	assert.Nil(t, s.Rename("Sunlight"))
+	assert.Equal(t, "Sunlight", s.Name)
+
+	assert.Nil(t, s.Disable())
+	assert.False(t, s.IsOn())
+	assert.Nil(t, s.Enable())
+	assert.True(t, s.IsOn())
+
+	assert.Nil(t, s.SetConfig(map[string]interface{}{"sunriseoffset": 10}))
+	assert.Equal(t, float64(10), s.Config["sunriseoffset"])
+	assert.Equal(t, float64(50), s.Config["sunsetoffset"])
+
+	assert.Nil(t, s.SetSensitivity(2))
+	assert.Equal(t, float64(2), s.Config["sensitivity"])
+
+	// On is left out unless set
+	led := true
+	assert.Nil(t, s.SetConfig(SensorConfig{LedIndication: &led}))
+	assert.Equal(t, true, s.Config["ledindication"])
+	assert.True(t, s.IsOn())
+
+	// Only the state of CLIP sensors can be set
+	assert.NotNil(t, s.SetState(map[string]interface{}{"daylight": true}))
+	assert.Equal(t, false, s.State["daylight"])
+
+	s.Type = SensorTypeCLIPPresence
+	assert.Nil(t, s.SetState(PresenceState{Presence: true}))
+	assert.Equal(t, true, s.State["presence"])
+
+	assert.Nil(t, s.Refresh())
+	assert.Equal(t, "Wall tap 1", s.Name)
+	assert.Equal(t, 1, s.ID)
+	assert.Nil(t, s.Rename("Wall tap 2"))
+
+	b.Host = badHostname
+	assert.NotNil(t, s.Rename("Daylight"))
+	assert.NotNil(t, s.SetConfig(map[string]interface{}{"sunriseoffset": 10}))
+	assert.NotNil(t, s.Enable())
+	assert.NotNil(t, s.Disable())
+	assert.NotNil(t, s.SetSensitivity(1))
+	assert.NotNil(t, s.Refresh())
+	assert.Equal(t, "Wall tap 2", s.Name)
+}
@@ -0,0 +1,261 @@
+package huego
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// StateChange is a change to the state of a light, the action of a group or a light state of a scene.
+// It is implemented by State and *StateUpdate. Passing a nil state to the bridge is an error.
+type StateChange interface {
+	stateBody() ([]byte, error)
+	stateUpdate() *StateUpdate
+}
+
+var errNilState = errors.New("state is nil")
+
+// stateBody returns the request body of s. A nil s is an error rather than a panic in the methods of s.
+func stateBody(s StateChange) ([]byte, error) {
+	if isNilState(s) {
+		return nil, errNilState
+	}
+	return s.stateBody()
+}
+
+// stateUpdate returns the attributes that s sends, none if s is nil
+func stateUpdate(s StateChange) *StateUpdate {
+	if isNilState(s) {
+		return NewStateUpdate()
+	}
+	return s.stateUpdate()
+}
+
+// isNilState reports whether s is nil or a nil *State or *StateUpdate
+func isNilState(s StateChange) bool {
+	switch v := s.(type) {
+	case nil:
+		return true
+	case *State:
+		return v == nil
+	case *StateUpdate:
+		return v == nil
+	}
+	return false
+}
+
+// stateBody returns s as a request body. Read only attributes are left out.
+func (s State) stateBody() ([]byte, error) {
+	s.Reachable = false
+	s.ColorMode = ""
+	return json.Marshal(&s)
+}
+
+// stateUpdate returns the attributes of s that are sent to the bridge, those that aren't zero and on
+func (s State) stateUpdate() *StateUpdate {
+	u := NewStateUpdate().SetOn(s.On)
+	if s.Bri != 0 {
+		u.SetBri(s.Bri)
+	}
+	if s.Hue != 0 {
+		u.SetHue(s.Hue)
+	}
+	if s.Sat != 0 {
+		u.SetSat(s.Sat)
+	}
+	if s.Xy != nil {
+		u.Xy = append([]float32{}, s.Xy...)
+	}
+	if s.Ct != 0 {
+		u.SetCt(s.Ct)
+	}
+	if s.Alert != "" {
+		u.SetAlert(s.Alert)
+	}
+	if s.Effect != "" {
+		u.SetEffect(s.Effect)
+	}
+	if s.TransitionTime != 0 {
+		u.SetTransitionTime(s.TransitionTime)
+	}
+	if s.BriInc != 0 {
+		u.SetBriInc(s.BriInc)
+	}
+	if s.SatInc != 0 {
+		u.SetSatInc(s.SatInc)
+	}
+	if s.HueInc != 0 {
+		u.SetHueInc(s.HueInc)
+	}
+	if s.CtInc != 0 {
+		u.SetCtInc(s.CtInc)
+	}
+	if s.XyInc != 0 {
+		u.SetXyInc(float32(s.XyInc))
+	}
+	if s.Scene != "" {
+		u.SetScene(s.Scene)
+	}
+	return u
+}
+
+// StateUpdate is a partial state change where only the attributes that are set are sent to the bridge.
+// Unlike State it can send zero values such as hue 0 or transitiontime 0, and it leaves on untouched unless SetOn is called.
+//
+//	u := huego.NewStateUpdate().SetHue(0).SetSat(254).SetTransitionTime(0)
+type StateUpdate struct {
+	On             *bool     `json:"on,omitempty"`
+	Bri            *uint8    `json:"bri,omitempty"`
+	Hue            *uint16   `json:"hue,omitempty"`
+	Sat            *uint8    `json:"sat,omitempty"`
+	Xy             []float32 `json:"xy,omitempty"`
+	Ct             *uint16   `json:"ct,omitempty"`
+	Alert          *string   `json:"alert,omitempty"`
+	Effect         *string   `json:"effect,omitempty"`
+	TransitionTime *uint16   `json:"transitiontime,omitempty"`
+	BriInc         *int      `json:"bri_inc,omitempty"`
+	SatInc         *int      `json:"sat_inc,omitempty"`
+	HueInc         *int      `json:"hue_inc,omitempty"`
+	CtInc          *int      `json:"ct_inc,omitempty"`
+	XyInc          *float32  `json:"xy_inc,omitempty"`
+	Scene          *string   `json:"scene,omitempty"`
+}
+
+// NewStateUpdate returns an empty state update
+func NewStateUpdate() *StateUpdate {
+	return &StateUpdate{}
+}
+
+// stateBody returns the attributes of u that are set as a request body
+func (u *StateUpdate) stateBody() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+// stateUpdate returns a copy of u
+func (u *StateUpdate) stateUpdate() *StateUpdate {
+	c := *u
+	if u.Xy != nil {
+		c.Xy = append([]float32{}, u.Xy...)
+	}
+	return &c
+}
+
+// apply sets the attributes of s that u sets. Increments are left out since the resulting values aren't known.
+func (u *StateUpdate) apply(s *State) {
+	if u.On != nil {
+		s.On = *u.On
+	}
+	if u.Bri != nil {
+		s.Bri = *u.Bri
+	}
+	if u.Hue != nil {
+		s.Hue = *u.Hue
+	}
+	if u.Sat != nil {
+		s.Sat = *u.Sat
+	}
+	if u.Xy != nil {
+		s.Xy = append([]float32{}, u.Xy...)
+	}
+	if u.Ct != nil {
+		s.Ct = *u.Ct
+	}
+	if u.Alert != nil {
+		s.Alert = *u.Alert
+	}
+	if u.Effect != nil {
+		s.Effect = *u.Effect
+	}
+	if u.Scene != nil {
+		s.Scene = *u.Scene
+	}
+}
+
+// SetOn sets the on attribute
+func (u *StateUpdate) SetOn(on bool) *StateUpdate {
+	u.On = &on
+	return u
+}
+
+// SetBri sets the brightness (1-254)
+func (u *StateUpdate) SetBri(bri uint8) *StateUpdate {
+	u.Bri = &bri
+	return u
+}
+
+// SetHue sets the hue (0-65535)
+func (u *StateUpdate) SetHue(hue uint16) *StateUpdate {
+	u.Hue = &hue
+	return u
+}
+
+// SetSat sets the saturation (0-254)
+func (u *StateUpdate) SetSat(sat uint8) *StateUpdate {
+	u.Sat = &sat
+	return u
+}
+
+// SetXy sets the x and y coordinates of a color in CIE color space (0-1 per value)
+func (u *StateUpdate) SetXy(x, y float32) *StateUpdate {
+	u.Xy = []float32{x, y}
+	return u
+}
+
+// SetCt sets the color temperature in mired
+func (u *StateUpdate) SetCt(ct uint16) *StateUpdate {
+	u.Ct = &ct
+	return u
+}
+
+// SetAlert sets the alert effect, one of none, select or lselect
+func (u *StateUpdate) SetAlert(alert string) *StateUpdate {
+	u.Alert = &alert
+	return u
+}
+
+// SetEffect sets the dynamic effect, one of none or colorloop
+func (u *StateUpdate) SetEffect(effect string) *StateUpdate {
+	u.Effect = &effect
+	return u
+}
+
+// SetTransitionTime sets the duration of the transition in multiples of 100ms. 0 changes the state instantly.
+func (u *StateUpdate) SetTransitionTime(t uint16) *StateUpdate {
+	u.TransitionTime = &t
+	return u
+}
+
+// SetBriInc increments or decrements the brightness
+func (u *StateUpdate) SetBriInc(inc int) *StateUpdate {
+	u.BriInc = &inc
+	return u
+}
+
+// SetSatInc increments or decrements the saturation
+func (u *StateUpdate) SetSatInc(inc int) *StateUpdate {
+	u.SatInc = &inc
+	return u
+}
+
+// SetHueInc increments or decrements the hue
+func (u *StateUpdate) SetHueInc(inc int) *StateUpdate {
+	u.HueInc = &inc
+	return u
+}
+
+// SetCtInc increments or decrements the color temperature
+func (u *StateUpdate) SetCtInc(inc int) *StateUpdate {
+	u.CtInc = &inc
+	return u
+}
+
+// SetXyInc increments or decrements the x and y coordinates
+func (u *StateUpdate) SetXyInc(inc float32) *StateUpdate {
+	u.XyInc = &inc
+	return u
+}
+
+// SetScene sets the scene to recall. Only groups accept a scene.
+func (u *StateUpdate) SetScene(id string) *StateUpdate {
+	u.Scene = &id
+	return u
+}
@@ -0,0 +1,66 @@
+package huego
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateUpdate(t *testing.T) {
+
+	bodies := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		bodies[r.URL.Path] = string(data)
+		w.Write([]byte(`[{"success":{}}]`))
+	}))
+	defer srv.Close()
+
+	b := NewWithClient(srv.URL, username, srv.Client())
+
+	// Zero values are sent and on is left out
+	_, err := b.SetLightState(1, NewStateUpdate().SetHue(0).SetSat(0).SetTransitionTime(0))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"hue":0,"sat":0,"transitiontime":0}`, bodies["/api/lights/1/state"])
+
+	_, err = b.SetGroupState(1, NewStateUpdate().SetOn(false).SetScene("abc"))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"on":false,"scene":"abc"}`, bodies["/api/groups/1/action"])
+
+	_, err = b.SetSceneLightState("abc", 1, NewStateUpdate().SetXy(0, 0).SetBriInc(-10))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"xy":[0,0],"bri_inc":-10}`, bodies["/api/scenes/abc/lightstates/1"])
+
+	_, err = b.SetLightState(1, NewStateUpdate())
+	assert.Nil(t, err)
+	assert.Equal(t, `{}`, bodies["/api/lights/1/state"])
+
+	// State always sends on and leaves out read only attributes
+	_, err = b.SetLightState(1, State{Hue: 0, Bri: 10, Reachable: true, ColorMode: "xy"})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"on":false,"bri":10}`, bodies["/api/lights/1/state"])
+
+	_, err = b.SetSceneLightState("abc", 1, &State{On: true})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"on":true}`, bodies["/api/scenes/abc/lightstates/1"])
+
+	// Nil states are an error and nothing is sent
+	delete(bodies, "/api/lights/2/state")
+	var s *State
+	_, err = b.SetLightState(2, s)
+	assert.Equal(t, errNilState, err)
+	var u *StateUpdate
+	_, err = b.SetGroupState(2, u)
+	assert.Equal(t, errNilState, err)
+	_, err = b.SetSceneLightState("abc", 2, nil)
+	assert.Equal(t, errNilState, err)
+	assert.NotContains(t, bodies, "/api/lights/2/state")
+
+	l := &Light{ID: 2, bridge: b}
+	assert.Equal(t, NewStateUpdate(), l.CheckState(s, ValidateStrict).State)
+	_, err = l.SetStateValidated(u, ValidateStrict)
+	assert.Equal(t, errNilState, err)
+}
@@ -0,0 +1,386 @@
+package huego
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimePatternType is the kind of time described by a TimePattern
+type TimePatternType int
+
+// Types of time patterns, https://developers.meethue.com/develop/hue-api/datatypes-and-time-patterns/
+const (
+	// AbsoluteTime is a date and time such as 2021-04-01T07:00:00
+	AbsoluteTime TimePatternType = iota
+	// RecurringTime is a time on one or more weekdays such as W124/T07:00:00
+	RecurringTime
+	// TimeInterval is a period of the day such as W124/T08:00:00/T17:00:00. Used by rule conditions.
+	TimeInterval
+	// Timer is a duration from when the schedule is created or enabled such as R05/PT00:10:00
+	Timer
+)
+
+// RepeatForever is the Repeat value of a timer that repeats until it is disabled
+const RepeatForever = -1
+
+// Weekdays is a set of days in the bitmask format of the bridge, 0MTWTFSS
+type Weekdays uint8
+
+// Common sets of weekdays
+const (
+	EveryDay Weekdays = 127
+	Workdays Weekdays = 124
+	Weekend  Weekdays = 3
+)
+
+// WeekdaysOf returns the set of days
+func WeekdaysOf(days ...time.Weekday) Weekdays {
+	var w Weekdays
+	for _, d := range days {
+		w |= weekdayBit(d)
+	}
+	return w
+}
+
+// Contains returns true if d is in the set
+func (w Weekdays) Contains(d time.Weekday) bool {
+	return w&weekdayBit(d) != 0
+}
+
+// Days returns the days in the set, starting on Monday
+func (w Weekdays) Days() []time.Weekday {
+	var days []time.Weekday
+	for i := 1; i <= 7; i++ {
+		d := time.Weekday(i % 7)
+		if w.Contains(d) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+func weekdayBit(d time.Weekday) Weekdays {
+	// Monday is the most significant bit and Sunday the least
+	return 1 << uint((7-int(d))%7)
+}
+
+// TimePattern is a time in the format used by the bridge for schedules and rule conditions.
+// Times of day are durations since midnight in the local time of the bridge.
+type TimePattern struct {
+	Type TimePatternType
+	// Date is the date and time of an absolute time. The location is ignored.
+	Date time.Time
+	// Weekdays of a recurring time or time interval. 0 means every day for time intervals.
+	Weekdays Weekdays
+	// At is the time of day of a recurring time and the start of a time interval
+	At time.Duration
+	// End is the end of a time interval
+	End time.Duration
+	// Timer is the duration of a timer
+	Timer time.Duration
+	// Repeat is the number of times a timer runs, 0 for once or RepeatForever
+	Repeat int
+	// Random is a random delay of up to this duration added to absolute times, recurring times and timers
+	Random time.Duration
+
+	// weekdayDigits and repeatDigits are the number of digits of Weekdays and Repeat in a parsed pattern
+	// when they differ from the 3 and 2 that String writes, so that it formats the pattern as it was written
+	weekdayDigits int
+	repeatDigits  int
+}
+
+var (
+	absolutePattern  = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2})(?:A(\d{2}:\d{2}:\d{2}))?$`)
+	recurringPattern = regexp.MustCompile(`^W(\d{1,3})/T(\d{2}:\d{2}:\d{2})(?:A(\d{2}:\d{2}:\d{2}))?$`)
+	intervalPattern  = regexp.MustCompile(`^(?:W(\d{1,3})/)?T(\d{2}:\d{2}:\d{2})/T(\d{2}:\d{2}:\d{2})$`)
+	timerPattern     = regexp.MustCompile(`^(?:R(\d{0,2})/)?PT(\d{2}:\d{2}:\d{2})(?:A(\d{2}:\d{2}:\d{2}))?$`)
+)
+
+// ParseTimePattern parses a time pattern such as 2021-04-01T07:00:00, W127/T07:00:00A00:30:00,
+// T08:00:00/T17:00:00 or R05/PT00:10:00
+func ParseTimePattern(s string) (TimePattern, error) {
+
+	var p TimePattern
+	var err error
+
+	invalid := func(err error) (TimePattern, error) {
+		return TimePattern{}, fmt.Errorf("invalid time pattern %q: %v", s, err)
+	}
+
+	if m := absolutePattern.FindStringSubmatch(s); m != nil {
+		p.Type = AbsoluteTime
+		p.Date, err = time.Parse("2006-01-02T15:04:05", m[1])
+		if err != nil {
+			return invalid(err)
+		}
+		p.Random, err = parseDuration(m[2])
+		if err != nil {
+			return invalid(err)
+		}
+		return p, nil
+	}
+
+	if m := recurringPattern.FindStringSubmatch(s); m != nil {
+		p.Type = RecurringTime
+		p.Weekdays, err = parseWeekdays(m[1])
+		if err != nil {
+			return invalid(err)
+		}
+		p.weekdayDigits = digits(m[1], 3)
+		p.At, err = parseTimeOfDay(m[2])
+		if err != nil {
+			return invalid(err)
+		}
+		p.Random, err = parseDuration(m[3])
+		if err != nil {
+			return invalid(err)
+		}
+		return p, nil
+	}
+
+	if m := intervalPattern.FindStringSubmatch(s); m != nil {
+		p.Type = TimeInterval
+		if m[1] != "" {
+			p.Weekdays, err = parseWeekdays(m[1])
+			if err != nil {
+				return invalid(err)
+			}
+			p.weekdayDigits = digits(m[1], 3)
+		}
+		p.At, err = parseTimeOfDay(m[2])
+		if err != nil {
+			return invalid(err)
+		}
+		p.End, err = parseTimeOfDay(m[3])
+		if err != nil {
+			return invalid(err)
+		}
+		return p, nil
+	}
+
+	if m := timerPattern.FindStringSubmatch(s); m != nil {
+		p.Type = Timer
+		if strings.HasPrefix(s, "R") {
+			p.Repeat = RepeatForever
+			if m[1] != "" {
+				p.Repeat, _ = strconv.Atoi(m[1])
+				if p.Repeat == 0 {
+					return invalid(errors.New("a timer can't repeat 0 times"))
+				}
+				p.repeatDigits = digits(m[1], 2)
+			}
+		}
+		p.Timer, err = parseDuration(m[2])
+		if err != nil {
+			return invalid(err)
+		}
+		p.Random, err = parseDuration(m[3])
+		if err != nil {
+			return invalid(err)
+		}
+		return p, nil
+	}
+
+	return invalid(errors.New("unknown format"))
+}
+
+// NewAbsoluteTime returns a time pattern for the date and time of t. The location of t is ignored.
+func NewAbsoluteTime(t time.Time) TimePattern {
+	return TimePattern{Type: AbsoluteTime, Date: t}
+}
+
+// NewRecurringTime returns a time pattern for a time of day on days
+func NewRecurringTime(days Weekdays, at time.Duration) TimePattern {
+	return TimePattern{Type: RecurringTime, Weekdays: days, At: at}
+}
+
+// NewTimeInterval returns a time pattern for the time between from and to on days. days may be 0 for every day.
+func NewTimeInterval(days Weekdays, from, to time.Duration) TimePattern {
+	return TimePattern{Type: TimeInterval, Weekdays: days, At: from, End: to}
+}
+
+// NewTimer returns a time pattern for a timer that expires after d, repeat times or RepeatForever
+func NewTimer(d time.Duration, repeat int) TimePattern {
+	return TimePattern{Type: Timer, Timer: d, Repeat: repeat}
+}
+
+// Randomized returns a copy of p with a random delay of up to d
+func (p TimePattern) Randomized(d time.Duration) TimePattern {
+	p.Random = d
+	return p
+}
+
+// Validate returns an error if p can't be represented in the format of the bridge
+func (p TimePattern) Validate() error {
+	day := 24 * time.Hour
+	max := 100*time.Hour - time.Second
+	switch {
+	case p.Random < 0 || p.Random > max:
+		return fmt.Errorf("random delay %v is out of range", p.Random)
+	case p.Random != 0 && p.Type == TimeInterval:
+		return errors.New("time intervals can't be randomized")
+	}
+	switch p.Type {
+	case AbsoluteTime:
+		if p.Date.IsZero() {
+			return errors.New("absolute time has no date")
+		}
+	case RecurringTime:
+		if p.Weekdays == 0 || p.Weekdays > EveryDay {
+			return fmt.Errorf("invalid weekdays %d", p.Weekdays)
+		}
+		if p.At < 0 || p.At >= day {
+			return fmt.Errorf("time of day %v is out of range", p.At)
+		}
+	case TimeInterval:
+		if p.Weekdays > EveryDay {
+			return fmt.Errorf("invalid weekdays %d", p.Weekdays)
+		}
+		if p.At < 0 || p.At >= day || p.End < 0 || p.End >= day {
+			return fmt.Errorf("time interval %v-%v is out of range", p.At, p.End)
+		}
+	case Timer:
+		if p.Timer <= 0 || p.Timer > max {
+			return fmt.Errorf("timer %v is out of range", p.Timer)
+		}
+		if p.Repeat < RepeatForever || p.Repeat > 99 {
+			return fmt.Errorf("timer repeat %d is out of range", p.Repeat)
+		}
+	default:
+		return fmt.Errorf("unknown time pattern type %d", p.Type)
+	}
+	return nil
+}
+
+// String formats p in the format of the bridge
+func (p TimePattern) String() string {
+
+	var s string
+	switch p.Type {
+	case AbsoluteTime:
+		s = p.Date.Format("2006-01-02T15:04:05")
+	case RecurringTime:
+		s = fmt.Sprintf("W%0*d/T%s", width(p.weekdayDigits, 3), p.Weekdays, formatDuration(p.At))
+	case TimeInterval:
+		if p.Weekdays != 0 {
+			s = fmt.Sprintf("W%0*d/", width(p.weekdayDigits, 3), p.Weekdays)
+		}
+		return s + "T" + formatDuration(p.At) + "/T" + formatDuration(p.End)
+	case Timer:
+		switch {
+		case p.Repeat == RepeatForever:
+			s = "R/"
+		case p.Repeat > 0:
+			s = fmt.Sprintf("R%0*d/", width(p.repeatDigits, 2), p.Repeat)
+		}
+		s += "PT" + formatDuration(p.Timer)
+	}
+
+	if p.Random > 0 {
+		s += "A" + formatDuration(p.Random)
+	}
+
+	return s
+}
+
+// digits returns the number of digits in s, or 0 if it is def
+func digits(s string, def int) int {
+	if len(s) == def {
+		return 0
+	}
+	return len(s)
+}
+
+// width returns digits, or def if digits is 0
+func width(digits, def int) int {
+	if digits == 0 {
+		return def
+	}
+	return digits
+}
+
+// Time returns the date and time of an absolute time in loc
+func (p TimePattern) Time(loc *time.Location) time.Time {
+	d := p.Date
+	return time.Date(d.Year(), d.Month(), d.Day(), d.Hour(), d.Minute(), d.Second(), 0, loc)
+}
+
+// NextOccurrence returns the next time after now that p occurs, in the location of now. For time intervals this
+// is the next start of the interval and for timers when they first expire if they are started at now.
+// Random delays are not included. false is returned if p doesn't occur after now.
+func (p TimePattern) NextOccurrence(now time.Time) (time.Time, bool) {
+
+	switch p.Type {
+	case AbsoluteTime:
+		t := p.Time(now.Location())
+		return t, t.After(now)
+	case RecurringTime, TimeInterval:
+		days := p.Weekdays
+		if days == 0 {
+			days = EveryDay
+		}
+		// The time of day is wall clock time, which is not the time since midnight on days that daylight saving time changes
+		h, m, sec := int(p.At/time.Hour), int(p.At%time.Hour/time.Minute), int(p.At%time.Minute/time.Second)
+		for i := 0; i <= 7; i++ {
+			day := time.Date(now.Year(), now.Month(), now.Day()+i, 0, 0, 0, 0, now.Location())
+			t := time.Date(now.Year(), now.Month(), now.Day()+i, h, m, sec, 0, now.Location())
+			if days.Contains(day.Weekday()) && t.After(now) {
+				return t, true
+			}
+		}
+	case Timer:
+		if p.Timer > 0 {
+			return now.Add(p.Timer), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// TimePattern parses the local time of the schedule, or the time in UTC if it has no local time
+func (s *Schedule) TimePattern() (TimePattern, error) {
+	if s.LocalTime != "" {
+		return ParseTimePattern(s.LocalTime)
+	}
+	return ParseTimePattern(s.Time)
+}
+
+func parseWeekdays(s string) (Weekdays, error) {
+	w, err := strconv.Atoi(s)
+	if err != nil || w < 1 || w > int(EveryDay) {
+		return 0, fmt.Errorf("invalid weekdays %q", s)
+	}
+	return Weekdays(w), nil
+}
+
+// parseDuration parses hh:mm:ss, an empty string is 0
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	var h, m, sec int
+	_, err := fmt.Sscanf(s, "%02d:%02d:%02d", &h, &m, &sec)
+	if err != nil || m > 59 || sec > 59 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second, nil
+}
+
+// parseTimeOfDay parses hh:mm:ss as a time of day
+func parseTimeOfDay(s string) (time.Duration, error) {
+	d, err := parseDuration(s)
+	if err == nil && d >= 24*time.Hour {
+		err = fmt.Errorf("invalid time of day %q", s)
+	}
+	return d, err
+}
+
+// formatDuration formats d as hh:mm:ss
+func formatDuration(d time.Duration) string {
+	s := int(d.Round(time.Second) / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
@@ -0,0 +1,169 @@
+package huego
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimePattern(t *testing.T) {
+
+	tests := []struct {
+		pattern string
+		want    TimePattern
+	}{
+		{"2021-04-01T07:00:00", TimePattern{Type: AbsoluteTime, Date: time.Date(2021, 4, 1, 7, 0, 0, 0, time.UTC)}},
+		{"2021-04-01T07:00:00A00:30:00", TimePattern{Type: AbsoluteTime, Date: time.Date(2021, 4, 1, 7, 0, 0, 0, time.UTC), Random: 30 * time.Minute}},
+		{"W127/T07:00:00", TimePattern{Type: RecurringTime, Weekdays: EveryDay, At: 7 * time.Hour}},
+		{"W124/T06:30:00A00:00:30", TimePattern{Type: RecurringTime, Weekdays: Workdays, At: 6*time.Hour + 30*time.Minute, Random: 30 * time.Second}},
+		{"T08:00:00/T17:00:00", TimePattern{Type: TimeInterval, At: 8 * time.Hour, End: 17 * time.Hour}},
+		{"W003/T22:00:00/T06:00:00", TimePattern{Type: TimeInterval, Weekdays: Weekend, At: 22 * time.Hour, End: 6 * time.Hour}},
+		{"PT00:10:00", TimePattern{Type: Timer, Timer: 10 * time.Minute}},
+		{"PT00:10:00A00:01:00", TimePattern{Type: Timer, Timer: 10 * time.Minute, Random: time.Minute}},
+		{"R05/PT00:01:00", TimePattern{Type: Timer, Timer: time.Minute, Repeat: 5}},
+		{"R/PT99:00:00", TimePattern{Type: Timer, Timer: 99 * time.Hour, Repeat: RepeatForever}},
+	}
+
+	for _, tt := range tests {
+		p, err := ParseTimePattern(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tt.want, p, tt.pattern)
+		assert.Equal(t, tt.pattern, p.String())
+		assert.Nil(t, p.Validate(), tt.pattern)
+	}
+
+	for _, s := range []string{"", "none", "2021-13-01T07:00:00", "W128/T07:00:00", "W000/T07:00:00", "W127/T24:00:00", "T07:60:00/T08:00:00", "R00/PT00:01:00", "PT00:01", "W127/T07:00:00/T08:00:00A00:10:00"} {
+		_, err := ParseTimePattern(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestTimePatternRoundTrip(t *testing.T) {
+
+	// Patterns are formatted as they were written, whatever the number of digits
+	for _, s := range []string{"W3/T07:00:00", "W03/T07:00:00A00:10:00", "W124/T07:00:00", "W3/T22:00:00/T06:00:00", "R5/PT00:01:00", "R05/PT00:01:00"} {
+		p, err := ParseTimePattern(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s, p.String())
+	}
+
+	p, err := ParseTimePattern("W3/T07:00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Weekend, p.Weekdays)
+}
+
+func TestTimePatternBuilders(t *testing.T) {
+
+	assert.Equal(t, "W124/T07:15:00", NewRecurringTime(Workdays, 7*time.Hour+15*time.Minute).String())
+	assert.Equal(t, "W064/T07:00:00A00:15:00", NewRecurringTime(WeekdaysOf(time.Monday), 7*time.Hour).Randomized(15*time.Minute).String())
+	assert.Equal(t, "T20:00:00/T23:00:00", NewTimeInterval(0, 20*time.Hour, 23*time.Hour).String())
+	assert.Equal(t, "R10/PT00:00:30", NewTimer(30*time.Second, 10).String())
+	assert.Equal(t, "2021-04-01T07:00:00", NewAbsoluteTime(time.Date(2021, 4, 1, 7, 0, 0, 0, time.Local)).String())
+
+	assert.NotNil(t, NewRecurringTime(0, time.Hour).Validate())
+	assert.NotNil(t, NewRecurringTime(EveryDay, 25*time.Hour).Validate())
+	assert.NotNil(t, NewTimer(0, 0).Validate())
+	assert.NotNil(t, NewTimer(time.Minute, 100).Validate())
+	assert.NotNil(t, NewTimeInterval(0, time.Hour, 2*time.Hour).Randomized(time.Minute).Validate())
+	assert.NotNil(t, NewAbsoluteTime(time.Time{}).Validate())
+	assert.NotNil(t, TimePattern{Type: 9}.Validate())
+}
+
+func TestWeekdays(t *testing.T) {
+	assert.Equal(t, Workdays, WeekdaysOf(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday))
+	assert.Equal(t, Weekend, WeekdaysOf(time.Saturday, time.Sunday))
+	assert.Equal(t, Weekdays(64), WeekdaysOf(time.Monday))
+	assert.Equal(t, Weekdays(1), WeekdaysOf(time.Sunday))
+	assert.True(t, Weekend.Contains(time.Sunday))
+	assert.False(t, Weekend.Contains(time.Friday))
+	assert.Equal(t, []time.Weekday{time.Monday, time.Sunday}, WeekdaysOf(time.Sunday, time.Monday).Days())
+	assert.Len(t, EveryDay.Days(), 7)
+}
+
+func TestNextOccurrence(t *testing.T) {
+
+	// A Wednesday
+	now := time.Date(2021, 4, 7, 12, 0, 0, 0, time.UTC)
+
+	next, ok := NewRecurringTime(EveryDay, 13*time.Hour).NextOccurrence(now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, 4, 7, 13, 0, 0, 0, time.UTC), next)
+
+	next, ok = NewRecurringTime(EveryDay, 7*time.Hour).NextOccurrence(now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, 4, 8, 7, 0, 0, 0, time.UTC), next)
+
+	next, ok = NewRecurringTime(Weekend, 7*time.Hour).NextOccurrence(now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, 4, 10, 7, 0, 0, 0, time.UTC), next)
+
+	// Only on Wednesdays, and it already happened today
+	next, ok = NewRecurringTime(WeekdaysOf(time.Wednesday), 12*time.Hour).NextOccurrence(now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, 4, 14, 12, 0, 0, 0, time.UTC), next)
+
+	next, ok = NewTimeInterval(0, 20*time.Hour, 23*time.Hour).NextOccurrence(now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, 4, 7, 20, 0, 0, 0, time.UTC), next)
+
+	next, ok = NewTimer(10*time.Minute, 0).NextOccurrence(now)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(10*time.Minute), next)
+
+	next, ok = NewAbsoluteTime(time.Date(2021, 4, 8, 7, 0, 0, 0, time.UTC)).NextOccurrence(now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2021, 4, 8, 7, 0, 0, 0, time.UTC), next)
+
+	_, ok = NewAbsoluteTime(time.Date(2021, 4, 6, 7, 0, 0, 0, time.UTC)).NextOccurrence(now)
+	assert.False(t, ok)
+
+	// Absolute times are in the location of now
+	loc := time.FixedZone("CEST", 2*60*60)
+	next, _ = NewAbsoluteTime(time.Date(2021, 4, 8, 7, 0, 0, 0, time.UTC)).NextOccurrence(now.In(loc))
+	assert.Equal(t, time.Date(2021, 4, 8, 7, 0, 0, 0, loc), next)
+}
+
+func TestNextOccurrenceDST(t *testing.T) {
+
+	loc, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	// Clocks go forward from 02:00 to 03:00 on 2026-03-29 and back from 03:00 to 02:00 on 2026-10-25
+	next, ok := NewRecurringTime(EveryDay, 7*time.Hour).NextOccurrence(time.Date(2026, 3, 29, 0, 30, 0, 0, loc))
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 3, 29, 7, 0, 0, 0, loc), next)
+
+	next, ok = NewTimeInterval(0, 7*time.Hour, 8*time.Hour).NextOccurrence(time.Date(2026, 10, 25, 0, 30, 0, 0, loc))
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 10, 25, 7, 0, 0, 0, loc), next)
+
+	next, ok = NewRecurringTime(WeekdaysOf(time.Sunday), 7*time.Hour).NextOccurrence(time.Date(2026, 3, 28, 12, 0, 0, 0, loc))
+	assert.True(t, ok)
+	assert.Equal(t, "2026-03-29T07:00:00+02:00", next.Format(time.RFC3339))
+}
+
+func TestScheduleTimePattern(t *testing.T) {
+
+	s := &Schedule{Time: "2021-04-01T05:00:00", LocalTime: "2021-04-01T07:00:00"}
+	p, err := s.TimePattern()
+	assert.Nil(t, err)
+	assert.Equal(t, 7, p.Date.Hour())
+
+	s = &Schedule{Time: "W127/T05:00:00"}
+	p, err = s.TimePattern()
+	assert.Nil(t, err)
+	assert.Equal(t, RecurringTime, p.Type)
+
+	s = &Schedule{}
+	_, err = s.TimePattern()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,409 @@
+package huego
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Validation controls how a state that doesn't fit the capabilities of a light is handled
+type Validation int
+
+const (
+	// ValidateStrict rejects a state with values out of range or attributes that the light doesn't support
+	ValidateStrict Validation = iota
+	// ValidateClamp clamps values to the range of the light and removes attributes that the light doesn't support
+	ValidateClamp
+)
+
+// Default color temperature range in mired of lights that don't report it in their capabilities
+const (
+	minCt = 153
+	maxCt = 500
+)
+
+// StateIssue is an attribute of a state that doesn't fit the capabilities of a light
+type StateIssue struct {
+	// Attribute is the name of the attribute as sent to the bridge, for example ct
+	Attribute string
+	// Value is the value that was requested
+	Value interface{}
+	// Adjusted is the value sent instead of Value. It is nil if the attribute was removed or rejected.
+	Adjusted interface{}
+	Reason   string
+}
+
+// String returns the issue as <attribute> <value>: <reason>
+func (i StateIssue) String() string {
+	s := fmt.Sprintf("%s %v: %s", i.Attribute, i.Value, i.Reason)
+	if i.Adjusted != nil {
+		s += fmt.Sprintf(", set to %v", i.Adjusted)
+	}
+	return s
+}
+
+// StateReport is the result of checking a state against the capabilities of lights
+type StateReport struct {
+	// State holds the attributes that are sent to the bridge
+	State *StateUpdate
+	// Adjusted holds the attributes that were clamped or removed
+	Adjusted []StateIssue
+	// Rejected holds the attributes that were rejected. The state isn't sent if there are any.
+	Rejected []StateIssue
+}
+
+// Err returns the rejected attributes as a StateError or nil if none were rejected
+func (r *StateReport) Err() error {
+	if len(r.Rejected) == 0 {
+		return nil
+	}
+	return StateError(r.Rejected)
+}
+
+// StateError holds the attributes of a state that were rejected
+type StateError []StateIssue
+
+// Error returns the rejected attributes separated by semicolons
+func (e StateError) Error() string {
+	s := make([]string, len(e))
+	for i, issue := range e {
+		s[i] = issue.String()
+	}
+	return "invalid state: " + strings.Join(s, "; ")
+}
+
+// Is reports whether target is ErrInvalidValue, the error the bridge would have returned. Used by errors.Is
+func (e StateError) Is(target error) bool {
+	return ErrInvalidValue.Is(target)
+}
+
+// CheckState checks s against the capabilities of the light. Only the attributes that s sends are checked: all of a
+// *StateUpdate that are set, and on and the attributes that aren't zero of a State. The returned report holds the
+// attributes to send and those that were adjusted or rejected according to v. No request is made to the bridge.
+func (l *Light) CheckState(s StateChange, v Validation) *StateReport {
+	return checkState(stateUpdate(s), limitsOf([]*Light{l}, false), v)
+}
+
+// SetStateValidated checks s against the capabilities of the light and sets the state of the light to the result
+func (l *Light) SetStateValidated(s StateChange, v Validation) (*StateReport, error) {
+	return l.SetStateValidatedContext(context.Background(), s, v)
+}
+
+// SetStateValidatedContext checks s against the capabilities of the light and sets the state of the light to the result.
+// Nothing is sent to the bridge if any attribute is rejected, in which case the error is a StateError.
+func (l *Light) SetStateValidatedContext(ctx context.Context, s StateChange, v Validation) (*StateReport, error) {
+	if isNilState(s) {
+		return nil, errNilState
+	}
+	r := l.CheckState(s, v)
+	if err := r.Err(); err != nil {
+		return r, err
+	}
+	_, err := l.bridge.SetLightStateContext(ctx, l.ID, r.State)
+	if err != nil {
+		return r, err
+	}
+	if l.State == nil {
+		l.State = &State{}
+	}
+	r.State.apply(l.State)
+	return r, nil
+}
+
+// CheckState checks s against the capabilities of the lights in the group
+func (g *Group) CheckState(s StateChange, v Validation) (*StateReport, error) {
+	return g.CheckStateContext(context.Background(), s, v)
+}
+
+// CheckStateContext checks s against the capabilities of the lights in the group. An attribute is supported if any
+// light in the group supports it and values are limited to the combined range of the lights. The bridge applies
+// the state to each light as far as the light is able to show it. A group without lights is an error.
+func (g *Group) CheckStateContext(ctx context.Context, s StateChange, v Validation) (*StateReport, error) {
+
+	lights, err := g.bridge.GetLightsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []*Light
+	for i := range lights {
+		if g.ID == 0 || contains(g.Lights, strconv.Itoa(lights[i].ID)) {
+			members = append(members, &lights[i])
+		}
+	}
+
+	if len(members) == 0 {
+		return nil, fmt.Errorf("group %d has no lights", g.ID)
+	}
+
+	return checkState(stateUpdate(s), limitsOf(members, true), v), nil
+}
+
+// SetStateValidated checks s against the capabilities of the lights in the group and sets the state of the group to the result
+func (g *Group) SetStateValidated(s StateChange, v Validation) (*StateReport, error) {
+	return g.SetStateValidatedContext(context.Background(), s, v)
+}
+
+// SetStateValidatedContext checks s against the capabilities of the lights in the group and sets the state of the group to the result.
+// Nothing is sent to the bridge if any attribute is rejected, in which case the error is a StateError.
+func (g *Group) SetStateValidatedContext(ctx context.Context, s StateChange, v Validation) (*StateReport, error) {
+	if isNilState(s) {
+		return nil, errNilState
+	}
+	r, err := g.CheckStateContext(ctx, s, v)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Err(); err != nil {
+		return r, err
+	}
+	_, err = g.bridge.SetGroupStateContext(ctx, g.ID, r.State)
+	if err != nil {
+		return r, err
+	}
+	if g.State == nil {
+		g.State = &State{}
+	}
+	r.State.apply(g.State)
+	return r, nil
+}
+
+// stateLimits are the combined capabilities of one or more lights
+type stateLimits struct {
+	dimmable bool
+	color    bool
+	ct       bool
+	scene    bool
+	ctMin    uint16
+	ctMax    uint16
+	// gamuts of the color lights, nil if the gamut of any of them is unknown
+	gamuts []*Gamut
+}
+
+// limitsOf returns the combined capabilities of lights. Scenes can only be recalled on groups.
+func limitsOf(lights []*Light, group bool) *stateLimits {
+
+	lim := &stateLimits{scene: group}
+	unknownGamut := false
+
+	for _, l := range lights {
+		t := lightType(l.Type)
+		if t != "on/offlight" && t != "on/offplug-inunit" {
+			lim.dimmable = true
+		}
+		if l.SupportsColor() {
+			lim.color = true
+			if g := l.Gamut(); g != nil {
+				lim.gamuts = append(lim.gamuts, g)
+			} else {
+				unknownGamut = true
+			}
+		}
+		if l.SupportsCt() {
+			r := l.CtRange()
+			if r == nil {
+				r = &CtRange{Min: minCt, Max: maxCt}
+			}
+			if !lim.ct || r.Min < lim.ctMin {
+				lim.ctMin = r.Min
+			}
+			if !lim.ct || r.Max > lim.ctMax {
+				lim.ctMax = r.Max
+			}
+			lim.ct = true
+		}
+	}
+
+	if unknownGamut {
+		lim.gamuts = nil
+	}
+
+	return lim
+}
+
+// checkState checks the attributes that u sets against lim and returns the attributes to send along with the issues found
+func checkState(u *StateUpdate, lim *stateLimits, v Validation) *StateReport {
+
+	r := &StateReport{State: u}
+
+	// fix records an attribute that can be adjusted, or removed if adjusted is nil. It returns
+	// true if the attribute should be changed, which is only the case when clamping.
+	fix := func(attr string, value, adjusted interface{}, reason string) bool {
+		if v != ValidateClamp {
+			r.Rejected = append(r.Rejected, StateIssue{Attribute: attr, Value: value, Reason: reason})
+			return false
+		}
+		r.Adjusted = append(r.Adjusted, StateIssue{Attribute: attr, Value: value, Adjusted: adjusted, Reason: reason})
+		return true
+	}
+	reject := func(attr string, value interface{}, reason string) {
+		r.Rejected = append(r.Rejected, StateIssue{Attribute: attr, Value: value, Reason: reason})
+	}
+	unsupported := "not supported"
+
+	if u.Bri != nil {
+		bri := *u.Bri
+		if !lim.dimmable {
+			if fix("bri", bri, nil, unsupported) {
+				u.Bri = nil
+			}
+		} else if bri < 1 || bri > 254 {
+			n := uint8(clamp(float64(bri), 1, 254))
+			if fix("bri", bri, n, "out of range 1-254") {
+				u.SetBri(n)
+			}
+		}
+	}
+
+	if u.Hue != nil && !lim.color {
+		if fix("hue", *u.Hue, nil, unsupported) {
+			u.Hue = nil
+		}
+	}
+
+	if u.Sat != nil {
+		sat := *u.Sat
+		if !lim.color {
+			if fix("sat", sat, nil, unsupported) {
+				u.Sat = nil
+			}
+		} else if sat > 254 {
+			if fix("sat", sat, uint8(254), "out of range 0-254") {
+				u.SetSat(254)
+			}
+		}
+	}
+
+	if u.Xy != nil {
+		if len(u.Xy) != 2 {
+			reject("xy", u.Xy, "must be x and y")
+		} else if !lim.color {
+			if fix("xy", u.Xy, nil, unsupported) {
+				u.Xy = nil
+			}
+		} else if xy, reason := lim.clampXy(u.Xy); reason != "" {
+			if fix("xy", u.Xy, xy, reason) {
+				u.Xy = xy
+			}
+		}
+	}
+
+	if u.Ct != nil {
+		ct := *u.Ct
+		if !lim.ct {
+			if fix("ct", ct, nil, unsupported) {
+				u.Ct = nil
+			}
+		} else if ct < lim.ctMin || ct > lim.ctMax {
+			n := uint16(clamp(float64(ct), float64(lim.ctMin), float64(lim.ctMax)))
+			if fix("ct", ct, n, fmt.Sprintf("out of range %d-%d", lim.ctMin, lim.ctMax)) {
+				u.SetCt(n)
+			}
+		}
+	}
+
+	if u.Alert != nil && !contains([]string{"none", "select", "lselect"}, *u.Alert) {
+		reject("alert", *u.Alert, "must be none, select or lselect")
+	}
+
+	if u.Effect != nil {
+		if !contains([]string{"none", "colorloop"}, *u.Effect) {
+			reject("effect", *u.Effect, "must be none or colorloop")
+		} else if !lim.color {
+			if fix("effect", *u.Effect, nil, unsupported) {
+				u.Effect = nil
+			}
+		}
+	}
+
+	incs := []struct {
+		attr      string
+		inc       **int
+		supported bool
+		max       int
+	}{
+		{"bri_inc", &u.BriInc, lim.dimmable, 254},
+		{"sat_inc", &u.SatInc, lim.color, 254},
+		{"hue_inc", &u.HueInc, lim.color, 65534},
+		{"ct_inc", &u.CtInc, lim.ct, 65534},
+	}
+	for _, i := range incs {
+		if *i.inc == nil {
+			continue
+		}
+		inc := **i.inc
+		if !i.supported {
+			if fix(i.attr, inc, nil, unsupported) {
+				*i.inc = nil
+			}
+		} else if inc < -i.max || inc > i.max {
+			n := int(clamp(float64(inc), float64(-i.max), float64(i.max)))
+			if fix(i.attr, inc, n, fmt.Sprintf("out of range %d-%d", -i.max, i.max)) {
+				*i.inc = &n
+			}
+		}
+	}
+
+	if u.XyInc != nil {
+		inc := *u.XyInc
+		if !lim.color {
+			if fix("xy_inc", inc, nil, unsupported) {
+				u.XyInc = nil
+			}
+		} else if inc < -0.5 || inc > 0.5 {
+			n := float32(clamp(float64(inc), -0.5, 0.5))
+			if fix("xy_inc", inc, n, "out of range -0.5-0.5") {
+				u.SetXyInc(n)
+			}
+		}
+	}
+
+	if u.Scene != nil && !lim.scene {
+		if fix("scene", *u.Scene, nil, "only groups can recall scenes") {
+			u.Scene = nil
+		}
+	}
+
+	return r
+}
+
+// clampXy returns xy limited to 0-1 and to the closest of the gamuts along with the reason it was changed.
+// The reason is empty if xy is within range.
+func (lim *stateLimits) clampXy(xy []float32) ([]float32, string) {
+
+	reason := ""
+	c := []float32{float32(clamp(float64(xy[0]), 0, 1)), float32(clamp(float64(xy[1]), 0, 1))}
+	if c[0] != xy[0] || c[1] != xy[1] {
+		reason = "out of range 0-1"
+	}
+
+	if len(lim.gamuts) == 0 {
+		return c, reason
+	}
+
+	var best []float32
+	for _, g := range lim.gamuts {
+		if g.Contains(c) {
+			return c, reason
+		}
+		p := g.Clamp(c)
+		if best == nil || distance(point(c), point(p)) < distance(point(c), point(best)) {
+			best = p
+		}
+	}
+
+	if reason == "" {
+		reason = "outside the color gamut"
+	}
+	return best, reason
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package huego_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/amimof/huego"
+	"github.com/amimof/huego/huegotest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLightCheckState(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	defer srv.Close()
+	b := srv.Bridge()
+
+	ambiance, err := b.GetLight(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := huego.State{On: true, Bri: 255, Ct: 600, Xy: []float32{0.3, 0.3}}
+
+	r := ambiance.CheckState(s, huego.ValidateStrict)
+	assert.Equal(t, huego.NewStateUpdate().SetOn(true).SetBri(255).SetCt(600).SetXy(0.3, 0.3), r.State)
+	assert.Empty(t, r.Adjusted)
+	assert.Equal(t, []huego.StateIssue{
+		{Attribute: "bri", Value: uint8(255), Reason: "out of range 1-254"},
+		{Attribute: "xy", Value: []float32{0.3, 0.3}, Reason: "not supported"},
+		{Attribute: "ct", Value: uint16(600), Reason: "out of range 153-454"},
+	}, r.Rejected)
+
+	r = ambiance.CheckState(s, huego.ValidateClamp)
+	assert.Nil(t, r.Err())
+	assert.Equal(t, huego.NewStateUpdate().SetOn(true).SetBri(254).SetCt(454), r.State)
+	assert.Equal(t, "ct 600: out of range 153-454, set to 454", r.Adjusted[2].String())
+
+	// Values that can't be adjusted are rejected in either mode
+	r = ambiance.CheckState(huego.State{Alert: "blink"}, huego.ValidateClamp)
+	assert.EqualError(t, r.Err(), "invalid state: alert blink: must be none, select or lselect")
+	assert.True(t, errors.Is(r.Err(), huego.ErrInvalidValue))
+
+	color, err := b.GetLight(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r = color.CheckState(huego.State{On: true, Xy: []float32{0.1, 0.9}, Scene: "abc"}, huego.ValidateClamp)
+	assert.Len(t, r.Adjusted, 2)
+	assert.Equal(t, "outside the color gamut", r.Adjusted[0].Reason)
+	assert.True(t, huego.GamutC.Contains(r.State.Xy))
+	assert.Empty(t, r.State.Scene)
+
+	r = color.CheckState(huego.State{On: true, Hue: 0, Sat: 254, Ct: 153, Effect: "colorloop"}, huego.ValidateStrict)
+	assert.Nil(t, r.Err())
+	assert.Empty(t, r.Adjusted)
+
+	// Zero values of a StateUpdate are checked and kept, and on is left out
+	u := huego.NewStateUpdate().SetHue(0).SetSat(0).SetBri(0).SetTransitionTime(0)
+	r = color.CheckState(u, huego.ValidateClamp)
+	assert.Equal(t, []huego.StateIssue{{Attribute: "bri", Value: uint8(0), Adjusted: uint8(1), Reason: "out of range 1-254"}}, r.Adjusted)
+	assert.Equal(t, huego.NewStateUpdate().SetHue(0).SetSat(0).SetBri(1).SetTransitionTime(0), r.State)
+	assert.Equal(t, uint8(0), *u.Bri)
+
+	r = ambiance.CheckState(huego.NewStateUpdate().SetHue(0).SetXyInc(0.7), huego.ValidateStrict)
+	assert.EqualError(t, r.Err(), "invalid state: hue 0: not supported; xy_inc 0.7: not supported")
+}
+
+func TestLightSetStateValidated(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	defer srv.Close()
+	b := srv.Bridge()
+
+	l, err := b.GetLight(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is sent when an attribute is rejected
+	r, err := l.SetStateValidated(huego.State{On: true, Ct: 600}, huego.ValidateStrict)
+	assert.True(t, errors.Is(err, huego.ErrInvalidValue))
+	assert.Len(t, r.Rejected, 1)
+	l, err = b.GetLight(3)
+	assert.Nil(t, err)
+	assert.False(t, l.State.On)
+
+	r, err = l.SetStateValidated(huego.State{On: true, Ct: 600}, huego.ValidateClamp)
+	assert.Nil(t, err)
+	assert.Len(t, r.Adjusted, 1)
+	assert.Equal(t, uint16(454), l.State.Ct)
+
+	l, err = b.GetLight(3)
+	assert.Nil(t, err)
+	assert.True(t, l.State.On)
+	assert.Equal(t, uint16(454), l.State.Ct)
+
+	// On is left as is
+	_, err = l.SetStateValidated(huego.NewStateUpdate().SetCt(153).SetTransitionTime(0), huego.ValidateStrict)
+	assert.Nil(t, err)
+	assert.True(t, l.State.On)
+	assert.Equal(t, uint16(153), l.State.Ct)
+}
+
+func TestGroupSetStateValidated(t *testing.T) {
+
+	srv := huegotest.NewServer()
+	defer srv.Close()
+	b := srv.Bridge()
+
+	_, err := b.CreateGroup(huego.Group{Name: "Whites", Lights: []string{"2", "3"}})
+	assert.Nil(t, err)
+	g, err := b.GetGroup(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The ambiance lamp supports ct but no light in the group supports xy
+	r, err := g.CheckState(huego.State{On: true, Ct: 500, Xy: []float32{0.3, 0.3}, Scene: "abc"}, huego.ValidateStrict)
+	assert.Nil(t, err)
+	assert.Equal(t, "invalid state: xy [0.3 0.3]: not supported; ct 500: out of range 153-454", r.Err().Error())
+
+	r, err = g.SetStateValidated(huego.State{On: true, Ct: 500, Xy: []float32{0.3, 0.3}}, huego.ValidateClamp)
+	assert.Nil(t, err)
+	assert.Equal(t, huego.NewStateUpdate().SetOn(true).SetCt(454), r.State)
+
+	l, err := b.GetLight(3)
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(454), l.State.Ct)
+
+	// Group 0 holds every light, including the color lamp
+	g, err = b.GetGroup(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = g.CheckState(huego.State{On: true, Ct: 500, Xy: []float32{0.3, 0.3}}, huego.ValidateStrict)
+	assert.Nil(t, err)
+	assert.Nil(t, r.Err())
+	// A room without lights can't show any state
+	_, err = b.CreateGroup(huego.Group{Name: "Hallway", Type: "Room", Lights: []string{}})
+	assert.Nil(t, err)
+	g, err = b.GetGroup(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = g.SetStateValidated(huego.NewStateUpdate().SetOn(true), huego.ValidateStrict)
+	assert.EqualError(t, err, "group 2 has no lights")
+}